@@ -1,9 +1,12 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
 	"os"
+	"strings"
+	"time"
 
 	commonconfig "github.com/Lumina-Enterprise-Solutions/prism-common-libs/config"
 )
@@ -15,6 +18,85 @@ type Config struct {
 	RedisAddr      string
 	VaultAddr      string
 	VaultToken     string
+
+	// QueueBackend memilih implementasi antrian: "list", "stream", "fair"
+	// (sub-antrian per tenant dengan round-robin berbobot), "partitioned"
+	// (urutan terjamin per ordering key) atau "memory" (di dalam proses, untuk
+	// pengembangan lokal tanpa Redis).
+	QueueBackend string
+	// QueuePartitions adalah jumlah partisi backend "partitioned"; job dengan
+	// ordering key berbeda yang jatuh di partisi sama ikut diproses berurutan.
+	QueuePartitions int
+	// QueueReliable mengaktifkan dequeue at-least-once (BLMOVE + processing list)
+	// untuk backend "list". Backend "stream" selalu at-least-once.
+	QueueReliable          bool
+	QueueVisibilityTimeout time.Duration
+	QueueReaperInterval    time.Duration
+	// QueuePriorityMode mengatur urutan lane prioritas: "strict" atau "weighted".
+	QueuePriorityMode string
+	// QueueLaneWeights adalah bobot per lane untuk mode "weighted".
+	QueueLaneWeights map[string]int
+	// TenantWeights adalah bobot round-robin per tenant untuk backend "fair"
+	// (default 1). TenantMaxInflight membatasi job in-flight per tenant (0 = tanpa batas).
+	TenantWeights     map[string]int
+	TenantMaxInflight int
+	// SchedulerInterval adalah seberapa sering job terjadwal diperiksa.
+	SchedulerInterval time.Duration
+	// IdempotencyWindow adalah lama Idempotency-Key diingat.
+	IdempotencyWindow time.Duration
+	// QueueHighWaterMark dan TenantHighWaterMark adalah batas job menunggu
+	// (global dan per tenant) sebelum permintaan baru ditolak dengan 429.
+	// Nilai 0 menonaktifkan batas.
+	QueueHighWaterMark  int
+	TenantHighWaterMark int
+	// BulkShedPercent menolak job lane bulk lebih dulu, mulai persentase ini
+	// dari high-water mark.
+	BulkShedPercent int
+	// BackpressureRetryAfter dikirim sebagai header Retry-After saat menolak.
+	BackpressureRetryAfter time.Duration
+	// StatusTTL adalah lama status pengiriman notifikasi disimpan.
+	StatusTTL time.Duration
+	// WorkerConcurrency adalah jumlah goroutine worker yang memproses antrian.
+	WorkerConcurrency int
+	// WorkerDrainTimeout adalah batas waktu menunggu job in-flight saat shutdown.
+	WorkerDrainTimeout time.Duration
+
+	// EmailProvider memilih provider email: "smtp", "ses" atau "sendgrid".
+	// Beberapa provider dipisah koma (mis. "smtp,sendgrid") membentuk rantai
+	// failover sesuai urutan. Kredensial provider dimuat dari Vault.
+	EmailProvider string
+	EmailFrom     string
+	// EmailBreakerFailureThreshold adalah jumlah kegagalan berturut-turut
+	// sebelum provider dianggap tidak sehat; EmailBreakerCooldown adalah jeda
+	// minimal sebelum provider diperiksa lagi oleh probe setiap EmailProbeInterval.
+	EmailBreakerFailureThreshold int
+	EmailBreakerCooldown         time.Duration
+	EmailProbeInterval           time.Duration
+	// SMTPPoolSize, SMTPPoolIdleTimeout dan SMTPPoolMaxMessagesPerConn
+	// mengatur pool koneksi provider "smtp".
+	SMTPPoolSize               int
+	SMTPPoolIdleTimeout        time.Duration
+	SMTPPoolMaxMessagesPerConn int
+	// SESRegion dan SESEndpoint dipakai provider "ses"; SendGridEndpoint
+	// dipakai provider "sendgrid". Endpoint kosong berarti endpoint publik.
+	SESRegion        string
+	SESEndpoint      string
+	SendGridEndpoint string
+
+	// Retry policy default untuk pengiriman email yang gagal.
+	RetryMaxAttempts int
+	RetryBaseDelay   time.Duration
+	RetryMaxDelay    time.Duration
+	// TemplateRetryPolicies menimpa policy default per nama template.
+	TemplateRetryPolicies map[string]TemplateRetryPolicy
+}
+
+// TemplateRetryPolicy adalah override retry untuk satu template. Field
+// bernilai nol mengikuti policy default.
+type TemplateRetryPolicy struct {
+	MaxAttempts      int `json:"max_attempts"`
+	BaseDelaySeconds int `json:"base_delay_seconds"`
+	MaxDelaySeconds  int `json:"max_delay_seconds"`
 }
 
 func Load() *Config {
@@ -25,6 +107,30 @@ func Load() *Config {
 
 	serviceName := "prism-notification-service"
 
+	// retry_policies berisi JSON, mis. {"password_reset.html": {"max_attempts": 5}}.
+	var templateRetryPolicies map[string]TemplateRetryPolicy
+	if raw := loader.Get(fmt.Sprintf("config/%s/retry_policies", serviceName), ""); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &templateRetryPolicies); err != nil {
+			log.Fatalf("Konfigurasi retry_policies tidak valid: %v", err)
+		}
+	}
+
+	// queue_lane_weights berisi JSON, mis. {"critical": 8, "high": 4, "normal": 2, "bulk": 1}.
+	var laneWeights map[string]int
+	if raw := loader.Get(fmt.Sprintf("config/%s/queue_lane_weights", serviceName), ""); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &laneWeights); err != nil {
+			log.Fatalf("Konfigurasi queue_lane_weights tidak valid: %v", err)
+		}
+	}
+
+	// tenant_weights berisi JSON, mis. {"tenant-besar": 1, "tenant-premium": 4}.
+	var tenantWeights map[string]int
+	if raw := loader.Get(fmt.Sprintf("config/%s/tenant_weights", serviceName), ""); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &tenantWeights); err != nil {
+			log.Fatalf("Konfigurasi tenant_weights tidak valid: %v", err)
+		}
+	}
+
 	return &Config{
 		Port:           loader.GetInt(fmt.Sprintf("config/%s/port", serviceName), 8080),
 		ServiceName:    serviceName,
@@ -32,5 +138,54 @@ func Load() *Config {
 		RedisAddr:      loader.Get("config/global/redis_addr", "cache-redis:6379"),
 		VaultAddr:      os.Getenv("VAULT_ADDR"), // Env var masih cara terbaik untuk info infra
 		VaultToken:     os.Getenv("VAULT_TOKEN"),
+
+		QueueBackend:           loader.Get(fmt.Sprintf("config/%s/queue_backend", serviceName), "list"),
+		QueuePartitions:        loader.GetInt(fmt.Sprintf("config/%s/queue_partitions", serviceName), 16),
+		QueueReliable:          loader.Get(fmt.Sprintf("config/%s/queue_reliable", serviceName), "false") == "true",
+		QueueVisibilityTimeout: time.Duration(loader.GetInt(fmt.Sprintf("config/%s/queue_visibility_timeout_seconds", serviceName), 300)) * time.Second,
+		QueueReaperInterval:    time.Duration(loader.GetInt(fmt.Sprintf("config/%s/queue_reaper_interval_seconds", serviceName), 30)) * time.Second,
+		QueuePriorityMode:      loader.Get(fmt.Sprintf("config/%s/queue_priority_mode", serviceName), "strict"),
+		QueueLaneWeights:       laneWeights,
+		TenantWeights:          tenantWeights,
+		TenantMaxInflight:      loader.GetInt(fmt.Sprintf("config/%s/tenant_max_inflight", serviceName), 0),
+		SchedulerInterval:      time.Duration(loader.GetInt(fmt.Sprintf("config/%s/scheduler_interval_seconds", serviceName), 1)) * time.Second,
+		IdempotencyWindow:      time.Duration(loader.GetInt(fmt.Sprintf("config/%s/idempotency_window_seconds", serviceName), 86400)) * time.Second,
+		QueueHighWaterMark:     loader.GetInt(fmt.Sprintf("config/%s/queue_high_water_mark", serviceName), 0),
+		TenantHighWaterMark:    loader.GetInt(fmt.Sprintf("config/%s/tenant_high_water_mark", serviceName), 0),
+		BulkShedPercent:        loader.GetInt(fmt.Sprintf("config/%s/bulk_shed_percent", serviceName), 80),
+		BackpressureRetryAfter: time.Duration(loader.GetInt(fmt.Sprintf("config/%s/backpressure_retry_after_seconds", serviceName), 30)) * time.Second,
+		StatusTTL:              time.Duration(loader.GetInt(fmt.Sprintf("config/%s/status_ttl_seconds", serviceName), 604800)) * time.Second,
+		WorkerConcurrency:      loader.GetInt(fmt.Sprintf("config/%s/worker_concurrency", serviceName), 4),
+		WorkerDrainTimeout:     time.Duration(loader.GetInt(fmt.Sprintf("config/%s/worker_drain_timeout_seconds", serviceName), 30)) * time.Second,
+
+		EmailProvider:    loader.Get(fmt.Sprintf("config/%s/email_provider", serviceName), "smtp"),
+		EmailFrom:        loader.Get(fmt.Sprintf("config/%s/email_from", serviceName), "no-reply@prismerp.com"),
+		SESRegion:        loader.Get(fmt.Sprintf("config/%s/ses_region", serviceName), ""),
+		SESEndpoint:      loader.Get(fmt.Sprintf("config/%s/ses_endpoint", serviceName), ""),
+		SendGridEndpoint: loader.Get(fmt.Sprintf("config/%s/sendgrid_endpoint", serviceName), ""),
+
+		EmailBreakerFailureThreshold: loader.GetInt(fmt.Sprintf("config/%s/email_breaker_failure_threshold", serviceName), 3),
+		EmailBreakerCooldown:         time.Duration(loader.GetInt(fmt.Sprintf("config/%s/email_breaker_cooldown_seconds", serviceName), 30)) * time.Second,
+		EmailProbeInterval:           time.Duration(loader.GetInt(fmt.Sprintf("config/%s/email_probe_interval_seconds", serviceName), 15)) * time.Second,
+
+		SMTPPoolSize:               loader.GetInt(fmt.Sprintf("config/%s/smtp_pool_size", serviceName), 4),
+		SMTPPoolIdleTimeout:        time.Duration(loader.GetInt(fmt.Sprintf("config/%s/smtp_pool_idle_timeout_seconds", serviceName), 30)) * time.Second,
+		SMTPPoolMaxMessagesPerConn: loader.GetInt(fmt.Sprintf("config/%s/smtp_pool_max_messages_per_conn", serviceName), 100),
+
+		RetryMaxAttempts:      loader.GetInt(fmt.Sprintf("config/%s/retry_max_attempts", serviceName), 3),
+		RetryBaseDelay:        time.Duration(loader.GetInt(fmt.Sprintf("config/%s/retry_base_delay_seconds", serviceName), 20)) * time.Second,
+		RetryMaxDelay:         time.Duration(loader.GetInt(fmt.Sprintf("config/%s/retry_max_delay_seconds", serviceName), 600)) * time.Second,
+		TemplateRetryPolicies: templateRetryPolicies,
+	}
+}
+
+// EmailProviders memecah EmailProvider menjadi daftar provider berurutan.
+func (c *Config) EmailProviders() []string {
+	var providers []string
+	for _, name := range strings.Split(c.EmailProvider, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			providers = append(providers, name)
+		}
 	}
+	return providers
 }
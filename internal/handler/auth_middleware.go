@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// LocalJWTMiddleware memverifikasi token seperti auth.JWTMiddleware (HMAC
+// dengan JWT_SECRET_KEY, exp, klaim sub) tetapi tanpa memeriksa daftar
+// token yang dicabut di Redis. Hanya untuk backend memory, saat service
+// dijalankan lokal tanpa Redis; token yang sudah di-logout tetap diterima
+// sampai kedaluwarsa.
+func LocalJWTMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authHeader := c.GetHeader("Authorization")
+		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+		if authHeader == "" || tokenString == authHeader {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required, must be Bearer token"})
+			return
+		}
+		secretKey := os.Getenv("JWT_SECRET_KEY")
+		if secretKey == "" {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "JWT secret key not configured"})
+			return
+		}
+
+		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+			}
+			return []byte(secretKey), nil
+		})
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token", "details": err.Error()})
+			return
+		}
+		claims, ok := token.Claims.(jwt.MapClaims)
+		if !ok || !token.Valid {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token or claims"})
+			return
+		}
+		userID, ok := claims["sub"]
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "User ID (sub) not found in token claims"})
+			return
+		}
+		c.Set("user_id", userID)
+		c.Set("claims", claims)
+		c.Next()
+	}
+}
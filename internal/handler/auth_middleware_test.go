@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Lumina-Enterprise-Solutions/prism-common-libs/auth"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalJWTMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("JWT_SECRET_KEY", "local-secret")
+	router := gin.New()
+	router.GET("/admin", LocalJWTMiddleware(), auth.AdminOnly(), func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	sign := func(secret string, claims jwt.MapClaims) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+		require.NoError(t, err)
+		return "Bearer " + token
+	}
+	exp := time.Now().Add(time.Hour).Unix()
+	cases := []struct {
+		name   string
+		header string
+		want   int
+	}{
+		{"valid admin without redis", sign("local-secret", jwt.MapClaims{"sub": "u1", "role": "admin", "jti": "j1", "exp": exp}), http.StatusNoContent},
+		{"not admin", sign("local-secret", jwt.MapClaims{"sub": "u1", "role": "user", "exp": exp}), http.StatusForbidden},
+		{"wrong secret", sign("other", jwt.MapClaims{"sub": "u1", "role": "admin", "exp": exp}), http.StatusUnauthorized},
+		{"expired", sign("local-secret", jwt.MapClaims{"sub": "u1", "role": "admin", "exp": time.Now().Add(-time.Minute).Unix()}), http.StatusUnauthorized},
+		{"missing sub", sign("local-secret", jwt.MapClaims{"role": "admin", "exp": exp}), http.StatusUnauthorized},
+		{"missing header", "", http.StatusUnauthorized},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+			if tc.header != "" {
+				req.Header.Set("Authorization", tc.header)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tc.want, w.Code)
+		})
+	}
+}
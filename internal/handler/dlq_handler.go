@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Lumina-Enterprise-Solutions/prism-notification-service/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultDLQPageSize = 50
+	maxDLQPageSize     = 500
+)
+
+// DLQHandler menyediakan endpoint admin untuk memeriksa dan memulihkan
+// isi Dead-Letter Queue.
+type DLQHandler struct {
+	dlq service.DeadLetterQueue
+}
+
+func NewDLQHandler(dlq service.DeadLetterQueue) *DLQHandler {
+	return &DLQHandler{dlq: dlq}
+}
+
+type listDLQQuery struct {
+	service.DLQFilter
+	Offset int `form:"offset" binding:"omitempty,min=0"`
+	Limit  int `form:"limit" binding:"omitempty,min=1"`
+}
+
+func (h *DLQHandler) ListEntries(c *gin.Context) {
+	var q listDLQQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if q.Limit == 0 {
+		q.Limit = defaultDLQPageSize
+	}
+	if q.Limit > maxDLQPageSize {
+		q.Limit = maxDLQPageSize
+	}
+
+	entries, total, err := h.dlq.List(c.Request.Context(), q.DLQFilter, q.Offset, q.Limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read DLQ"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"entries": entries,
+		"total":   total,
+		"offset":  q.Offset,
+		"limit":   q.Limit,
+	})
+}
+
+func (h *DLQHandler) GetEntry(c *gin.Context) {
+	entry, err := h.dlq.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		respondDLQError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, entry)
+}
+
+func (h *DLQHandler) ReplayEntry(c *gin.Context) {
+	if err := h.dlq.Replay(c.Request.Context(), c.Param("id")); err != nil {
+		respondDLQError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"replayed": 1})
+}
+
+// ReplayEntries me-replay semua entri yang cocok dengan filter di query
+// string. Tanpa filter, ?all=true wajib disertakan.
+func (h *DLQHandler) ReplayEntries(c *gin.Context) {
+	filter, ok := bindDLQFilter(c)
+	if !ok {
+		return
+	}
+	n, err := h.dlq.ReplayMatching(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to replay DLQ entries", "replayed": n})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"replayed": n})
+}
+
+func (h *DLQHandler) DeleteEntry(c *gin.Context) {
+	if err := h.dlq.Delete(c.Request.Context(), c.Param("id")); err != nil {
+		respondDLQError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// PurgeEntries menghapus entri yang cocok dengan filter. Tanpa filter,
+// ?all=true wajib disertakan untuk mengosongkan seluruh DLQ.
+func (h *DLQHandler) PurgeEntries(c *gin.Context) {
+	filter, ok := bindDLQFilter(c)
+	if !ok {
+		return
+	}
+	n, err := h.dlq.Purge(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to purge DLQ", "purged": n})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"purged": n})
+}
+
+func bindDLQFilter(c *gin.Context) (service.DLQFilter, bool) {
+	var filter service.DLQFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return filter, false
+	}
+	if filter.IsEmpty() && c.Query("all") != "true" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A filter or all=true is required"})
+		return filter, false
+	}
+	return filter, true
+}
+
+func respondDLQError(c *gin.Context, err error) {
+	if errors.Is(err, service.ErrDLQEntryNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "DLQ entry not found"})
+		return
+	}
+	if errors.Is(err, service.ErrDLQEntryUndecodable) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "DLQ entry cannot be decoded and can only be deleted"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process DLQ entry"})
+}
@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Lumina-Enterprise-Solutions/prism-notification-service/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+type MockDLQ struct {
+	ListFunc   func(ctx context.Context, filter service.DLQFilter, offset, limit int) ([]service.DeadLetterEntry, int, error)
+	ReplayFunc func(ctx context.Context, id string) error
+	PurgeFunc  func(ctx context.Context, filter service.DLQFilter) (int, error)
+}
+
+func (m *MockDLQ) List(ctx context.Context, filter service.DLQFilter, offset, limit int) ([]service.DeadLetterEntry, int, error) {
+	if m.ListFunc != nil {
+		return m.ListFunc(ctx, filter, offset, limit)
+	}
+	return nil, 0, nil
+}
+func (m *MockDLQ) Get(ctx context.Context, id string) (*service.DeadLetterEntry, error) {
+	return nil, service.ErrDLQEntryNotFound
+}
+func (m *MockDLQ) Replay(ctx context.Context, id string) error {
+	if m.ReplayFunc != nil {
+		return m.ReplayFunc(ctx, id)
+	}
+	return nil
+}
+func (m *MockDLQ) ReplayMatching(ctx context.Context, filter service.DLQFilter) (int, error) {
+	return 0, nil
+}
+func (m *MockDLQ) Delete(ctx context.Context, id string) error {
+	return nil
+}
+func (m *MockDLQ) Purge(ctx context.Context, filter service.DLQFilter) (int, error) {
+	if m.PurgeFunc != nil {
+		return m.PurgeFunc(ctx, filter)
+	}
+	return 0, nil
+}
+
+var _ service.DeadLetterQueue = (*MockDLQ)(nil)
+
+func setupDLQRouter(dlq service.DeadLetterQueue) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	h := NewDLQHandler(dlq)
+	routes := router.Group("/notifications/admin/dlq")
+	routes.GET("", h.ListEntries)
+	routes.DELETE("", h.PurgeEntries)
+	routes.POST("/replay", h.ReplayEntries)
+	routes.GET("/:id", h.GetEntry)
+	routes.DELETE("/:id", h.DeleteEntry)
+	routes.POST("/:id/replay", h.ReplayEntry)
+	return router
+}
+
+func TestListDLQ_PassesFilterAndPaging(t *testing.T) {
+	var gotFilter service.DLQFilter
+	var gotOffset, gotLimit int
+	dlq := &MockDLQ{
+		ListFunc: func(ctx context.Context, filter service.DLQFilter, offset, limit int) ([]service.DeadLetterEntry, int, error) {
+			gotFilter, gotOffset, gotLimit = filter, offset, limit
+			return []service.DeadLetterEntry{{ID: "abc"}}, 1, nil
+		},
+	}
+	router := setupDLQRouter(dlq)
+
+	req, _ := http.NewRequest(http.MethodGet, "/notifications/admin/dlq?template_name=welcome.html&offset=20&limit=1000", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "welcome.html", gotFilter.TemplateName)
+	assert.Equal(t, 20, gotOffset)
+	assert.Equal(t, maxDLQPageSize, gotLimit, "Limit harus dibatasi")
+}
+
+func TestReplayDLQEntry_NotFound(t *testing.T) {
+	dlq := &MockDLQ{ReplayFunc: func(ctx context.Context, id string) error { return service.ErrDLQEntryNotFound }}
+	router := setupDLQRouter(dlq)
+
+	req, _ := http.NewRequest(http.MethodPost, "/notifications/admin/dlq/abc/replay", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestReplayDLQEntry_UndecodableIsUnprocessable(t *testing.T) {
+	dlq := &MockDLQ{ReplayFunc: func(ctx context.Context, id string) error { return service.ErrDLQEntryUndecodable }}
+	router := setupDLQRouter(dlq)
+
+	req, _ := http.NewRequest(http.MethodPost, "/notifications/admin/dlq/abc/replay", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+}
+
+func TestPurgeDLQ_RequiresFilterOrAll(t *testing.T) {
+	purged := false
+	dlq := &MockDLQ{PurgeFunc: func(ctx context.Context, filter service.DLQFilter) (int, error) {
+		purged = true
+		return 3, nil
+	}}
+	router := setupDLQRouter(dlq)
+
+	req, _ := http.NewRequest(http.MethodDelete, "/notifications/admin/dlq", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.False(t, purged)
+
+	req, _ = http.NewRequest(http.MethodDelete, "/notifications/admin/dlq?all=true", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"purged":3`)
+}
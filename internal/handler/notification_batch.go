@@ -0,0 +1,203 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Lumina-Enterprise-Solutions/prism-notification-service/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
+)
+
+// MaxBatchRecipients membatasi jumlah penerima dalam satu permintaan batch.
+const MaxBatchRecipients = 500
+
+// Status per item pada respons batch.
+const (
+	BatchItemAccepted = "accepted"
+	BatchItemInvalid  = "invalid"
+	BatchItemFailed   = "failed"
+)
+
+type BatchRecipient struct {
+	RecipientID string `json:"recipient_id" binding:"required"`
+	Recipient   string `json:"recipient" binding:"required,email"`
+	// TemplateData menimpa key yang sama pada template_data bersama.
+	TemplateData map[string]interface{} `json:"template_data"`
+	// OrderingKey berlaku per penerima; default recipient_id.
+	OrderingKey string `json:"ordering_key"`
+}
+
+// SendBatchRequest mengirim notifikasi yang sama ke banyak penerima. Field
+// selain recipients berlaku untuk semua penerima.
+type SendBatchRequest struct {
+	Recipients   []BatchRecipient       `json:"recipients" binding:"required,min=1"`
+	Subject      string                 `json:"subject" binding:"required"`
+	TemplateName string                 `json:"template_name" binding:"required"`
+	TemplateData map[string]interface{} `json:"template_data"`
+	TenantID     string                 `json:"tenant_id"`
+	Priority     string                 `json:"priority" binding:"omitempty,oneof=critical high normal bulk"`
+	SendAt       *time.Time             `json:"send_at"`
+	DelaySeconds int                    `json:"delay_seconds" binding:"omitempty,min=0"`
+	ExpiresAt    *time.Time             `json:"expires_at"`
+	TTLSeconds   int                    `json:"ttl_seconds" binding:"omitempty,min=1"`
+	// IdempotencyKey dipakai jika header Idempotency-Key tidak dikirim.
+	IdempotencyKey string `json:"idempotency_key"`
+}
+
+type BatchItemResult struct {
+	Index          int    `json:"index"`
+	RecipientID    string `json:"recipient_id,omitempty"`
+	NotificationID string `json:"notification_id,omitempty"`
+	Status         string `json:"status"`
+	Error          string `json:"error,omitempty"`
+}
+
+type SendBatchResponse struct {
+	Accepted int               `json:"accepted"`
+	Rejected int               `json:"rejected"`
+	SendAt   string            `json:"send_at,omitempty"`
+	Results  []BatchItemResult `json:"results"`
+}
+
+// SendBatch memvalidasi setiap penerima secara terpisah dan meng-enqueue
+// penerima yang valid sekaligus. Penerima yang tidak valid dilaporkan per
+// item tanpa menggagalkan penerima lain.
+func (h *NotificationHandler) SendBatch(c *gin.Context) {
+	var req SendBatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(req.Recipients) > MaxBatchRecipients {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Too many recipients", "max_recipients": MaxBatchRecipients})
+		return
+	}
+	if err := validateTemplateName(req.TemplateName); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	now := time.Now().UTC()
+	sendAt, err := resolveSendAt(req.SendAt, req.DelaySeconds, now)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	expiresAt, err := resolveExpiresAt(req.ExpiresAt, req.TTLSeconds, now, sendAt)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	idempotencyKey, ok := bindIdempotencyKey(c, req.IdempotencyKey)
+	if !ok {
+		return
+	}
+
+	tenant := tenantID(c, req.TenantID)
+	response := SendBatchResponse{Results: make([]BatchItemResult, len(req.Recipients))}
+	if sendAt != nil && sendAt.After(now) {
+		response.SendAt = sendAt.UTC().Format(time.RFC3339)
+	}
+	jobs := make([]service.NotificationJob, 0, len(req.Recipients))
+	indexes := make([]int, 0, len(req.Recipients))
+	for i, recipient := range req.Recipients {
+		result := &response.Results[i]
+		result.Index = i
+		result.RecipientID = recipient.RecipientID
+		if err := binding.Validator.ValidateStruct(&recipient); err != nil {
+			result.Status = BatchItemInvalid
+			result.Error = err.Error()
+			response.Rejected++
+			continue
+		}
+		job := service.NotificationJob{
+			ID:              uuid.NewString(),
+			RecipientUserID: recipient.RecipientID,
+			To:              recipient.Recipient,
+			Subject:         req.Subject,
+			TemplateName:    req.TemplateName,
+			TemplateData:    mergeTemplateData(req.TemplateData, recipient.TemplateData),
+			TenantID:        tenant,
+			Priority:        req.Priority,
+			OrderingKey:     recipient.OrderingKey,
+			SendAt:          sendAt,
+			EnqueuedAt:      &now,
+			ExpiresAt:       expiresAt,
+		}
+		result.NotificationID = job.ID
+		result.Status = BatchItemAccepted
+		response.Accepted++
+		jobs = append(jobs, job)
+		indexes = append(indexes, i)
+	}
+	if len(jobs) == 0 {
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	reserved, done := h.reserveIdempotencyKey(c, tenant, idempotencyKey, req, response)
+	if done {
+		return
+	}
+
+	for _, job := range jobs {
+		h.recordStatus(c, job.ID, service.StateQueued)
+	}
+	ctx := c.Request.Context()
+	if batch, ok := h.queueService.(service.BatchEnqueuer); ok {
+		// Semua penerima masuk dalam satu transaksi Redis, atau tidak sama sekali.
+		if err := batch.EnqueueBatch(ctx, jobs); err != nil {
+			for _, job := range jobs {
+				h.recordEnqueueFailure(c, job.ID, err)
+			}
+			if reserved {
+				h.releaseIdempotencyKey(c, tenant, idempotencyKey)
+			}
+			respondEnqueueError(c, err)
+			return
+		}
+		c.JSON(http.StatusAccepted, response)
+		return
+	}
+
+	// Queue tanpa dukungan batch: enqueue satu per satu dan laporkan
+	// kegagalan per item. Key dilepas agar caller bisa mengulang item yang
+	// gagal.
+	failed := false
+	for i, job := range jobs {
+		if err := h.queueService.Enqueue(ctx, job); err != nil {
+			h.recordEnqueueFailure(c, job.ID, err)
+			result := &response.Results[indexes[i]]
+			result.Status = BatchItemFailed
+			result.Error = err.Error()
+			response.Accepted--
+			response.Rejected++
+			failed = true
+		}
+	}
+	if failed && reserved {
+		h.releaseIdempotencyKey(c, tenant, idempotencyKey)
+	}
+	if response.Accepted == 0 {
+		c.JSON(http.StatusInternalServerError, response)
+		return
+	}
+	c.JSON(http.StatusAccepted, response)
+}
+
+// mergeTemplateData menyalin data bersama lalu menimpanya dengan data milik
+// penerima (shallow merge).
+func mergeTemplateData(shared, override map[string]interface{}) map[string]interface{} {
+	if len(shared) == 0 && len(override) == 0 {
+		return shared
+	}
+	merged := make(map[string]interface{}, len(shared)+len(override))
+	for k, v := range shared {
+		merged[k] = v
+	}
+	for k, v := range override {
+		merged[k] = v
+	}
+	return merged
+}
@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Lumina-Enterprise-Solutions/prism-notification-service/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// batchQueue menambahkan EnqueueBatch pada MockQueueService.
+type batchQueue struct {
+	MockQueueService
+	batches [][]service.NotificationJob
+	err     error
+}
+
+func (q *batchQueue) EnqueueBatch(ctx context.Context, jobs []service.NotificationJob) error {
+	if q.err != nil {
+		return q.err
+	}
+	q.batches = append(q.batches, jobs)
+	return nil
+}
+
+func sendBatch(t *testing.T, q service.Queue, req SendBatchRequest) (*httptest.ResponseRecorder, SendBatchResponse) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	h := NewNotificationHandler(q, nil, nil, nil)
+	router.POST("/notifications/send/batch", h.SendBatch)
+
+	body, _ := json.Marshal(req)
+	httpReq, _ := http.NewRequest(http.MethodPost, "/notifications/send/batch", bytes.NewBuffer(body))
+	httpReq.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httpReq)
+
+	var resp SendBatchResponse
+	_ = json.Unmarshal(rr.Body.Bytes(), &resp)
+	return rr, resp
+}
+
+func TestSendBatch_MergesDataAndEnqueuesValidRecipientsTogether(t *testing.T) {
+	q := &batchQueue{}
+	rr, resp := sendBatch(t, q, SendBatchRequest{
+		Subject:      "PO #123 menunggu persetujuan",
+		TemplateName: "welcome.html",
+		TemplateData: map[string]interface{}{"PO": "123", "Name": "Approver"},
+		Priority:     "high",
+		Recipients: []BatchRecipient{
+			{RecipientID: "u1", Recipient: "a@example.com", TemplateData: map[string]interface{}{"Name": "Budi"}},
+			{RecipientID: "u2", Recipient: "bukan-email"},
+			{RecipientID: "u3", Recipient: "c@example.com"},
+		},
+	})
+
+	require.Equal(t, http.StatusAccepted, rr.Code)
+	assert.Equal(t, 2, resp.Accepted)
+	assert.Equal(t, 1, resp.Rejected)
+	require.Len(t, resp.Results, 3)
+	assert.Equal(t, BatchItemInvalid, resp.Results[1].Status)
+	assert.NotEmpty(t, resp.Results[1].Error)
+	assert.Empty(t, resp.Results[1].NotificationID)
+
+	require.Len(t, q.batches, 1, "penerima valid harus di-enqueue dalam satu panggilan")
+	jobs := q.batches[0]
+	require.Len(t, jobs, 2)
+	assert.Equal(t, resp.Results[0].NotificationID, jobs[0].ID)
+	assert.Equal(t, map[string]interface{}{"PO": "123", "Name": "Budi"}, jobs[0].TemplateData)
+	assert.Equal(t, map[string]interface{}{"PO": "123", "Name": "Approver"}, jobs[1].TemplateData)
+	assert.Equal(t, "high", jobs[1].Priority)
+}
+
+func TestSendBatch_AllInvalidReturns400(t *testing.T) {
+	q := &batchQueue{}
+	rr, resp := sendBatch(t, q, SendBatchRequest{
+		Subject: "s", TemplateName: "welcome.html",
+		Recipients: []BatchRecipient{{Recipient: "a@example.com"}},
+	})
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, 1, resp.Rejected)
+	assert.Empty(t, q.batches)
+}
+
+func TestSendBatch_TooManyRecipients(t *testing.T) {
+	recipients := make([]BatchRecipient, MaxBatchRecipients+1)
+	rr, _ := sendBatch(t, &batchQueue{}, SendBatchRequest{Subject: "s", TemplateName: "welcome.html", Recipients: recipients})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestSendBatch_SaturatedQueue(t *testing.T) {
+	q := &batchQueue{err: &service.SaturationError{Scope: "tenant", RetryAfter: 10 * time.Second}}
+	rr, _ := sendBatch(t, q, SendBatchRequest{
+		Subject: "s", TemplateName: "welcome.html",
+		Recipients: []BatchRecipient{{RecipientID: "u1", Recipient: "a@example.com"}},
+	})
+
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Equal(t, "10", rr.Header().Get("Retry-After"))
+}
+
+func TestSendBatch_FallbackReportsPerItemFailures(t *testing.T) {
+	q := &MockQueueService{
+		EnqueueFunc: func(ctx context.Context, job service.NotificationJob) error {
+			if job.RecipientUserID == "u2" {
+				return errors.New("redis down")
+			}
+			return nil
+		},
+	}
+	rr, resp := sendBatch(t, q, SendBatchRequest{
+		Subject: "s", TemplateName: "welcome.html",
+		Recipients: []BatchRecipient{
+			{RecipientID: "u1", Recipient: "a@example.com"},
+			{RecipientID: "u2", Recipient: "b@example.com"},
+		},
+	})
+
+	assert.Equal(t, http.StatusAccepted, rr.Code)
+	assert.Equal(t, 1, resp.Accepted)
+	assert.Equal(t, BatchItemFailed, resp.Results[1].Status)
+}
+
+func TestSendBatch_WithMemoryQueue(t *testing.T) {
+	q := service.NewMemoryQueue()
+	rr, resp := sendBatch(t, q, SendBatchRequest{
+		Subject: "s", TemplateName: "welcome.html", Priority: "critical",
+		Recipients: []BatchRecipient{{RecipientID: "u1", Recipient: "a@example.com"}},
+	})
+	require.Equal(t, http.StatusAccepted, rr.Code)
+
+	job, err := q.Dequeue(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, resp.Results[0].NotificationID, job.ID)
+	assert.Equal(t, "critical", job.Priority)
+}
@@ -1,15 +1,25 @@
 package handler
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/Lumina-Enterprise-Solutions/prism-notification-service/internal/service"
 	ws "github.com/Lumina-Enterprise-Solutions/prism-notification-service/internal/websocket"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 )
 
+const maxIdempotencyKeyLength = 255
+
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
@@ -18,12 +28,16 @@ var upgrader = websocket.Upgrader{
 
 type NotificationHandler struct {
 	queueService service.Queue
+	idempotency  service.IdempotencyStore // nil berarti Idempotency-Key diabaikan
+	status       service.StatusStore      // nil berarti status pengiriman tidak dilacak
 	hub          *ws.Hub
 }
 
-func NewNotificationHandler(queueService service.Queue, hub *ws.Hub) *NotificationHandler {
+func NewNotificationHandler(queueService service.Queue, idempotency service.IdempotencyStore, status service.StatusStore, hub *ws.Hub) *NotificationHandler {
 	return &NotificationHandler{
 		queueService: queueService,
+		idempotency:  idempotency,
+		status:       status,
 		hub:          hub,
 	}
 }
@@ -34,6 +48,72 @@ type SendNotificationRequest struct {
 	Subject      string                 `json:"subject" binding:"required"`
 	TemplateName string                 `json:"template_name" binding:"required"`
 	TemplateData map[string]interface{} `json:"template_data"`
+	// TenantID dipakai jika caller tidak membawa JWT dengan klaim tenant_id
+	// dan tidak mengirim header X-Tenant-ID.
+	TenantID string `json:"tenant_id"`
+	// Priority memilih lane antrian: critical, high, normal (default) atau bulk.
+	Priority string `json:"priority" binding:"omitempty,oneof=critical high normal bulk"`
+	// OrderingKey mengelompokkan notifikasi yang harus dikirim berurutan;
+	// default recipient_id. Hanya dihormati backend "partitioned".
+	OrderingKey string `json:"ordering_key"`
+	// SendAt (RFC3339) atau DelaySeconds menunda pengiriman. Keduanya opsional
+	// dan tidak boleh diisi bersamaan.
+	SendAt       *time.Time `json:"send_at"`
+	DelaySeconds int        `json:"delay_seconds" binding:"omitempty,min=0"`
+	// ExpiresAt (RFC3339) atau TTLSeconds (dihitung dari waktu permintaan)
+	// membatasi kapan notifikasi masih layak dikirim. Keduanya opsional dan
+	// tidak boleh diisi bersamaan.
+	ExpiresAt  *time.Time `json:"expires_at"`
+	TTLSeconds int        `json:"ttl_seconds" binding:"omitempty,min=1"`
+	// IdempotencyKey dipakai jika header Idempotency-Key tidak dikirim.
+	IdempotencyKey string `json:"idempotency_key"`
+}
+
+// sendAt menghitung waktu kirim dari SendAt atau DelaySeconds.
+func (r *SendNotificationRequest) sendAt(now time.Time) (*time.Time, error) {
+	return resolveSendAt(r.SendAt, r.DelaySeconds, now)
+}
+
+// validateTemplateName menolak LegacyBodyTemplate, yang merender body tanpa
+// escaping dan hanya dipakai untuk job versi 0 yang dimigrasi.
+func validateTemplateName(name string) error {
+	if name == service.LegacyBodyTemplate {
+		return fmt.Errorf("template_name %s is reserved for migrated legacy jobs", name)
+	}
+	return nil
+}
+
+func resolveSendAt(sendAt *time.Time, delaySeconds int, now time.Time) (*time.Time, error) {
+	if sendAt != nil && delaySeconds > 0 {
+		return nil, errors.New("send_at and delay_seconds are mutually exclusive")
+	}
+	if delaySeconds > 0 {
+		at := now.Add(time.Duration(delaySeconds) * time.Second)
+		return &at, nil
+	}
+	return sendAt, nil
+}
+
+// resolveExpiresAt menghitung batas waktu kirim dari ExpiresAt atau
+// TTLSeconds. Batas harus di masa depan dan setelah waktu kirim terjadwal.
+func resolveExpiresAt(expiresAt *time.Time, ttlSeconds int, now time.Time, sendAt *time.Time) (*time.Time, error) {
+	if expiresAt != nil && ttlSeconds > 0 {
+		return nil, errors.New("expires_at and ttl_seconds are mutually exclusive")
+	}
+	if ttlSeconds > 0 {
+		at := now.Add(time.Duration(ttlSeconds) * time.Second)
+		expiresAt = &at
+	}
+	if expiresAt == nil {
+		return nil, nil
+	}
+	if !expiresAt.After(now) {
+		return nil, errors.New("expires_at must be in the future")
+	}
+	if sendAt != nil && !expiresAt.After(*sendAt) {
+		return nil, errors.New("expires_at must be after send_at")
+	}
+	return expiresAt, nil
 }
 
 func (h *NotificationHandler) SendNotification(c *gin.Context) {
@@ -42,19 +122,247 @@ func (h *NotificationHandler) SendNotification(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err := validateTemplateName(req.TemplateName); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	now := time.Now().UTC()
+	sendAt, err := req.sendAt(now)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	expiresAt, err := resolveExpiresAt(req.ExpiresAt, req.TTLSeconds, now, sendAt)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	idempotencyKey, ok := bindIdempotencyKey(c, req.IdempotencyKey)
+	if !ok {
+		return
+	}
+
+	tenant := tenantID(c, req.TenantID)
 	job := service.NotificationJob{
+		ID:              uuid.NewString(),
 		RecipientUserID: req.RecipientID,
 		To:              req.Recipient,
 		Subject:         req.Subject,
 		TemplateName:    req.TemplateName,
 		TemplateData:    req.TemplateData,
+		TenantID:        tenant,
+		Priority:        req.Priority,
+		OrderingKey:     req.OrderingKey,
+		SendAt:          sendAt,
+		EnqueuedAt:      &now,
+		ExpiresAt:       expiresAt,
+	}
+	response := gin.H{"message": "Notification accepted for processing", "notification_id": job.ID}
+	if sendAt != nil && sendAt.After(now) {
+		response["message"] = "Notification scheduled"
+		response["send_at"] = sendAt.UTC().Format(time.RFC3339)
+	}
+
+	ctx := c.Request.Context()
+	reserved, done := h.reserveIdempotencyKey(c, tenant, idempotencyKey, req, response)
+	if done {
+		return
+	}
+
+	// Status dicatat sebelum enqueue agar tidak menimpa "processing" dari worker.
+	h.recordStatus(c, job.ID, service.StateQueued)
+	err = h.queueService.Enqueue(ctx, job)
+	if err != nil {
+		h.recordEnqueueFailure(c, job.ID, err)
+		if reserved {
+			h.releaseIdempotencyKey(c, tenant, idempotencyKey)
+		}
+		respondEnqueueError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, response)
+}
+
+// bindIdempotencyKey mengambil Idempotency-Key dari header, lalu dari body.
+func bindIdempotencyKey(c *gin.Context, fromBody string) (string, bool) {
+	key := c.GetHeader("Idempotency-Key")
+	if key == "" {
+		key = fromBody
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Idempotency key is too long"})
+		return "", false
+	}
+	return key, true
+}
+
+// reserveIdempotencyKey menyimpan response untuk key tersebut milik tenant.
+// request adalah permintaan yang sudah di-bind; hash-nya disimpan agar key
+// yang dipakai ulang untuk permintaan lain ditolak dengan 422. done bernilai
+// true jika respons sudah ditulis (permintaan ulang atau error).
+func (h *NotificationHandler) reserveIdempotencyKey(c *gin.Context, tenant, key string, request, response interface{}) (reserved, done bool) {
+	if key == "" || h.idempotency == nil {
+		return false, false
+	}
+	body, err := json.Marshal(response)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode response"})
+		return false, true
+	}
+	requestHash, err := hashRequest(request)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode request"})
+		return false, true
+	}
+	existing, ok, err := h.idempotency.Reserve(c.Request.Context(), tenant, key, requestHash, body)
+	if errors.Is(err, service.ErrIdempotencyKeyMismatch) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency key was already used for a different request"})
+		return false, true
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check idempotency key"})
+		return false, true
+	}
+	if !ok {
+		// Permintaan ulang: kembalikan respons asli tanpa enqueue lagi.
+		c.Header("Idempotent-Replayed", "true")
+		c.Data(http.StatusAccepted, "application/json; charset=utf-8", existing)
+		return false, true
 	}
-	err := h.queueService.Enqueue(c.Request.Context(), job)
+	return true, false
+}
+
+// hashRequest menghitung SHA-256 dari permintaan yang sudah di-bind. Hash
+// diambil dari struct, bukan body mentah, sehingga urutan field JSON dan
+// spasi tidak berpengaruh.
+func hashRequest(request interface{}) (string, error) {
+	body, err := json.Marshal(request)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enqueue notification"})
+		return "", err
+	}
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func (h *NotificationHandler) releaseIdempotencyKey(c *gin.Context, tenant, key string) {
+	if err := h.idempotency.Release(c.Request.Context(), tenant, key); err != nil {
+		log.Printf("WARN: Failed to release idempotency key %s: %v", key, err)
+	}
+}
+
+func respondEnqueueError(c *gin.Context, err error) {
+	var saturated *service.SaturationError
+	if errors.As(err, &saturated) {
+		respondSaturated(c, saturated)
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enqueue notification"})
+}
+
+// respondSaturated menolak permintaan dengan 429 agar caller mencoba lagi
+// setelah Retry-After.
+func respondSaturated(c *gin.Context, saturated *service.SaturationError) {
+	if seconds := int(saturated.RetryAfter.Round(time.Second).Seconds()); seconds > 0 {
+		c.Header("Retry-After", strconv.Itoa(seconds))
+	}
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": "Notification queue is saturated, retry later", "scope": saturated.Scope})
+}
+
+// tenantID mengambil tenant dari header X-Tenant-ID, lalu field tenant_id
+// di body. /send dipanggil service internal tanpa JWT, jadi tenant dipercaya
+// apa adanya dari caller; admission per tenant dan key idempotency
+// bergantung pada nilai ini.
+func tenantID(c *gin.Context, fromBody string) string {
+	if tenant := c.GetHeader("X-Tenant-ID"); tenant != "" {
+		return tenant
+	}
+	return fromBody
+}
+
+func (h *NotificationHandler) recordStatus(c *gin.Context, id string, state service.DeliveryState) {
+	if h.status == nil {
+		return
+	}
+	if err := h.status.Record(c.Request.Context(), id, state); err != nil {
+		log.Printf("WARN: Failed to record status %s for notification %s: %v", state, id, err)
+	}
+}
+
+// recordEnqueueFailure menandai notifikasi gagal di-enqueue. Permintaan yang
+// ditolak backpressure tidak pernah diterima, jadi status queued-nya dihapus
+// alih-alih dicatat sebagai failed.
+func (h *NotificationHandler) recordEnqueueFailure(c *gin.Context, id string, err error) {
+	if !errors.Is(err, service.ErrQueueSaturated) {
+		h.recordStatus(c, id, service.StateFailed)
+		return
+	}
+	if h.status == nil {
 		return
 	}
-	c.JSON(http.StatusAccepted, gin.H{"message": "Notification accepted for processing"})
+	if err := h.status.Delete(c.Request.Context(), id); err != nil {
+		log.Printf("WARN: Failed to delete status for rejected notification %s: %v", id, err)
+	}
+}
+
+// GetNotificationStatus mengembalikan status pengiriman terakhir sebuah
+// notifikasi beserta status per channel.
+func (h *NotificationHandler) GetNotificationStatus(c *gin.Context) {
+	if h.status == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+		return
+	}
+	status, err := h.status.Get(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, service.ErrStatusNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read notification status"})
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// CancelNotificationResponse melaporkan apakah pembatalan menang atas
+// pengiriman. State adalah status notifikasi setelah permintaan diproses.
+type CancelNotificationResponse struct {
+	ID        string                `json:"id"`
+	Cancelled bool                  `json:"cancelled"`
+	State     service.DeliveryState `json:"state"`
+}
+
+// CancelNotification menarik notifikasi yang masih queued, terjadwal atau
+// menunggu retry. Job yang sudah diambil worker tidak bisa dibatalkan dan
+// dijawab dengan 409.
+func (h *NotificationHandler) CancelNotification(c *gin.Context) {
+	if h.status == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+		return
+	}
+	id := c.Param("id")
+	state, cancelled, err := h.status.Cancel(c.Request.Context(), id)
+	if errors.Is(err, service.ErrStatusNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel notification"})
+		return
+	}
+	response := CancelNotificationResponse{ID: id, Cancelled: cancelled, State: state}
+	if !cancelled {
+		c.JSON(http.StatusConflict, response)
+		return
+	}
+
+	// Status cancelled sudah cukup agar worker melewati job; menghapusnya dari
+	// jadwal hanya membebaskan tempat, jadi kegagalan cukup dicatat.
+	if canceller, ok := h.queueService.(service.ScheduleCanceller); ok {
+		if _, err := canceller.CancelScheduled(c.Request.Context(), id); err != nil {
+			log.Printf("WARN: Failed to remove cancelled notification %s from schedule: %v", id, err)
+		}
+	}
+	c.JSON(http.StatusOK, response)
 }
 
 func (h *NotificationHandler) HandleWebSocket(c *gin.Context) {
@@ -27,7 +27,9 @@ import (
 type MockQueueService struct {
 	EnqueueFunc    func(ctx context.Context, job service.NotificationJob) error
 	DequeueFunc    func(ctx context.Context) (*service.NotificationJob, error)
-	EnqueueDLQFunc func(ctx context.Context, job service.NotificationJob) error
+	AckFunc        func(ctx context.Context, job *service.NotificationJob) error
+	NackFunc       func(ctx context.Context, job *service.NotificationJob) error
+	EnqueueDLQFunc func(ctx context.Context, job service.NotificationJob, failure service.DeliveryFailure) error
 }
 
 func (m *MockQueueService) Enqueue(ctx context.Context, job service.NotificationJob) error {
@@ -42,9 +44,21 @@ func (m *MockQueueService) Dequeue(ctx context.Context) (*service.NotificationJo
 	}
 	return nil, nil
 }
-func (m *MockQueueService) EnqueueToDLQ(ctx context.Context, job service.NotificationJob) error {
+func (m *MockQueueService) Ack(ctx context.Context, job *service.NotificationJob) error {
+	if m.AckFunc != nil {
+		return m.AckFunc(ctx, job)
+	}
+	return nil
+}
+func (m *MockQueueService) Nack(ctx context.Context, job *service.NotificationJob) error {
+	if m.NackFunc != nil {
+		return m.NackFunc(ctx, job)
+	}
+	return nil
+}
+func (m *MockQueueService) EnqueueToDLQ(ctx context.Context, job service.NotificationJob, failure service.DeliveryFailure) error {
 	if m.EnqueueDLQFunc != nil {
-		return m.EnqueueDLQFunc(ctx, job)
+		return m.EnqueueDLQFunc(ctx, job, failure)
 	}
 	return nil
 }
@@ -55,7 +69,7 @@ var _ service.Queue = (*MockQueueService)(nil)
 func setupRouter(q service.Queue, h *ws.Hub) *gin.Engine {
 	gin.SetMode(gin.TestMode)
 	router := gin.Default()
-	handler := NewNotificationHandler(q, h)
+	handler := NewNotificationHandler(q, nil, nil, h)
 	router.POST("/notifications/send", handler.SendNotification)
 	// Kita tidak akan setup /ws di sini lagi, karena testnya butuh middleware khusus
 	return router
@@ -64,7 +78,7 @@ func setupRouter(q service.Queue, h *ws.Hub) *gin.Engine {
 func setupRouterWithRealMiddleware(q service.Queue, h *ws.Hub, redisClient *redis.Client) *gin.Engine {
 	gin.SetMode(gin.TestMode)
 	router := gin.Default()
-	handler := NewNotificationHandler(q, h)
+	handler := NewNotificationHandler(q, nil, nil, h)
 	jwtAuthMiddleware := auth.JWTMiddleware(redisClient)
 	router.GET("/ws", jwtAuthMiddleware, handler.HandleWebSocket)
 	return router
@@ -156,3 +170,486 @@ func TestHandleWebSocket(t *testing.T) {
 	assert.True(t, hub.IsClientRegistered(userID), "Klien harus terdaftar setelah handshake")
 	require.NoError(t, redisMock.ExpectationsWereMet())
 }
+
+func TestSendNotification_DelaySecondsSchedulesJob(t *testing.T) {
+	hub := ws.NewHub()
+	go hub.Run()
+	defer hub.Stop()
+
+	var enqueuedJob service.NotificationJob
+	mockQueue := &MockQueueService{
+		EnqueueFunc: func(ctx context.Context, job service.NotificationJob) error {
+			enqueuedJob = job
+			return nil
+		},
+	}
+	router := setupRouter(mockQueue, hub)
+
+	before := time.Now()
+	reqBody := SendNotificationRequest{RecipientID: "u1", Recipient: "t@e.com", Subject: "s", TemplateName: "tn", DelaySeconds: 3600}
+	body, _ := json.Marshal(reqBody)
+	req, _ := http.NewRequest(http.MethodPost, "/notifications/send", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusAccepted, rr.Code)
+	require.NotNil(t, enqueuedJob.SendAt, "SendAt harus diisi dari delay_seconds")
+	assert.True(t, enqueuedJob.SendAt.After(before.Add(59*time.Minute)))
+}
+
+func TestSendNotification_TTLSetsExpiresAt(t *testing.T) {
+	hub := ws.NewHub()
+	go hub.Run()
+	defer hub.Stop()
+
+	var enqueuedJob service.NotificationJob
+	mockQueue := &MockQueueService{
+		EnqueueFunc: func(ctx context.Context, job service.NotificationJob) error {
+			enqueuedJob = job
+			return nil
+		},
+	}
+	router := setupRouter(mockQueue, hub)
+
+	before := time.Now()
+	body, _ := json.Marshal(SendNotificationRequest{RecipientID: "u1", Recipient: "t@e.com", Subject: "s", TemplateName: "otp.html", TTLSeconds: 300})
+	req, _ := http.NewRequest(http.MethodPost, "/notifications/send", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusAccepted, rr.Code)
+	require.NotNil(t, enqueuedJob.ExpiresAt, "ExpiresAt harus diisi dari ttl_seconds")
+	assert.True(t, enqueuedJob.ExpiresAt.After(before.Add(299*time.Second)))
+}
+
+func TestResolveExpiresAt(t *testing.T) {
+	now := time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC)
+	past := now.Add(-time.Minute)
+	later := now.Add(time.Hour)
+	sendAt := now.Add(2 * time.Hour)
+
+	at, err := resolveExpiresAt(nil, 0, now, nil)
+	assert.NoError(t, err)
+	assert.Nil(t, at)
+
+	at, err = resolveExpiresAt(&later, 0, now, nil)
+	require.NoError(t, err)
+	assert.Equal(t, later, *at)
+
+	_, err = resolveExpiresAt(&later, 60, now, nil)
+	assert.Error(t, err, "expires_at dan ttl_seconds tidak boleh bersamaan")
+	_, err = resolveExpiresAt(&past, 0, now, nil)
+	assert.Error(t, err, "expires_at di masa lalu")
+	_, err = resolveExpiresAt(&later, 0, now, &sendAt)
+	assert.Error(t, err, "expires_at sebelum send_at")
+}
+
+func TestSendNotification_OrderingKeyIsPassedToJob(t *testing.T) {
+	hub := ws.NewHub()
+	go hub.Run()
+	defer hub.Stop()
+
+	var enqueuedJob service.NotificationJob
+	mockQueue := &MockQueueService{
+		EnqueueFunc: func(ctx context.Context, job service.NotificationJob) error {
+			enqueuedJob = job
+			return nil
+		},
+	}
+	router := setupRouter(mockQueue, hub)
+
+	body, _ := json.Marshal(SendNotificationRequest{RecipientID: "u1", Recipient: "t@e.com", Subject: "s", TemplateName: "tn", OrderingKey: "order-42"})
+	req, _ := http.NewRequest(http.MethodPost, "/notifications/send", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusAccepted, rr.Code)
+	assert.Equal(t, "order-42", enqueuedJob.OrderingKey)
+	assert.Equal(t, "order-42", service.OrderingKeyOf(&enqueuedJob))
+}
+
+func TestSendNotification_SendAtAndDelayAreExclusive(t *testing.T) {
+	hub := ws.NewHub()
+	go hub.Run()
+	defer hub.Stop()
+
+	router := setupRouter(&MockQueueService{}, hub)
+
+	body := []byte(`{"recipient_id":"u1","recipient":"t@e.com","subject":"s","template_name":"tn","send_at":"2030-01-01T00:00:00Z","delay_seconds":60}`)
+	req, _ := http.NewRequest(http.MethodPost, "/notifications/send", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestSendNotification_RejectsLegacyBodyTemplate(t *testing.T) {
+	mockQueue := &MockQueueService{
+		EnqueueFunc: func(ctx context.Context, job service.NotificationJob) error {
+			t.Fatal("template legacy tidak boleh di-enqueue dari API")
+			return nil
+		},
+	}
+	router := setupRouter(mockQueue, nil)
+
+	body := []byte(`{"recipient_id":"u1","recipient":"t@e.com","subject":"s","template_name":"legacy_body.html","template_data":{"body":"<script>x</script>"}}`)
+	req, _ := http.NewRequest(http.MethodPost, "/notifications/send", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+// memoryIdempotencyStore adalah IdempotencyStore sederhana berbasis map.
+type memoryIdempotencyStore struct {
+	responses map[string][]byte
+	hashes    map[string]string
+}
+
+func newMemoryIdempotencyStore() *memoryIdempotencyStore {
+	return &memoryIdempotencyStore{responses: map[string][]byte{}, hashes: map[string]string{}}
+}
+
+func (m *memoryIdempotencyStore) Reserve(ctx context.Context, tenant, key, requestHash string, response []byte) ([]byte, bool, error) {
+	k := tenant + ":" + key
+	if existing, ok := m.responses[k]; ok {
+		if m.hashes[k] != requestHash {
+			return nil, false, service.ErrIdempotencyKeyMismatch
+		}
+		return existing, false, nil
+	}
+	m.responses[k] = response
+	m.hashes[k] = requestHash
+	return nil, true, nil
+}
+func (m *memoryIdempotencyStore) Release(ctx context.Context, tenant, key string) error {
+	delete(m.responses, tenant+":"+key)
+	delete(m.hashes, tenant+":"+key)
+	return nil
+}
+
+func TestSendNotification_IdempotencyKeyPreventsDuplicateEnqueue(t *testing.T) {
+	hub := ws.NewHub()
+	go hub.Run()
+	defer hub.Stop()
+
+	enqueued := 0
+	mockQueue := &MockQueueService{
+		EnqueueFunc: func(ctx context.Context, job service.NotificationJob) error {
+			enqueued++
+			return nil
+		},
+	}
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	h := NewNotificationHandler(mockQueue, newMemoryIdempotencyStore(), nil, hub)
+	router.POST("/notifications/send", h.SendNotification)
+
+	body, _ := json.Marshal(SendNotificationRequest{RecipientID: "u1", Recipient: "t@e.com", Subject: "s", TemplateName: "password_reset.html"})
+	send := func() *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodPost, "/notifications/send", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Idempotency-Key", "reset-u1-001")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	first := send()
+	second := send()
+
+	assert.Equal(t, http.StatusAccepted, first.Code)
+	assert.Equal(t, http.StatusAccepted, second.Code)
+	assert.Equal(t, 1, enqueued, "Permintaan ulang tidak boleh meng-enqueue lagi")
+	assert.JSONEq(t, first.Body.String(), second.Body.String(), "Respons asli harus dikembalikan")
+	assert.Equal(t, "true", second.Header().Get("Idempotent-Replayed"))
+}
+
+func TestSendNotification_IdempotencyKeyIsScopedToRequestAndTenant(t *testing.T) {
+	hub := ws.NewHub()
+	go hub.Run()
+	defer hub.Stop()
+
+	enqueued := 0
+	mockQueue := &MockQueueService{
+		EnqueueFunc: func(ctx context.Context, job service.NotificationJob) error {
+			enqueued++
+			return nil
+		},
+	}
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	h := NewNotificationHandler(mockQueue, newMemoryIdempotencyStore(), nil, hub)
+	router.POST("/notifications/send", h.SendNotification)
+
+	send := func(tenant, recipient string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(SendNotificationRequest{RecipientID: "u1", Recipient: recipient, Subject: "s", TemplateName: "welcome.html"})
+		req, _ := http.NewRequest(http.MethodPost, "/notifications/send", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Idempotency-Key", "welcome-001")
+		req.Header.Set("X-Tenant-ID", tenant)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	assert.Equal(t, http.StatusAccepted, send("tenant-a", "a@example.com").Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, send("tenant-a", "b@example.com").Code,
+		"key yang sama untuk permintaan berbeda harus ditolak")
+	assert.Equal(t, http.StatusAccepted, send("tenant-b", "b@example.com").Code,
+		"tenant lain boleh memakai key yang sama")
+	assert.Equal(t, 2, enqueued)
+}
+
+type memoryStatusStore struct {
+	statuses map[string]*service.NotificationStatus
+}
+
+func (m *memoryStatusStore) Record(ctx context.Context, id string, state service.DeliveryState) error {
+	if m.statuses[id] == nil {
+		m.statuses[id] = &service.NotificationStatus{ID: id, Channels: map[string]service.ChannelStatus{}}
+	}
+	m.statuses[id].State = state
+	return nil
+}
+
+func (m *memoryStatusStore) RecordChannel(ctx context.Context, id, channel string, state service.DeliveryState, errMsg string) error {
+	if err := m.Record(ctx, id, m.statuses[id].State); err != nil {
+		return err
+	}
+	m.statuses[id].Channels[channel] = service.ChannelStatus{State: state, Error: errMsg}
+	return nil
+}
+
+func (m *memoryStatusStore) Get(ctx context.Context, id string) (*service.NotificationStatus, error) {
+	if s, ok := m.statuses[id]; ok {
+		return s, nil
+	}
+	return nil, service.ErrStatusNotFound
+}
+
+func (m *memoryStatusStore) Claim(ctx context.Context, id string) (bool, error) {
+	if s, ok := m.statuses[id]; ok && s.State == service.StateCancelled {
+		return false, nil
+	}
+	return true, m.Record(ctx, id, service.StateProcessing)
+}
+
+func (m *memoryStatusStore) Cancel(ctx context.Context, id string) (service.DeliveryState, bool, error) {
+	s, ok := m.statuses[id]
+	if !ok {
+		return "", false, service.ErrStatusNotFound
+	}
+	switch s.State {
+	case service.StateQueued, service.StateRetrying, service.StateCancelled:
+		s.State = service.StateCancelled
+		return s.State, true, nil
+	}
+	return s.State, false, nil
+}
+
+func (m *memoryStatusStore) Delete(ctx context.Context, id string) error {
+	delete(m.statuses, id)
+	return nil
+}
+
+func TestNotificationStatus_QueuedOnSendAndReadable(t *testing.T) {
+	hub := ws.NewHub()
+	go hub.Run()
+	defer hub.Stop()
+
+	store := &memoryStatusStore{statuses: map[string]*service.NotificationStatus{}}
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	h := NewNotificationHandler(&MockQueueService{}, nil, store, hub)
+	router.POST("/notifications/send", h.SendNotification)
+	router.GET("/notifications/:id", h.GetNotificationStatus)
+
+	body, _ := json.Marshal(SendNotificationRequest{RecipientID: "u1", Recipient: "t@e.com", Subject: "s", TemplateName: "welcome.html"})
+	req, _ := http.NewRequest(http.MethodPost, "/notifications/send", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusAccepted, rr.Code)
+
+	var sent map[string]string
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &sent))
+	id := sent["notification_id"]
+	require.NotEmpty(t, id)
+
+	req, _ = http.NewRequest(http.MethodGet, "/notifications/"+id, nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var status service.NotificationStatus
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &status))
+	assert.Equal(t, service.StateQueued, status.State)
+
+	req, _ = http.NewRequest(http.MethodGet, "/notifications/unknown", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestSendNotification_Priority(t *testing.T) {
+	hub := ws.NewHub()
+	go hub.Run()
+	defer hub.Stop()
+
+	var enqueuedJob service.NotificationJob
+	mockQueue := &MockQueueService{
+		EnqueueFunc: func(ctx context.Context, job service.NotificationJob) error {
+			enqueuedJob = job
+			return nil
+		},
+	}
+	router := setupRouter(mockQueue, hub)
+	send := func(priority string) int {
+		body, _ := json.Marshal(SendNotificationRequest{RecipientID: "u1", Recipient: "t@e.com", Subject: "s", TemplateName: "password_reset.html", Priority: priority})
+		req, _ := http.NewRequest(http.MethodPost, "/notifications/send", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	assert.Equal(t, http.StatusAccepted, send("critical"))
+	assert.Equal(t, "critical", enqueuedJob.Priority)
+	assert.Equal(t, http.StatusBadRequest, send("urgent"))
+}
+
+func TestSendNotification_TenantHeaderOverridesBody(t *testing.T) {
+	hub := ws.NewHub()
+	go hub.Run()
+	defer hub.Stop()
+
+	var enqueuedJob service.NotificationJob
+	mockQueue := &MockQueueService{
+		EnqueueFunc: func(ctx context.Context, job service.NotificationJob) error {
+			enqueuedJob = job
+			return nil
+		},
+	}
+	router := setupRouter(mockQueue, hub)
+	send := func(header string) string {
+		body, _ := json.Marshal(SendNotificationRequest{RecipientID: "u1", Recipient: "t@e.com", Subject: "s", TemplateName: "welcome.html", TenantID: "tenant-body"})
+		req, _ := http.NewRequest(http.MethodPost, "/notifications/send", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		if header != "" {
+			req.Header.Set("X-Tenant-ID", header)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusAccepted, rr.Code)
+		return enqueuedJob.TenantID
+	}
+
+	assert.Equal(t, "tenant-header", send("tenant-header"))
+	assert.Equal(t, "tenant-body", send(""))
+}
+
+func TestSendNotification_SaturatedQueueReturns429(t *testing.T) {
+	hub := ws.NewHub()
+	go hub.Run()
+	defer hub.Stop()
+
+	mockQueue := &MockQueueService{
+		EnqueueFunc: func(ctx context.Context, job service.NotificationJob) error {
+			return &service.SaturationError{Scope: "queue", Depth: 100, Limit: 100, RetryAfter: 30 * time.Second}
+		},
+	}
+	router := setupRouter(mockQueue, hub)
+
+	body, _ := json.Marshal(SendNotificationRequest{RecipientID: "u1", Recipient: "t@e.com", Subject: "s", TemplateName: "welcome.html"})
+	req, _ := http.NewRequest(http.MethodPost, "/notifications/send", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Equal(t, "30", rr.Header().Get("Retry-After"))
+}
+
+func TestSendNotification_SaturatedQueueLeavesNoStatus(t *testing.T) {
+	hub := ws.NewHub()
+	go hub.Run()
+	defer hub.Stop()
+
+	store := &memoryStatusStore{statuses: map[string]*service.NotificationStatus{}}
+	mockQueue := &MockQueueService{
+		EnqueueFunc: func(ctx context.Context, job service.NotificationJob) error {
+			return &service.SaturationError{Scope: "tenant", Tenant: "tenant-a", Depth: 5, Limit: 5}
+		},
+	}
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	h := NewNotificationHandler(mockQueue, nil, store, hub)
+	router.POST("/notifications/send", h.SendNotification)
+
+	body, _ := json.Marshal(SendNotificationRequest{RecipientID: "u1", Recipient: "t@e.com", Subject: "s", TemplateName: "welcome.html"})
+	req, _ := http.NewRequest(http.MethodPost, "/notifications/send", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Empty(t, store.statuses, "permintaan yang ditolak tidak boleh meninggalkan status")
+}
+
+// cancellableQueue mencatat ID yang dihapus dari jadwal.
+type cancellableQueue struct {
+	MockQueueService
+	cancelled []string
+}
+
+func (q *cancellableQueue) CancelScheduled(ctx context.Context, id string) ([]service.NotificationJob, error) {
+	q.cancelled = append(q.cancelled, id)
+	return nil, nil
+}
+
+func TestCancelNotification(t *testing.T) {
+	hub := ws.NewHub()
+	go hub.Run()
+	defer hub.Stop()
+
+	store := &memoryStatusStore{statuses: map[string]*service.NotificationStatus{
+		"queued": {ID: "queued", State: service.StateQueued},
+		"sent":   {ID: "sent", State: service.StateSent},
+	}}
+	queue := &cancellableQueue{}
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	h := NewNotificationHandler(queue, nil, store, hub)
+	router.DELETE("/notifications/:id", h.CancelNotification)
+	cancel := func(id string) (int, CancelNotificationResponse) {
+		req, _ := http.NewRequest(http.MethodDelete, "/notifications/"+id, nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		var resp CancelNotificationResponse
+		_ = json.Unmarshal(rr.Body.Bytes(), &resp)
+		return rr.Code, resp
+	}
+
+	code, resp := cancel("queued")
+	assert.Equal(t, http.StatusOK, code)
+	assert.True(t, resp.Cancelled)
+	assert.Equal(t, service.StateCancelled, resp.State)
+	assert.Equal(t, []string{"queued"}, queue.cancelled)
+
+	code, resp = cancel("queued")
+	assert.Equal(t, http.StatusOK, code, "pembatalan ulang bersifat idempoten")
+	assert.True(t, resp.Cancelled)
+
+	code, resp = cancel("sent")
+	assert.Equal(t, http.StatusConflict, code)
+	assert.False(t, resp.Cancelled)
+	assert.Equal(t, service.StateSent, resp.State)
+
+	code, _ = cancel("unknown")
+	assert.Equal(t, http.StatusNotFound, code)
+}
@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Lumina-Enterprise-Solutions/prism-notification-service/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// QuarantineHandler menyediakan endpoint admin untuk memeriksa dan
+// menghapus payload antrian yang gagal di-decode.
+type QuarantineHandler struct {
+	quarantine service.Quarantine
+}
+
+func NewQuarantineHandler(quarantine service.Quarantine) *QuarantineHandler {
+	return &QuarantineHandler{quarantine: quarantine}
+}
+
+type listQuarantineQuery struct {
+	Offset int `form:"offset" binding:"omitempty,min=0"`
+	Limit  int `form:"limit" binding:"omitempty,min=1"`
+}
+
+func (h *QuarantineHandler) ListEntries(c *gin.Context) {
+	var q listQuarantineQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if q.Limit == 0 {
+		q.Limit = defaultDLQPageSize
+	}
+	if q.Limit > maxDLQPageSize {
+		q.Limit = maxDLQPageSize
+	}
+
+	entries, total, err := h.quarantine.List(c.Request.Context(), q.Offset, q.Limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read quarantine"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"entries": entries,
+		"total":   total,
+		"offset":  q.Offset,
+		"limit":   q.Limit,
+	})
+}
+
+func (h *QuarantineHandler) GetEntry(c *gin.Context) {
+	entry, err := h.quarantine.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		respondQuarantineError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, entry)
+}
+
+func (h *QuarantineHandler) DeleteEntry(c *gin.Context) {
+	if err := h.quarantine.Delete(c.Request.Context(), c.Param("id")); err != nil {
+		respondQuarantineError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// PurgeEntries mengosongkan karantina; ?all=true wajib disertakan.
+func (h *QuarantineHandler) PurgeEntries(c *gin.Context) {
+	if c.Query("all") != "true" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "all=true is required"})
+		return
+	}
+	n, err := h.quarantine.Purge(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to purge quarantine"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"purged": n})
+}
+
+func respondQuarantineError(c *gin.Context, err error) {
+	if errors.Is(err, service.ErrQuarantineEntryNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Quarantine entry not found"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process quarantine entry"})
+}
@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Lumina-Enterprise-Solutions/prism-notification-service/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memoryQuarantine struct {
+	entries []service.QuarantineEntry
+}
+
+func (m *memoryQuarantine) List(ctx context.Context, offset, limit int) ([]service.QuarantineEntry, int, error) {
+	end := min(offset+limit, len(m.entries))
+	if offset >= end {
+		return nil, len(m.entries), nil
+	}
+	return m.entries[offset:end], len(m.entries), nil
+}
+
+func (m *memoryQuarantine) Get(ctx context.Context, id string) (*service.QuarantineEntry, error) {
+	for i := range m.entries {
+		if m.entries[i].ID == id {
+			return &m.entries[i], nil
+		}
+	}
+	return nil, service.ErrQuarantineEntryNotFound
+}
+
+func (m *memoryQuarantine) Delete(ctx context.Context, id string) error {
+	for i := range m.entries {
+		if m.entries[i].ID == id {
+			m.entries = append(m.entries[:i], m.entries[i+1:]...)
+			return nil
+		}
+	}
+	return service.ErrQuarantineEntryNotFound
+}
+
+func (m *memoryQuarantine) Purge(ctx context.Context) (int, error) {
+	n := len(m.entries)
+	m.entries = nil
+	return n, nil
+}
+
+var _ service.Quarantine = (*memoryQuarantine)(nil)
+
+func setupQuarantineRouter(q service.Quarantine) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	h := NewQuarantineHandler(q)
+	routes := router.Group("/notifications/admin/quarantine")
+	routes.GET("", h.ListEntries)
+	routes.DELETE("", h.PurgeEntries)
+	routes.GET("/:id", h.GetEntry)
+	routes.DELETE("/:id", h.DeleteEntry)
+	return router
+}
+
+func TestListQuarantine(t *testing.T) {
+	q := &memoryQuarantine{entries: []service.QuarantineEntry{
+		{ID: "a1", Source: "list", Payload: "{oops", Error: "unexpected end of JSON input"},
+	}}
+	router := setupQuarantineRouter(q)
+
+	req, _ := http.NewRequest(http.MethodGet, "/notifications/admin/quarantine", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	var body struct {
+		Entries []service.QuarantineEntry `json:"entries"`
+		Total   int                       `json:"total"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+	assert.Equal(t, 1, body.Total)
+	require.Len(t, body.Entries, 1)
+	assert.Equal(t, "{oops", body.Entries[0].Payload)
+}
+
+func TestDeleteQuarantineEntry(t *testing.T) {
+	q := &memoryQuarantine{entries: []service.QuarantineEntry{{ID: "a1"}}}
+	router := setupQuarantineRouter(q)
+
+	req, _ := http.NewRequest(http.MethodDelete, "/notifications/admin/quarantine/a1", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	assert.Empty(t, q.entries)
+
+	req, _ = http.NewRequest(http.MethodGet, "/notifications/admin/quarantine/a1", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestPurgeQuarantine_RequiresAll(t *testing.T) {
+	q := &memoryQuarantine{entries: []service.QuarantineEntry{{ID: "a1"}, {ID: "b2"}}}
+	router := setupQuarantineRouter(q)
+
+	req, _ := http.NewRequest(http.MethodDelete, "/notifications/admin/quarantine", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Len(t, q.entries, 2)
+
+	req, _ = http.NewRequest(http.MethodDelete, "/notifications/admin/quarantine?all=true", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"purged":2}`, rr.Body.String())
+}
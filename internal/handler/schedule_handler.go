@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Lumina-Enterprise-Solutions/prism-notification-service/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+type ScheduleHandler struct {
+	store service.ScheduleStore
+}
+
+func NewScheduleHandler(store service.ScheduleStore) *ScheduleHandler {
+	return &ScheduleHandler{store: store}
+}
+
+type ScheduleRequest struct {
+	Cron         string                 `json:"cron" binding:"required"`
+	Timezone     string                 `json:"timezone"`
+	RecipientID  string                 `json:"recipient_id" binding:"required"`
+	Recipient    string                 `json:"recipient" binding:"required,email"`
+	Subject      string                 `json:"subject" binding:"required"`
+	TemplateName string                 `json:"template_name" binding:"required"`
+	TemplateData map[string]interface{} `json:"template_data"`
+	// TenantID dipakai jika header X-Tenant-ID tidak dikirim, sama seperti
+	// SendNotification.
+	TenantID string `json:"tenant_id"`
+	Priority string `json:"priority" binding:"omitempty,oneof=critical high normal bulk"`
+}
+
+// bindSchedule mem-parse dan memvalidasi body request, termasuk ekspresi cron.
+func bindSchedule(c *gin.Context) (*service.RecurringSchedule, bool) {
+	var req ScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if err := validateTemplateName(req.TemplateName); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if _, err := service.ParseCron(req.Cron, req.Timezone); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return &service.RecurringSchedule{
+		Cron:     req.Cron,
+		Timezone: req.Timezone,
+		Job: service.NotificationJob{
+			RecipientUserID: req.RecipientID,
+			To:              req.Recipient,
+			Subject:         req.Subject,
+			TemplateName:    req.TemplateName,
+			TemplateData:    req.TemplateData,
+			TenantID:        tenantID(c, req.TenantID),
+			Priority:        req.Priority,
+		},
+	}, true
+}
+
+func (h *ScheduleHandler) CreateSchedule(c *gin.Context) {
+	schedule, ok := bindSchedule(c)
+	if !ok {
+		return
+	}
+	created, err := h.store.Create(c.Request.Context(), *schedule)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create schedule"})
+		return
+	}
+	c.JSON(http.StatusCreated, created)
+}
+
+func (h *ScheduleHandler) ListSchedules(c *gin.Context) {
+	schedules, err := h.store.List(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list schedules"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"schedules": schedules})
+}
+
+func (h *ScheduleHandler) GetSchedule(c *gin.Context) {
+	schedule, err := h.store.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		respondScheduleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, schedule)
+}
+
+func (h *ScheduleHandler) UpdateSchedule(c *gin.Context) {
+	schedule, ok := bindSchedule(c)
+	if !ok {
+		return
+	}
+	schedule.ID = c.Param("id")
+	updated, err := h.store.Update(c.Request.Context(), *schedule)
+	if err != nil {
+		respondScheduleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, updated)
+}
+
+func (h *ScheduleHandler) DeleteSchedule(c *gin.Context) {
+	if err := h.store.Delete(c.Request.Context(), c.Param("id")); err != nil {
+		respondScheduleError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func respondScheduleError(c *gin.Context, err error) {
+	if errors.Is(err, service.ErrScheduleNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Schedule not found"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process schedule"})
+}
@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Lumina-Enterprise-Solutions/prism-notification-service/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+type MockScheduleStore struct {
+	CreateFunc func(ctx context.Context, schedule service.RecurringSchedule) (*service.RecurringSchedule, error)
+	GetFunc    func(ctx context.Context, id string) (*service.RecurringSchedule, error)
+	DeleteFunc func(ctx context.Context, id string) error
+}
+
+func (m *MockScheduleStore) Create(ctx context.Context, schedule service.RecurringSchedule) (*service.RecurringSchedule, error) {
+	if m.CreateFunc != nil {
+		return m.CreateFunc(ctx, schedule)
+	}
+	return &schedule, nil
+}
+func (m *MockScheduleStore) Get(ctx context.Context, id string) (*service.RecurringSchedule, error) {
+	if m.GetFunc != nil {
+		return m.GetFunc(ctx, id)
+	}
+	return nil, service.ErrScheduleNotFound
+}
+func (m *MockScheduleStore) List(ctx context.Context) ([]service.RecurringSchedule, error) {
+	return nil, nil
+}
+func (m *MockScheduleStore) Update(ctx context.Context, schedule service.RecurringSchedule) (*service.RecurringSchedule, error) {
+	return &schedule, nil
+}
+func (m *MockScheduleStore) Delete(ctx context.Context, id string) error {
+	if m.DeleteFunc != nil {
+		return m.DeleteFunc(ctx, id)
+	}
+	return nil
+}
+
+var _ service.ScheduleStore = (*MockScheduleStore)(nil)
+
+func setupScheduleRouter(store service.ScheduleStore) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	h := NewScheduleHandler(store)
+	router.POST("/notifications/schedules", h.CreateSchedule)
+	router.GET("/notifications/schedules/:id", h.GetSchedule)
+	router.DELETE("/notifications/schedules/:id", h.DeleteSchedule)
+	return router
+}
+
+func TestCreateSchedule_Success(t *testing.T) {
+	var created service.RecurringSchedule
+	store := &MockScheduleStore{
+		CreateFunc: func(ctx context.Context, schedule service.RecurringSchedule) (*service.RecurringSchedule, error) {
+			created = schedule
+			schedule.ID = "sched-1"
+			return &schedule, nil
+		},
+	}
+	router := setupScheduleRouter(store)
+
+	body := []byte(`{"cron":"0 9 * * 1","timezone":"Asia/Jakarta","recipient_id":"u1","recipient":"t@e.com","subject":"Weekly digest","template_name":"digest.html"}`)
+	req, _ := http.NewRequest(http.MethodPost, "/notifications/schedules", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	assert.Equal(t, "u1", created.Job.RecipientUserID)
+	assert.Contains(t, rr.Body.String(), "sched-1")
+}
+
+func TestCreateSchedule_TenantFromHeaderOverridesBody(t *testing.T) {
+	var created service.RecurringSchedule
+	store := &MockScheduleStore{
+		CreateFunc: func(ctx context.Context, schedule service.RecurringSchedule) (*service.RecurringSchedule, error) {
+			created = schedule
+			return &schedule, nil
+		},
+	}
+	router := setupScheduleRouter(store)
+
+	body := []byte(`{"cron":"0 9 * * 1","recipient_id":"u1","recipient":"t@e.com","subject":"Weekly digest","template_name":"digest.html","tenant_id":"tenant-body"}`)
+	req, _ := http.NewRequest(http.MethodPost, "/notifications/schedules", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Tenant-ID", "tenant-header")
+	rr := httptest.NewRecorder()
+
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	assert.Equal(t, "tenant-header", created.Job.TenantID)
+}
+
+func TestCreateSchedule_InvalidCron(t *testing.T) {
+	router := setupScheduleRouter(&MockScheduleStore{})
+
+	body := []byte(`{"cron":"every monday","recipient_id":"u1","recipient":"t@e.com","subject":"s","template_name":"tn"}`)
+	req, _ := http.NewRequest(http.MethodPost, "/notifications/schedules", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestGetSchedule_NotFound(t *testing.T) {
+	router := setupScheduleRouter(&MockScheduleStore{})
+
+	req, _ := http.NewRequest(http.MethodGet, "/notifications/schedules/missing", nil)
+	rr := httptest.NewRecorder()
+
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Lumina-Enterprise-Solutions/prism-notification-service/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// SuppressionHandler menyediakan endpoint admin untuk melihat, menambah dan
+// menghapus alamat di suppression list.
+type SuppressionHandler struct {
+	suppression service.SuppressionList
+}
+
+func NewSuppressionHandler(suppression service.SuppressionList) *SuppressionHandler {
+	return &SuppressionHandler{suppression: suppression}
+}
+
+type listSuppressionQuery struct {
+	Offset int `form:"offset" binding:"omitempty,min=0"`
+	Limit  int `form:"limit" binding:"omitempty,min=1"`
+}
+
+type AddSuppressionRequest struct {
+	Email  string `json:"email" binding:"required,email"`
+	Reason string `json:"reason"`
+}
+
+func (h *SuppressionHandler) ListSuppressions(c *gin.Context) {
+	var q listSuppressionQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if q.Limit == 0 {
+		q.Limit = defaultDLQPageSize
+	}
+	if q.Limit > maxDLQPageSize {
+		q.Limit = maxDLQPageSize
+	}
+
+	entries, total, err := h.suppression.List(c.Request.Context(), q.Offset, q.Limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read suppression list"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"entries": entries,
+		"total":   total,
+		"offset":  q.Offset,
+		"limit":   q.Limit,
+	})
+}
+
+func (h *SuppressionHandler) GetSuppression(c *gin.Context) {
+	entry, err := h.suppression.Get(c.Request.Context(), c.Param("email"))
+	if err != nil {
+		respondSuppressionError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, entry)
+}
+
+// AddSuppression menambahkan alamat secara manual, mis. atas permintaan
+// unsubscribe atau complaint dari provider.
+func (h *SuppressionHandler) AddSuppression(c *gin.Context) {
+	var req AddSuppressionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.suppression.Suppress(c.Request.Context(), req.Email, service.SuppressionSourceManual, req.Reason); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update suppression list"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// RemoveSuppression menghapus alamat agar email kembali dikirim, mis.
+// setelah mailbox penerima diperbaiki.
+func (h *SuppressionHandler) RemoveSuppression(c *gin.Context) {
+	if err := h.suppression.Remove(c.Request.Context(), c.Param("email")); err != nil {
+		respondSuppressionError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func respondSuppressionError(c *gin.Context, err error) {
+	if errors.Is(err, service.ErrSuppressionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Suppression not found"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process suppression"})
+}
@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Lumina-Enterprise-Solutions/prism-notification-service/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memorySuppressionList struct {
+	entries []service.Suppression
+}
+
+func (m *memorySuppressionList) Suppress(ctx context.Context, email, source, reason string) error {
+	m.entries = append(m.entries, service.Suppression{Email: strings.ToLower(email), Source: source, Reason: reason})
+	return nil
+}
+
+func (m *memorySuppressionList) IsSuppressed(ctx context.Context, email string) (bool, error) {
+	_, err := m.Get(ctx, email)
+	return err == nil, nil
+}
+
+func (m *memorySuppressionList) List(ctx context.Context, offset, limit int) ([]service.Suppression, int, error) {
+	end := min(offset+limit, len(m.entries))
+	if offset >= end {
+		return nil, len(m.entries), nil
+	}
+	return m.entries[offset:end], len(m.entries), nil
+}
+
+func (m *memorySuppressionList) Get(ctx context.Context, email string) (*service.Suppression, error) {
+	for i := range m.entries {
+		if m.entries[i].Email == strings.ToLower(email) {
+			return &m.entries[i], nil
+		}
+	}
+	return nil, service.ErrSuppressionNotFound
+}
+
+func (m *memorySuppressionList) Remove(ctx context.Context, email string) error {
+	for i := range m.entries {
+		if m.entries[i].Email == strings.ToLower(email) {
+			m.entries = append(m.entries[:i], m.entries[i+1:]...)
+			return nil
+		}
+	}
+	return service.ErrSuppressionNotFound
+}
+
+var _ service.SuppressionList = (*memorySuppressionList)(nil)
+
+func setupSuppressionRouter(s service.SuppressionList) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	h := NewSuppressionHandler(s)
+	routes := router.Group("/notifications/admin/suppressions")
+	routes.GET("", h.ListSuppressions)
+	routes.POST("", h.AddSuppression)
+	routes.GET("/:email", h.GetSuppression)
+	routes.DELETE("/:email", h.RemoveSuppression)
+	return router
+}
+
+func TestListSuppressions(t *testing.T) {
+	s := &memorySuppressionList{entries: []service.Suppression{
+		{Email: "ghost@example.com", Source: service.SuppressionSourceHardBounce, Reason: "550 5.1.1 mailbox does not exist"},
+	}}
+	router := setupSuppressionRouter(s)
+
+	req, _ := http.NewRequest(http.MethodGet, "/notifications/admin/suppressions", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	var body struct {
+		Entries []service.Suppression `json:"entries"`
+		Total   int                   `json:"total"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+	assert.Equal(t, 1, body.Total)
+	assert.Equal(t, "ghost@example.com", body.Entries[0].Email)
+}
+
+func TestAddAndRemoveSuppression(t *testing.T) {
+	s := &memorySuppressionList{}
+	router := setupSuppressionRouter(s)
+
+	req, _ := http.NewRequest(http.MethodPost, "/notifications/admin/suppressions", bytes.NewBufferString(`{"email":"Budi@example.com","reason":"unsubscribe"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusNoContent, rr.Code)
+	require.Len(t, s.entries, 1)
+	assert.Equal(t, service.SuppressionSourceManual, s.entries[0].Source)
+
+	req, _ = http.NewRequest(http.MethodDelete, "/notifications/admin/suppressions/budi@example.com", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	assert.Empty(t, s.entries)
+
+	req, _ = http.NewRequest(http.MethodGet, "/notifications/admin/suppressions/budi@example.com", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestAddSuppression_InvalidEmail(t *testing.T) {
+	router := setupSuppressionRouter(&memorySuppressionList{})
+
+	req, _ := http.NewRequest(http.MethodPost, "/notifications/admin/suppressions", bytes.NewBufferString(`{"email":"bukan-email"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
@@ -0,0 +1,315 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+)
+
+// NotificationTenantPendingPrefix + tenant menghitung job tenant yang sudah
+// diterima tetapi belum selesai (termasuk job terjadwal dan retry).
+const NotificationTenantPendingPrefix = "notification_tenant_pending:"
+
+// tenantPendingTTL memulihkan penghitung yang bocor (mis. payload yang
+// dikarantina tidak pernah di-Ack) setelah tenant tidak aktif.
+const tenantPendingTTL = 24 * time.Hour
+
+var ErrQueueSaturated = errors.New("queue saturated")
+
+// SaturationError dikembalikan Enqueue saat antrian melewati high-water mark.
+type SaturationError struct {
+	// Scope adalah "queue" (batas global) atau "tenant".
+	Scope      string
+	Tenant     string
+	Depth      int64
+	Limit      int64
+	RetryAfter time.Duration
+}
+
+func (e *SaturationError) Error() string {
+	if e.Scope == "tenant" {
+		return fmt.Sprintf("antrian tenant %s penuh (%d/%d)", e.Tenant, e.Depth, e.Limit)
+	}
+	return fmt.Sprintf("antrian penuh (%d/%d)", e.Depth, e.Limit)
+}
+
+func (e *SaturationError) Unwrap() error { return ErrQueueSaturated }
+
+// DepthReporter diimplementasikan backend yang bisa melaporkan jumlah job
+// yang menunggu diproses.
+type DepthReporter interface {
+	Depth(ctx context.Context) (int64, error)
+}
+
+var (
+	_ DepthReporter = (*QueueService)(nil)
+	_ DepthReporter = (*StreamQueueService)(nil)
+	_ DepthReporter = (*FairQueueService)(nil)
+	_ DepthReporter = (*PartitionedQueueService)(nil)
+	_ DepthReporter = (*ScheduledQueue)(nil)
+)
+
+// BackpressureLimits mengatur kapan job baru ditolak. Nilai nol berarti
+// batas tersebut tidak dipakai.
+type BackpressureLimits struct {
+	QueueHighWater  int64
+	TenantHighWater int64
+	// BulkShedPercent membuat job lane bulk ditolak lebih dulu, begitu
+	// kedalaman mencapai persentase ini dari high-water mark.
+	BulkShedPercent int
+	RetryAfter      time.Duration
+}
+
+// BackpressureQueue membungkus Queue dan menolak job baru saat antrian
+// jenuh. Job retry (Attempt > 0) selalu diterima karena sudah pernah lolos
+// pemeriksaan; menolaknya hanya akan membuat job hilang.
+type BackpressureQueue struct {
+	Queue
+	redisClient *redis.Client
+	limits      BackpressureLimits
+}
+
+var (
+	_ Queue             = (*BackpressureQueue)(nil)
+	_ ScheduleCanceller = (*BackpressureQueue)(nil)
+	_ AdmittedEnqueuer  = (*BackpressureQueue)(nil)
+)
+
+// AdmittedEnqueuer diimplementasikan wrapper yang menerapkan admission.
+// EnqueueAdmitted memasukkan job yang sudah pernah diterima (mis. replay
+// DLQ) tanpa memeriksa high-water mark.
+type AdmittedEnqueuer interface {
+	EnqueueAdmitted(ctx context.Context, job NotificationJob) error
+}
+
+func NewBackpressureQueue(redisClient *redis.Client, inner Queue, limits BackpressureLimits) *BackpressureQueue {
+	return &BackpressureQueue{Queue: inner, redisClient: redisClient, limits: limits}
+}
+
+func (q *BackpressureQueue) Enqueue(ctx context.Context, job NotificationJob) error {
+	if err := q.admit(ctx, []NotificationJob{job}); err != nil {
+		return err
+	}
+	if err := q.Queue.Enqueue(ctx, job); err != nil {
+		return err
+	}
+	q.countPending(ctx, []NotificationJob{job})
+	return nil
+}
+
+// EnqueueAdmitted melewati admission tetapi tetap menghitung job sebagai
+// job tenant yang tertunda. Slot job yang di-dead-letter sudah dilepas saat
+// worker meng-Ack-nya, sehingga replay harus mengambil slot baru agar Ack
+// berikutnya tidak membuat penghitung lebih kecil dari seharusnya.
+func (q *BackpressureQueue) EnqueueAdmitted(ctx context.Context, job NotificationJob) error {
+	if err := q.Queue.Enqueue(ctx, job); err != nil {
+		return err
+	}
+	q.countPending(ctx, []NotificationJob{job})
+	return nil
+}
+
+// EnqueueBatch menerima atau menolak batch secara utuh: job dihitung
+// berurutan terhadap high-water mark seolah di-enqueue satu per satu.
+// Backend Redis menulis batch dan penghitung tenant dalam satu MULTI/EXEC;
+// backend lain (mis. MemoryQueue) memakai EnqueueBatch-nya sendiri atau
+// Enqueue per job.
+func (q *BackpressureQueue) EnqueueBatch(ctx context.Context, jobs []NotificationJob) error {
+	if err := q.admit(ctx, jobs); err != nil {
+		return err
+	}
+	if _, ok := q.Queue.(pipelineEnqueuer); ok {
+		return enqueueBatch(ctx, q.redisClient, q, jobs)
+	}
+	if batcher, ok := q.Queue.(BatchEnqueuer); ok {
+		if err := batcher.EnqueueBatch(ctx, jobs); err != nil {
+			return err
+		}
+	} else {
+		for _, job := range jobs {
+			if err := q.Queue.Enqueue(ctx, job); err != nil {
+				return err
+			}
+		}
+	}
+	q.countPending(ctx, jobs)
+	return nil
+}
+
+// countPending menambah penghitung tenant untuk job yang sudah di-enqueue.
+func (q *BackpressureQueue) countPending(ctx context.Context, jobs []NotificationJob) {
+	if q.limits.TenantHighWater <= 0 {
+		return
+	}
+	_, err := q.redisClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i := range jobs {
+			key := NotificationTenantPendingPrefix + tenantOf(&jobs[i])
+			pipe.Incr(ctx, key)
+			pipe.Expire(ctx, key, tenantPendingTTL)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Warn().Err(err).Int("jobs", len(jobs)).Msg("Failed to count pending tenant jobs")
+	}
+}
+
+func (q *BackpressureQueue) enqueueTo(ctx context.Context, pipe redis.Pipeliner, job NotificationJob) error {
+	inner, err := innerEnqueuer(q.Queue)
+	if err != nil {
+		return err
+	}
+	if err := inner.enqueueTo(ctx, pipe, job); err != nil {
+		return err
+	}
+	if q.limits.TenantHighWater > 0 {
+		key := NotificationTenantPendingPrefix + tenantOf(&job)
+		pipe.Incr(ctx, key)
+		pipe.Expire(ctx, key, tenantPendingTTL)
+	}
+	return nil
+}
+
+// releasePendingScript mengurangi penghitung tenant tanpa membuatnya negatif.
+var releasePendingScript = redis.NewScript(`
+local n = redis.call('DECR', KEYS[1])
+if n <= 0 then
+	redis.call('DEL', KEYS[1])
+end
+return n
+`)
+
+func (q *BackpressureQueue) Ack(ctx context.Context, job *NotificationJob) error {
+	if err := q.Queue.Ack(ctx, job); err != nil {
+		return err
+	}
+	if q.limits.TenantHighWater > 0 {
+		key := NotificationTenantPendingPrefix + tenantOf(job)
+		if err := releasePendingScript.Run(ctx, q.redisClient, []string{key}).Err(); err != nil {
+			log.Warn().Err(err).Str("tenant", tenantOf(job)).Msg("Failed to release pending tenant job")
+		}
+	}
+	return nil
+}
+
+// Depth meneruskan kedalaman antrian dari backend di bawahnya.
+func (q *BackpressureQueue) Depth(ctx context.Context) (int64, error) {
+	return queueDepth(ctx, q.Queue)
+}
+
+// CancelScheduled meneruskan pembatalan ke backend di bawahnya dan
+// melepas slot tenant untuk job yang dihapus, karena job tersebut tidak
+// akan pernah di-Ack.
+func (q *BackpressureQueue) CancelScheduled(ctx context.Context, id string) ([]NotificationJob, error) {
+	canceller, ok := q.Queue.(ScheduleCanceller)
+	if !ok {
+		return nil, nil
+	}
+	removed, err := canceller.CancelScheduled(ctx, id)
+	if q.limits.TenantHighWater > 0 {
+		for i := range removed {
+			key := NotificationTenantPendingPrefix + tenantOf(&removed[i])
+			if err := releasePendingScript.Run(ctx, q.redisClient, []string{key}).Err(); err != nil {
+				log.Warn().Err(err).Str("tenant", tenantOf(&removed[i])).Msg("Failed to release pending tenant job")
+			}
+		}
+	}
+	return removed, err
+}
+
+// HoldForRetry meneruskan ke backend di bawahnya jika didukung. Job yang
+// ditahan belum selesai sehingga slot tenant-nya tidak dilepas.
+func (q *BackpressureQueue) HoldForRetry(ctx context.Context, job *NotificationJob, retry NotificationJob) (bool, error) {
+	holder, ok := q.Queue.(RetryHolder)
+	if !ok {
+		return false, nil
+	}
+	return holder.HoldForRetry(ctx, job, retry)
+}
+
+// admit memeriksa high-water mark global lalu per tenant untuk job baru
+// (Attempt == 0). Kegagalan membaca kedalaman tidak menolak job; Enqueue
+// sendiri akan gagal jika Redis down.
+func (q *BackpressureQueue) admit(ctx context.Context, jobs []NotificationJob) error {
+	queueDepthKnown := false
+	var depth int64
+	tenantDepths := map[string]int64{}
+	for i := range jobs {
+		job := &jobs[i]
+		if job.Attempt > 0 {
+			continue
+		}
+		if q.limits.QueueHighWater > 0 {
+			if !queueDepthKnown {
+				d, err := queueDepth(ctx, q.Queue)
+				if err != nil {
+					log.Warn().Err(err).Msg("Failed to read queue depth for backpressure")
+					d = -1
+				}
+				depth, queueDepthKnown = d, true
+			}
+			if depth >= 0 {
+				if err := q.check(job, "queue", "", depth, q.limits.QueueHighWater); err != nil {
+					return err
+				}
+				depth++
+			}
+		}
+		if q.limits.TenantHighWater > 0 {
+			tenant := tenantOf(job)
+			d, ok := tenantDepths[tenant]
+			if !ok {
+				d = q.tenantDepth(ctx, tenant)
+			}
+			if d >= 0 {
+				if err := q.check(job, "tenant", tenant, d, q.limits.TenantHighWater); err != nil {
+					return err
+				}
+				d++
+			}
+			tenantDepths[tenant] = d
+		}
+	}
+	return nil
+}
+
+// tenantDepth mengembalikan -1 jika penghitung tenant tidak bisa dibaca.
+func (q *BackpressureQueue) tenantDepth(ctx context.Context, tenant string) int64 {
+	depth, err := q.redisClient.Get(ctx, NotificationTenantPendingPrefix+tenant).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0
+	}
+	if err != nil {
+		log.Warn().Err(err).Str("tenant", tenant).Msg("Failed to read tenant depth for backpressure")
+		return -1
+	}
+	return depth
+}
+
+func (q *BackpressureQueue) check(job *NotificationJob, scope, tenant string, depth, limit int64) error {
+	priority, err := ParsePriority(job.Priority)
+	if err != nil {
+		priority = PriorityNormal
+	}
+	threshold := limit
+	if priority == PriorityBulk && q.limits.BulkShedPercent > 0 && q.limits.BulkShedPercent < 100 {
+		threshold = limit * int64(q.limits.BulkShedPercent) / 100
+	}
+	if depth < threshold {
+		return nil
+	}
+	backpressureRejected.WithLabelValues(scope, string(priority)).Inc()
+	return &SaturationError{Scope: scope, Tenant: tenant, Depth: depth, Limit: threshold, RetryAfter: q.limits.RetryAfter}
+}
+
+func queueDepth(ctx context.Context, queue Queue) (int64, error) {
+	reporter, ok := queue.(DepthReporter)
+	if !ok {
+		return 0, fmt.Errorf("backend antrian %T tidak mendukung pembacaan kedalaman", queue)
+	}
+	return reporter.Depth(ctx)
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redismock/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestBackpressureQueue(limits BackpressureLimits) (*BackpressureQueue, redismock.ClientMock) {
+	db, mock := redismock.NewClientMock()
+	return NewBackpressureQueue(db, NewQueueService(db), limits), mock
+}
+
+func expectLaneDepths(mock redismock.ClientMock, depths ...int64) {
+	for i, p := range Priorities {
+		mock.ExpectLLen(LaneKey(p)).SetVal(depths[i])
+	}
+}
+
+func TestBackpressure_RejectsWhenQueueAboveHighWater(t *testing.T) {
+	queue, mock := newTestBackpressureQueue(BackpressureLimits{QueueHighWater: 100, RetryAfter: 30 * time.Second})
+	expectLaneDepths(mock, 0, 10, 80, 10)
+
+	err := queue.Enqueue(context.Background(), NotificationJob{ID: "n-1"})
+	assert.ErrorIs(t, err, ErrQueueSaturated)
+	var saturated *SaturationError
+	require.ErrorAs(t, err, &saturated)
+	assert.Equal(t, "queue", saturated.Scope)
+	assert.Equal(t, int64(100), saturated.Depth)
+	assert.Equal(t, 30*time.Second, saturated.RetryAfter)
+	assert.NoError(t, mock.ExpectationsWereMet(), "job yang ditolak tidak boleh di-enqueue")
+}
+
+func TestBackpressure_ShedsBulkBeforeOtherLanes(t *testing.T) {
+	queue, mock := newTestBackpressureQueue(BackpressureLimits{QueueHighWater: 100, BulkShedPercent: 80})
+
+	expectLaneDepths(mock, 0, 0, 70, 15)
+	err := queue.Enqueue(context.Background(), NotificationJob{ID: "n-1", Priority: "bulk"})
+	var saturated *SaturationError
+	require.ErrorAs(t, err, &saturated)
+	assert.Equal(t, int64(80), saturated.Limit)
+
+	normal := NotificationJob{ID: "n-2"}
+	payload, err := json.Marshal(normal)
+	require.NoError(t, err)
+	expectLaneDepths(mock, 0, 0, 70, 15)
+	mock.ExpectLPush(NotificationQueueKey, payload).SetVal(86)
+	require.NoError(t, queue.Enqueue(context.Background(), normal))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBackpressure_TenantHighWater(t *testing.T) {
+	queue, mock := newTestBackpressureQueue(BackpressureLimits{TenantHighWater: 5})
+	key := NotificationTenantPendingPrefix + "tenant-a"
+
+	mock.ExpectGet(key).SetVal("5")
+	err := queue.Enqueue(context.Background(), NotificationJob{ID: "n-1", TenantID: "tenant-a"})
+	var saturated *SaturationError
+	require.ErrorAs(t, err, &saturated)
+	assert.Equal(t, "tenant", saturated.Scope)
+	assert.Equal(t, "tenant-a", saturated.Tenant)
+
+	job := NotificationJob{ID: "n-2", TenantID: "tenant-b"}
+	payload, err := json.Marshal(job)
+	require.NoError(t, err)
+	keyB := NotificationTenantPendingPrefix + "tenant-b"
+	mock.ExpectGet(keyB).RedisNil()
+	mock.ExpectLPush(NotificationQueueKey, payload).SetVal(1)
+	mock.ExpectIncr(keyB).SetVal(1)
+	mock.ExpectExpire(keyB, tenantPendingTTL).SetVal(true)
+	require.NoError(t, queue.Enqueue(context.Background(), job))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBackpressure_RetriesBypassLimits(t *testing.T) {
+	queue, mock := newTestBackpressureQueue(BackpressureLimits{QueueHighWater: 1})
+	retry := NotificationJob{ID: "n-1", Attempt: 1}
+	payload, err := json.Marshal(retry)
+	require.NoError(t, err)
+	mock.ExpectLPush(NotificationQueueKey, payload).SetVal(1)
+
+	require.NoError(t, queue.Enqueue(context.Background(), retry))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBackpressure_AckReleasesTenantSlot(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	inner := NewReliableQueueService(db, "worker-1", time.Minute)
+	queue := NewBackpressureQueue(db, inner, BackpressureLimits{TenantHighWater: 5})
+	job := &NotificationJob{TenantID: "tenant-a", receipt: `{"id":"n-1"}`}
+
+	mock.ExpectLRem(NotificationProcessingKeyPrefix+"worker-1", 1, job.receipt).SetVal(1)
+	mock.ExpectZRem(NotificationInflightKey, job.receipt).SetVal(1)
+	mock.ExpectEvalSha(releasePendingScript.Hash(), []string{NotificationTenantPendingPrefix + "tenant-a"}).SetVal(int64(0))
+
+	require.NoError(t, queue.Ack(context.Background(), job))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
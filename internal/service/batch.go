@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// BatchEnqueuer diimplementasikan Queue yang bisa meng-enqueue banyak job
+// dalam satu round-trip Redis.
+type BatchEnqueuer interface {
+	// EnqueueBatch meng-enqueue semua job atau tidak sama sekali.
+	EnqueueBatch(ctx context.Context, jobs []NotificationJob) error
+}
+
+var (
+	_ BatchEnqueuer = (*QueueService)(nil)
+	_ BatchEnqueuer = (*StreamQueueService)(nil)
+	_ BatchEnqueuer = (*FairQueueService)(nil)
+	_ BatchEnqueuer = (*PartitionedQueueService)(nil)
+	_ BatchEnqueuer = (*ScheduledQueue)(nil)
+	_ BatchEnqueuer = (*BackpressureQueue)(nil)
+)
+
+// pipelineEnqueuer menulis satu job ke pipeline milik caller. Wrapper seperti
+// ScheduledQueue meneruskannya ke Queue di bawahnya.
+type pipelineEnqueuer interface {
+	enqueueTo(ctx context.Context, pipe redis.Pipeliner, job NotificationJob) error
+}
+
+// enqueueBatch menulis semua job dalam satu MULTI/EXEC sehingga batch masuk
+// seluruhnya atau tidak sama sekali.
+func enqueueBatch(ctx context.Context, redisClient *redis.Client, q pipelineEnqueuer, jobs []NotificationJob) error {
+	_, err := redisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, job := range jobs {
+			if err := q.enqueueTo(ctx, pipe, job); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return err
+}
+
+// innerEnqueuer mengembalikan pipelineEnqueuer milik Queue yang dibungkus.
+func innerEnqueuer(queue Queue) (pipelineEnqueuer, error) {
+	inner, ok := queue.(pipelineEnqueuer)
+	if !ok {
+		return nil, fmt.Errorf("backend antrian %T tidak mendukung enqueue batch", queue)
+	}
+	return inner, nil
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redismock/v9"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func marshalJobs(t *testing.T, jobs ...NotificationJob) [][]byte {
+	t.Helper()
+	payloads := make([][]byte, len(jobs))
+	for i, job := range jobs {
+		payload, err := json.Marshal(job)
+		require.NoError(t, err)
+		payloads[i] = payload
+	}
+	return payloads
+}
+
+func TestEnqueueBatch_SingleTransaction(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	queueService := NewQueueService(db)
+	jobs := []NotificationJob{{ID: "n-1", Priority: "high"}, {ID: "n-2"}}
+	payloads := marshalJobs(t, jobs...)
+
+	mock.ExpectTxPipeline()
+	mock.ExpectLPush(LaneKey(PriorityHigh), payloads[0]).SetVal(1)
+	mock.ExpectLPush(NotificationQueueKey, payloads[1]).SetVal(1)
+	mock.ExpectTxPipelineExec()
+
+	require.NoError(t, queueService.EnqueueBatch(context.Background(), jobs))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestScheduledEnqueueBatch_ParksFutureJobs(t *testing.T) {
+	scheduled, mock, now := newTestScheduledQueue(t)
+	later := now.Add(time.Hour)
+	jobs := []NotificationJob{{ID: "n-1"}, {ID: "n-2", SendAt: &later}}
+	payloads := marshalJobs(t, jobs...)
+
+	mock.ExpectTxPipeline()
+	mock.ExpectLPush(NotificationQueueKey, payloads[0]).SetVal(1)
+	mock.ExpectZAdd(NotificationScheduledKey, redis.Z{Score: float64(later.UnixMilli()), Member: payloads[1]}).SetVal(1)
+	mock.ExpectHSet(NotificationScheduledIndexKey, "n-2", payloads[1]).SetVal(1)
+	mock.ExpectTxPipelineExec()
+
+	require.NoError(t, scheduled.EnqueueBatch(context.Background(), jobs))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFairEnqueueBatch_UsesEvalInsidePipeline(t *testing.T) {
+	queue, mock, _ := newTestFairQueue(t)
+	jobs := []NotificationJob{{ID: "n-1", TenantID: "tenant-a"}}
+	payloads := marshalJobs(t, jobs...)
+	keys := []string{NotificationTenantQueuePrefix + "tenant-a", NotificationTenantRingKey, NotificationTenantSignalKey}
+
+	mock.ExpectTxPipeline()
+	mock.ExpectEval(fairEnqueueLua, keys, "tenant-a", string(payloads[0]), "LPUSH").SetVal(int64(1))
+	mock.ExpectTxPipelineExec()
+
+	require.NoError(t, queue.EnqueueBatch(context.Background(), jobs))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBackpressureEnqueueBatch_CountsWholeBatch(t *testing.T) {
+	queue, mock := newTestBackpressureQueue(BackpressureLimits{QueueHighWater: 10})
+	jobs := make([]NotificationJob, 3)
+
+	// 8 job menunggu: job ke-3 dari batch akan menjadi job ke-11.
+	expectLaneDepths(mock, 0, 0, 8, 0)
+
+	err := queue.EnqueueBatch(context.Background(), jobs)
+	assert.ErrorIs(t, err, ErrQueueSaturated)
+	assert.NoError(t, mock.ExpectationsWereMet(), "batch yang ditolak tidak boleh di-enqueue sebagian")
+}
+
+func TestBackpressureEnqueueBatch_TracksTenantsInPipeline(t *testing.T) {
+	queue, mock := newTestBackpressureQueue(BackpressureLimits{TenantHighWater: 5})
+	jobs := []NotificationJob{{ID: "n-1", TenantID: "tenant-a"}, {ID: "n-2", TenantID: "tenant-a"}}
+	payloads := marshalJobs(t, jobs...)
+	key := NotificationTenantPendingPrefix + "tenant-a"
+
+	mock.ExpectGet(key).SetVal("3")
+	mock.ExpectTxPipeline()
+	for _, payload := range payloads {
+		mock.ExpectLPush(NotificationQueueKey, payload).SetVal(1)
+		mock.ExpectIncr(key).SetVal(1)
+		mock.ExpectExpire(key, tenantPendingTTL).SetVal(true)
+	}
+	mock.ExpectTxPipelineExec()
+
+	require.NoError(t, queue.EnqueueBatch(context.Background(), jobs))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBackpressureEnqueueBatch_MemoryBackend(t *testing.T) {
+	// Rangkaian yang sama dengan main.go untuk QUEUE_BACKEND=memory: tanpa
+	// batas tenant dan tanpa satu pun perintah Redis.
+	db, mock := redismock.NewClientMock()
+	memoryQueue := newTestMemoryQueue()
+	queue := NewBackpressureQueue(db, memoryQueue, BackpressureLimits{QueueHighWater: 10})
+	jobs := []NotificationJob{{ID: "n-1"}, {ID: "n-2", Priority: "critical"}}
+
+	require.NoError(t, queue.EnqueueBatch(context.Background(), jobs))
+	depth, err := memoryQueue.Depth(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), depth)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
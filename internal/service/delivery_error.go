@@ -0,0 +1,89 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"net/textproto"
+	"regexp"
+	"strings"
+)
+
+// ErrInvalidAddress menandai alamat pengirim atau penerima yang tidak bisa
+// di-parse. Mengirim ulang tidak akan mengubah hasilnya.
+var ErrInvalidAddress = errors.New("invalid email address")
+
+// DeliveryError adalah error EmailService.Send yang sudah diklasifikasikan.
+// Error permanen (alamat tidak ada, pesan ditolak, template rusak) tidak akan
+// berhasil jika diulang sehingga worker langsung memindahkannya ke DLQ.
+type DeliveryError struct {
+	Err       error
+	Permanent bool
+	// HardBounce berarti alamat penerima ditolak permanen oleh server
+	// tujuan; alamat tersebut dimasukkan ke suppression list.
+	HardBounce bool
+}
+
+func (e *DeliveryError) Error() string { return e.Err.Error() }
+
+func (e *DeliveryError) Unwrap() error { return e.Err }
+
+// IsPermanent melaporkan apakah err adalah DeliveryError permanen.
+func IsPermanent(err error) bool {
+	var deliveryErr *DeliveryError
+	return errors.As(err, &deliveryErr) && deliveryErr.Permanent
+}
+
+// IsHardBounce melaporkan apakah err adalah DeliveryError karena alamat
+// penerima ditolak permanen.
+func IsHardBounce(err error) bool {
+	var deliveryErr *DeliveryError
+	return errors.As(err, &deliveryErr) && deliveryErr.HardBounce
+}
+
+// enhancedStatus mencocokkan enhanced status code RFC 3463 (mis. "5.1.1") di
+// awal teks balasan SMTP.
+var enhancedStatus = regexp.MustCompile(`^([245])\.(\d{1,3})\.(\d{1,3})\b`)
+
+// classifyDeliveryError mengelompokkan error pengiriman. Error yang tidak
+// dikenal (jaringan, timeout, provider tidak sehat) dianggap sementara.
+func classifyDeliveryError(err error) *DeliveryError {
+	var smtpErr *textproto.Error
+	var providerErr *ProviderError
+	classified := &DeliveryError{Err: err}
+	switch {
+	case errors.Is(err, ErrTemplate), errors.Is(err, ErrInvalidAddress):
+		classified.Permanent = true
+	case errors.As(err, &smtpErr):
+		classified.Permanent, classified.HardBounce = classifySMTPReply(smtpErr)
+	case errors.As(err, &providerErr):
+		// 401/403/429/5xx adalah masalah provider atau kredensial yang bisa
+		// pulih; sisanya menolak isi request itu sendiri.
+		switch providerErr.StatusCode {
+		case http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity:
+			classified.Permanent = true
+		}
+	}
+	return classified
+}
+
+// classifySMTPReply membaca kode balasan SMTP. 4xx bersifat sementara;
+// 530/534/535 adalah masalah autentikasi relay yang pulih setelah kredensial
+// diperbaiki. 5xx lainnya permanen, dan menjadi hard bounce jika menolak
+// alamat penerima: enhanced status 5.1.x (alamat) atau 5.2.1 (mailbox
+// dinonaktifkan), atau kode 550/551/553 tanpa enhanced status.
+func classifySMTPReply(smtpErr *textproto.Error) (permanent, hardBounce bool) {
+	switch {
+	case smtpErr.Code < 500:
+		return false, false
+	case smtpErr.Code == 530, smtpErr.Code == 534, smtpErr.Code == 535:
+		return false, false
+	}
+	if m := enhancedStatus.FindStringSubmatch(strings.TrimSpace(smtpErr.Msg)); m != nil {
+		return true, m[2] == "1" || (m[2] == "2" && m[3] == "1")
+	}
+	switch smtpErr.Code {
+	case 550, 551, 553:
+		return true, true
+	}
+	return true, false
+}
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/textproto"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyDeliveryError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		permanent  bool
+		hardBounce bool
+	}{
+		{"mailbox not found", &textproto.Error{Code: 550, Msg: "5.1.1 mailbox does not exist"}, true, true},
+		{"mailbox disabled", &textproto.Error{Code: 550, Msg: "5.2.1 mailbox disabled"}, true, true},
+		{"no enhanced status", &textproto.Error{Code: 553, Msg: "mailbox name not allowed"}, true, true},
+		{"policy rejection", &textproto.Error{Code: 550, Msg: "5.7.1 rejected by policy"}, true, false},
+		{"mailbox full", &textproto.Error{Code: 552, Msg: "5.2.2 mailbox full"}, true, false},
+		{"message rejected", &textproto.Error{Code: 554, Msg: "transaction failed"}, true, false},
+		{"greylisted", &textproto.Error{Code: 451, Msg: "4.7.1 try again later"}, false, false},
+		{"auth failed", &textproto.Error{Code: 535, Msg: "5.7.8 bad credentials"}, false, false},
+		{"wrapped smtp", fmt.Errorf("send: %w", &textproto.Error{Code: 550, Msg: "5.1.1 unknown"}), true, true},
+		{"api bad request", &ProviderError{Provider: EmailProviderSendGrid, StatusCode: 400}, true, false},
+		{"api throttled", &ProviderError{Provider: EmailProviderSendGrid, StatusCode: 429}, false, false},
+		{"api unauthorized", &ProviderError{Provider: EmailProviderSES, StatusCode: 403}, false, false},
+		{"api unavailable", &ProviderError{Provider: EmailProviderSES, StatusCode: 503}, false, false},
+		{"template", fmt.Errorf("%w: missing", ErrTemplate), true, false},
+		{"invalid address", fmt.Errorf("%w: to", ErrInvalidAddress), true, false},
+		{"network", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, false, false},
+		{"no healthy provider", ErrNoHealthyProvider, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			classified := classifyDeliveryError(tt.err)
+			assert.Equal(t, tt.permanent, IsPermanent(classified))
+			assert.Equal(t, tt.hardBounce, IsHardBounce(classified))
+			assert.ErrorIs(t, classified, tt.err, "error asli tetap bisa di-unwrap")
+		})
+	}
+}
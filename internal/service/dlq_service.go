@@ -0,0 +1,353 @@
+package service
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+)
+
+var ErrDLQEntryNotFound = errors.New("dlq entry not found")
+
+// ErrDLQEntryUndecodable dikembalikan saat replay entri DLQ yang payload-nya
+// tidak bisa di-decode; entri seperti ini hanya bisa dihapus.
+var ErrDLQEntryUndecodable = errors.New("dlq entry cannot be decoded")
+
+// dlqScanChunk adalah ukuran batch LRANGE saat memindai DLQ.
+const dlqScanChunk = 500
+
+// DeliveryAttempt mencatat satu percobaan pengiriman yang gagal.
+type DeliveryAttempt struct {
+	Attempt int       `json:"attempt"`
+	At      time.Time `json:"at"`
+	Error   string    `json:"error"`
+}
+
+// DeliveryFailure merangkum mengapa sebuah job menyerah dan dipindah ke DLQ.
+type DeliveryFailure struct {
+	Reason         string            `json:"reason,omitempty"` // error terakhir
+	ErrorClass     string            `json:"error_class,omitempty"`
+	Permanent      bool              `json:"permanent,omitempty"` // tidak di-retry, lihat IsPermanent
+	Attempts       int               `json:"attempts,omitempty"`
+	AttemptHistory []DeliveryAttempt `json:"attempt_history,omitempty"`
+	WorkerHost     string            `json:"worker_host,omitempty"`
+	FailedAt       time.Time         `json:"failed_at"`
+}
+
+// DeadLetterEntry adalah envelope satu entri notification_dlq. ID diturunkan
+// dari isi payload sehingga stabil walaupun posisi entri di list bergeser.
+type DeadLetterEntry struct {
+	ID  string          `json:"id,omitempty"`
+	Job NotificationJob `json:"job"`
+	DeliveryFailure
+	EnqueuedAt *time.Time `json:"enqueued_at,omitempty"`
+	// Payload dan DecodeError hanya diisi untuk entri yang tidak bisa
+	// di-decode, lihat decodeDLQEntry.
+	Payload     string `json:"payload,omitempty"`
+	DecodeError string `json:"decode_error,omitempty"`
+
+	payload string
+}
+
+// Undecodable melaporkan apakah payload entri gagal di-decode.
+func (e *DeadLetterEntry) Undecodable() bool {
+	return e.DecodeError != ""
+}
+
+// DLQFilter memilih entri DLQ. Field kosong berarti tidak difilter.
+type DLQFilter struct {
+	TemplateName    string `form:"template_name"`
+	Recipient       string `form:"recipient"`
+	RecipientUserID string `form:"recipient_id"`
+	Reason          string `form:"reason"` // substring, case-insensitive
+}
+
+func (f DLQFilter) IsEmpty() bool {
+	return f == DLQFilter{}
+}
+
+func (f DLQFilter) Match(e *DeadLetterEntry) bool {
+	if f.TemplateName != "" && e.Job.TemplateName != f.TemplateName {
+		return false
+	}
+	if f.Recipient != "" && !strings.EqualFold(e.Job.To, f.Recipient) {
+		return false
+	}
+	if f.RecipientUserID != "" && e.Job.RecipientUserID != f.RecipientUserID {
+		return false
+	}
+	if f.Reason != "" && !strings.Contains(strings.ToLower(e.Reason), strings.ToLower(f.Reason)) {
+		return false
+	}
+	return true
+}
+
+type DeadLetterQueue interface {
+	List(ctx context.Context, filter DLQFilter, offset, limit int) ([]DeadLetterEntry, int, error)
+	Get(ctx context.Context, id string) (*DeadLetterEntry, error)
+	Replay(ctx context.Context, id string) error
+	ReplayMatching(ctx context.Context, filter DLQFilter) (int, error)
+	Delete(ctx context.Context, id string) error
+	Purge(ctx context.Context, filter DLQFilter) (int, error)
+}
+
+// DLQService membaca dan mengelola isi notification_dlq. Replay memakai
+// Queue sehingga job kembali melalui backend antrian yang aktif.
+type DLQService struct {
+	redisClient *redis.Client
+	queue       Queue
+}
+
+var _ DeadLetterQueue = (*DLQService)(nil)
+
+func NewDLQService(redisClient *redis.Client, queue Queue) *DLQService {
+	return &DLQService{redisClient: redisClient, queue: queue}
+}
+
+func payloadID(payload string) string {
+	sum := sha1.Sum([]byte(payload))
+	return hex.EncodeToString(sum[:8])
+}
+
+// decodeDeadLetter menerima envelope {"job": ...} maupun job polos
+// (format lama sebelum metadata kegagalan dicatat).
+func decodeDeadLetter(payload string) (*DeadLetterEntry, error) {
+	var probe struct {
+		Job json.RawMessage `json:"job"`
+	}
+	if err := json.Unmarshal([]byte(payload), &probe); err != nil {
+		return nil, err
+	}
+	entry := &DeadLetterEntry{}
+	rawJob := []byte(payload)
+	if probe.Job != nil {
+		if err := json.Unmarshal([]byte(payload), entry); err != nil {
+			return nil, err
+		}
+		rawJob = probe.Job
+	}
+	job, err := unmarshalJob(rawJob)
+	if err != nil {
+		return nil, err
+	}
+	entry.Job = job
+	entry.ID = payloadID(payload)
+	entry.payload = payload
+	return entry, nil
+}
+
+// decodeDLQEntry seperti decodeDeadLetter, tetapi payload yang rusak tetap
+// dikembalikan apa adanya beserta ID-nya supaya halaman List sesuai dengan
+// total dan entri tersebut masih bisa dihapus.
+func decodeDLQEntry(payload string) *DeadLetterEntry {
+	entry, err := decodeDeadLetter(payload)
+	if err != nil {
+		log.Warn().Err(err).Msg("Undecodable DLQ entry")
+		return &DeadLetterEntry{
+			ID:          payloadID(payload),
+			Payload:     payload,
+			DecodeError: err.Error(),
+			payload:     payload,
+		}
+	}
+	return entry
+}
+
+// scan memanggil fn untuk setiap entri DLQ (yang terbaru lebih dulu) hingga
+// fn mengembalikan false.
+func (s *DLQService) scan(ctx context.Context, fn func(*DeadLetterEntry) bool) error {
+	for start := int64(0); ; start += dlqScanChunk {
+		payloads, err := s.redisClient.LRange(ctx, NotificationDLQKey, start, start+dlqScanChunk-1).Result()
+		if err != nil {
+			return err
+		}
+		for _, payload := range payloads {
+			if !fn(decodeDLQEntry(payload)) {
+				return nil
+			}
+		}
+		if len(payloads) < dlqScanChunk {
+			return nil
+		}
+	}
+}
+
+func (s *DLQService) List(ctx context.Context, filter DLQFilter, offset, limit int) ([]DeadLetterEntry, int, error) {
+	entries := []DeadLetterEntry{}
+	if filter.IsEmpty() {
+		total, err := s.redisClient.LLen(ctx, NotificationDLQKey).Result()
+		if err != nil {
+			return nil, 0, err
+		}
+		payloads, err := s.redisClient.LRange(ctx, NotificationDLQKey, int64(offset), int64(offset+limit-1)).Result()
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, payload := range payloads {
+			entries = append(entries, *decodeDLQEntry(payload))
+		}
+		return entries, int(total), nil
+	}
+
+	total := 0
+	err := s.scan(ctx, func(e *DeadLetterEntry) bool {
+		if !filter.Match(e) {
+			return true
+		}
+		if total >= offset && len(entries) < limit {
+			entries = append(entries, *e)
+		}
+		total++
+		return true
+	})
+	return entries, total, err
+}
+
+func (s *DLQService) Get(ctx context.Context, id string) (*DeadLetterEntry, error) {
+	var found *DeadLetterEntry
+	err := s.scan(ctx, func(e *DeadLetterEntry) bool {
+		if e.ID == id {
+			found = e
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, ErrDLQEntryNotFound
+	}
+	return found, nil
+}
+
+func (s *DLQService) Replay(ctx context.Context, id string) error {
+	entry, err := s.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	return s.replay(ctx, entry)
+}
+
+// replay mengklaim entri dengan LREM (agar tidak di-replay dua kali oleh
+// admin lain) lalu memasukkannya kembali ke antrian.
+func (s *DLQService) replay(ctx context.Context, entry *DeadLetterEntry) error {
+	if entry.Undecodable() {
+		return ErrDLQEntryUndecodable
+	}
+	removed, err := s.redisClient.LRem(ctx, NotificationDLQKey, 1, entry.payload).Result()
+	if err != nil {
+		return err
+	}
+	if removed == 0 {
+		return ErrDLQEntryNotFound
+	}
+	// Replay memulai ulang siklus retry dari awal.
+	job := entry.Job
+	job.SendAt = nil
+	job.Attempt = 0
+	job.AttemptHistory = nil
+	if err := s.enqueue(ctx, job); err != nil {
+		if pushErr := s.redisClient.RPush(ctx, NotificationDLQKey, entry.payload).Err(); pushErr != nil {
+			log.Error().Err(pushErr).Str("dlq_id", entry.ID).Msg("Failed to return DLQ entry after replay error")
+		}
+		return fmt.Errorf("gagal replay entri DLQ %s: %w", entry.ID, err)
+	}
+	log.Info().Str("dlq_id", entry.ID).Str("recipient", job.To).Msg("Replayed DLQ entry")
+	return nil
+}
+
+// enqueue memasukkan job replay tanpa admission jika queue mendukungnya:
+// job ini sudah pernah diterima dan tidak boleh ditolak karena antrian jenuh.
+func (s *DLQService) enqueue(ctx context.Context, job NotificationJob) error {
+	if admitted, ok := s.queue.(AdmittedEnqueuer); ok {
+		return admitted.EnqueueAdmitted(ctx, job)
+	}
+	return s.queue.Enqueue(ctx, job)
+}
+
+func (s *DLQService) ReplayMatching(ctx context.Context, filter DLQFilter) (int, error) {
+	matched, err := s.collect(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	replayed := 0
+	for i := range matched {
+		if matched[i].Undecodable() {
+			continue // tidak ada job yang bisa dikirim ulang
+		}
+		err := s.replay(ctx, &matched[i])
+		if errors.Is(err, ErrDLQEntryNotFound) {
+			continue // sudah diproses admin lain
+		}
+		if err != nil {
+			return replayed, err
+		}
+		replayed++
+	}
+	return replayed, nil
+}
+
+func (s *DLQService) Delete(ctx context.Context, id string) error {
+	entry, err := s.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	removed, err := s.redisClient.LRem(ctx, NotificationDLQKey, 1, entry.payload).Result()
+	if err != nil {
+		return err
+	}
+	if removed == 0 {
+		return ErrDLQEntryNotFound
+	}
+	return nil
+}
+
+// Purge menghapus entri yang cocok dengan filter. Filter kosong menghapus
+// seluruh DLQ.
+func (s *DLQService) Purge(ctx context.Context, filter DLQFilter) (int, error) {
+	if filter.IsEmpty() {
+		var length *redis.IntCmd
+		_, err := s.redisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			length = pipe.LLen(ctx, NotificationDLQKey)
+			pipe.Del(ctx, NotificationDLQKey)
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+		return int(length.Val()), nil
+	}
+
+	matched, err := s.collect(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	purged := 0
+	for _, entry := range matched {
+		removed, err := s.redisClient.LRem(ctx, NotificationDLQKey, 1, entry.payload).Result()
+		if err != nil {
+			return purged, err
+		}
+		purged += int(removed)
+	}
+	return purged, nil
+}
+
+func (s *DLQService) collect(ctx context.Context, filter DLQFilter) ([]DeadLetterEntry, error) {
+	var matched []DeadLetterEntry
+	err := s.scan(ctx, func(e *DeadLetterEntry) bool {
+		if filter.Match(e) {
+			matched = append(matched, *e)
+		}
+		return true
+	})
+	return matched, err
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/go-redis/redismock/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeDeadLetter_AcceptsLegacyBareJob(t *testing.T) {
+	payload := `{"recipient_user_id":"user-1","to":"a@example.com","template_name":"welcome.html"}`
+
+	entry, err := decodeDeadLetter(payload)
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", entry.Job.RecipientUserID)
+	assert.Equal(t, payloadID(payload), entry.ID)
+	assert.Empty(t, entry.Reason)
+}
+
+func TestDLQList_Paged(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	dlq := NewDLQService(db, NewQueueService(db))
+	payload, err := json.Marshal(NotificationJob{RecipientUserID: "user-1", To: "a@example.com"})
+	require.NoError(t, err)
+
+	mock.ExpectLLen(NotificationDLQKey).SetVal(11)
+	mock.ExpectLRange(NotificationDLQKey, 10, 19).SetVal([]string{string(payload)})
+
+	entries, total, err := dlq.List(context.Background(), DLQFilter{}, 10, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 11, total)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "user-1", entries[0].Job.RecipientUserID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDLQList_KeepsUndecodableEntries(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	dlq := NewDLQService(db, NewQueueService(db))
+	payload, err := json.Marshal(NotificationJob{RecipientUserID: "user-1", To: "a@example.com"})
+	require.NoError(t, err)
+	broken := `{"job":{"to":`
+
+	mock.ExpectLLen(NotificationDLQKey).SetVal(2)
+	mock.ExpectLRange(NotificationDLQKey, 0, 9).SetVal([]string{broken, string(payload)})
+
+	entries, total, err := dlq.List(context.Background(), DLQFilter{}, 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 2, total)
+	require.Len(t, entries, 2)
+	assert.Equal(t, payloadID(broken), entries[0].ID)
+	assert.Equal(t, broken, entries[0].Payload)
+	assert.True(t, entries[0].Undecodable())
+	assert.False(t, entries[1].Undecodable())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDLQDelete_UndecodableEntry(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	dlq := NewDLQService(db, NewQueueService(db))
+	broken := `{"job":{"to":`
+
+	mock.ExpectLRange(NotificationDLQKey, 0, dlqScanChunk-1).SetVal([]string{broken})
+	mock.ExpectLRem(NotificationDLQKey, 1, broken).SetVal(1)
+
+	require.NoError(t, dlq.Delete(context.Background(), payloadID(broken)))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDLQReplay_RejectsUndecodableEntry(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	dlq := NewDLQService(db, NewQueueService(db))
+	broken := `{"job":{"to":`
+
+	mock.ExpectLRange(NotificationDLQKey, 0, dlqScanChunk-1).SetVal([]string{broken})
+
+	err := dlq.Replay(context.Background(), payloadID(broken))
+	assert.ErrorIs(t, err, ErrDLQEntryUndecodable)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDLQReplayMatching(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	dlq := NewDLQService(db, NewQueueService(db))
+	reset := NotificationJob{RecipientUserID: "user-1", TemplateName: "password_reset.html"}
+	welcome := NotificationJob{RecipientUserID: "user-2", TemplateName: "welcome.html"}
+	resetPayload, err := json.Marshal(reset)
+	require.NoError(t, err)
+	welcomePayload, err := json.Marshal(welcome)
+	require.NoError(t, err)
+
+	mock.ExpectLRange(NotificationDLQKey, 0, dlqScanChunk-1).SetVal([]string{string(resetPayload), string(welcomePayload)})
+	mock.ExpectLRem(NotificationDLQKey, 1, string(resetPayload)).SetVal(1)
+	mock.ExpectLPush(NotificationQueueKey, resetPayload).SetVal(1)
+
+	n, err := dlq.ReplayMatching(context.Background(), DLQFilter{TemplateName: "password_reset.html"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDLQReplay_CountsTenantSlotWithoutAdmission(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	// Antrian dan tenant sudah penuh, tetapi replay tidak boleh ditolak.
+	queue := NewBackpressureQueue(db, NewQueueService(db), BackpressureLimits{QueueHighWater: 1, TenantHighWater: 1})
+	dlq := NewDLQService(db, queue)
+	job := NotificationJob{ID: "n-1", TenantID: "tenant-a", TemplateName: "welcome.html", Attempt: 3}
+	payload, err := json.Marshal(job)
+	require.NoError(t, err)
+	job.Attempt = 0
+	replayed, err := json.Marshal(job)
+	require.NoError(t, err)
+	key := NotificationTenantPendingPrefix + "tenant-a"
+
+	// Entri rusak dilewati karena tidak ada job yang bisa dikirim ulang.
+	mock.ExpectLRange(NotificationDLQKey, 0, dlqScanChunk-1).SetVal([]string{`{"job":{"to":`, string(payload)})
+	mock.ExpectLRem(NotificationDLQKey, 1, string(payload)).SetVal(1)
+	mock.ExpectLPush(NotificationQueueKey, replayed).SetVal(1)
+	// Slot dilepas saat job di-dead-letter, jadi replay mengambil slot baru.
+	mock.ExpectIncr(key).SetVal(1)
+	mock.ExpectExpire(key, tenantPendingTTL).SetVal(true)
+
+	n, err := dlq.ReplayMatching(context.Background(), DLQFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDLQGet_NotFound(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	dlq := NewDLQService(db, NewQueueService(db))
+
+	mock.ExpectLRange(NotificationDLQKey, 0, dlqScanChunk-1).SetVal([]string{})
+
+	_, err := dlq.Get(context.Background(), "deadbeef")
+	assert.ErrorIs(t, err, ErrDLQEntryNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,422 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/mail"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/gomail.v2"
+)
+
+// Nama provider untuk konfigurasi EMAIL_PROVIDER.
+const (
+	EmailProviderSMTP     = "smtp"
+	EmailProviderSES      = "ses"
+	EmailProviderSendGrid = "sendgrid"
+)
+
+// EmailMessage adalah email yang sudah dirender dan siap dikirim provider.
+type EmailMessage struct {
+	From    string
+	To      string
+	Subject string
+	HTML    string
+	// Text adalah alternatif teks polos. Jika diisi, email dikirim sebagai
+	// multipart/alternative.
+	Text string
+}
+
+// EmailSender mengirim email yang sudah dirender melalui satu provider.
+type EmailSender interface {
+	SendEmail(ctx context.Context, msg EmailMessage) error
+	// Name mengembalikan nama provider untuk log dan metrik.
+	Name() string
+}
+
+// EmailProber diimplementasikan provider yang kesehatannya bisa diperiksa
+// tanpa mengirim email (lihat FailoverSender).
+type EmailProber interface {
+	Probe(ctx context.Context) error
+}
+
+var (
+	_ EmailSender = (*SMTPSender)(nil)
+	_ EmailSender = (*SESSender)(nil)
+	_ EmailSender = (*SendGridSender)(nil)
+	_ EmailSender = SimulatedSender{}
+
+	_ EmailProber = (*SMTPSender)(nil)
+	_ EmailProber = (*SESSender)(nil)
+	_ EmailProber = (*SendGridSender)(nil)
+)
+
+// ProviderError adalah respons non-2xx dari API HTTP provider email.
+type ProviderError struct {
+	Provider   string
+	StatusCode int
+	Body       string
+}
+
+func (e *ProviderError) Error() string {
+	return fmt.Sprintf("%s: HTTP %d: %s", e.Provider, e.StatusCode, e.Body)
+}
+
+// EmailProviderConfig berisi konfigurasi non-rahasia provider. Kredensial
+// dibaca dari env var yang dimuat dari Vault.
+type EmailProviderConfig struct {
+	Provider string
+	// SESRegion dan SESEndpoint dipakai provider "ses"; endpoint kosong berarti
+	// https://email.<region>.amazonaws.com.
+	SESRegion   string
+	SESEndpoint string
+	// SendGridEndpoint kosong berarti https://api.sendgrid.com.
+	SendGridEndpoint string
+	// SMTPPool mengatur pool koneksi provider "smtp".
+	SMTPPool SMTPPoolConfig
+}
+
+// NewEmailSenderFromEnv membuat EmailSender sesuai cfg.Provider. Jika
+// kredensial provider tidak diset, email hanya disimulasikan.
+func NewEmailSenderFromEnv(cfg EmailProviderConfig) (EmailSender, error) {
+	switch cfg.Provider {
+	case "", EmailProviderSMTP:
+		host := envOr("SMTP_HOST", "MAILTRAP_HOST")
+		if host == "" {
+			return simulated("SMTP"), nil
+		}
+		port, err := strconv.Atoi(envOr("SMTP_PORT", "MAILTRAP_PORT"))
+		if err != nil {
+			return nil, fmt.Errorf("port SMTP tidak valid: %w", err)
+		}
+		return NewSMTPSender(host, port, envOr("SMTP_USER", "MAILTRAP_USER"), envOr("SMTP_PASS", "MAILTRAP_PASS")).WithPool(cfg.SMTPPool), nil
+	case EmailProviderSES:
+		accessKey, secretKey := os.Getenv("SES_ACCESS_KEY_ID"), os.Getenv("SES_SECRET_ACCESS_KEY")
+		if accessKey == "" || secretKey == "" {
+			return simulated("SES"), nil
+		}
+		if cfg.SESRegion == "" {
+			return nil, fmt.Errorf("region SES wajib diisi")
+		}
+		return NewSESSender(cfg.SESEndpoint, cfg.SESRegion, accessKey, secretKey), nil
+	case EmailProviderSendGrid:
+		apiKey := os.Getenv("SENDGRID_API_KEY")
+		if apiKey == "" {
+			return simulated("SendGrid"), nil
+		}
+		return NewSendGridSender(cfg.SendGridEndpoint, apiKey), nil
+	default:
+		return nil, fmt.Errorf("provider email tidak dikenal: %q", cfg.Provider)
+	}
+}
+
+// envOr membaca env var pertama yang tidak kosong.
+func envOr(keys ...string) string {
+	for _, key := range keys {
+		if v := os.Getenv(key); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func simulated(provider string) SimulatedSender {
+	log.Printf("PERINGATAN: Kredensial %s tidak diset. Email akan disimulasikan (tidak terkirim).", provider)
+	return SimulatedSender{}
+}
+
+// SimulatedSender hanya mencatat email ke log; dipakai saat kredensial
+// provider tidak tersedia (pengembangan lokal dan pengujian).
+type SimulatedSender struct{}
+
+func (SimulatedSender) Name() string { return "simulated" }
+
+func (SimulatedSender) SendEmail(ctx context.Context, msg EmailMessage) error {
+	log.Printf("Mode Simulasi: Mengirim email '%s' ke %s", msg.Subject, msg.To)
+	return nil
+}
+
+// SMTPSender mengirim email melalui server SMTP apa pun (Mailtrap, Postfix,
+// relay internal). Koneksi dipakai ulang lewat pool agar handshake TCP, TLS
+// dan AUTH tidak diulang untuk setiap email.
+type SMTPSender struct {
+	dialer *gomail.Dialer
+	pool   *smtpPool
+}
+
+func NewSMTPSender(host string, port int, user, pass string) *SMTPSender {
+	s := &SMTPSender{dialer: gomail.NewDialer(host, port, user, pass)}
+	return s.WithPool(DefaultSMTPPoolConfig)
+}
+
+// WithPool mengganti konfigurasi pool. Dipanggil sebelum email pertama dikirim.
+func (s *SMTPSender) WithPool(config SMTPPoolConfig) *SMTPSender {
+	s.pool = newSMTPPool(s.dialer.Dial, config)
+	return s
+}
+
+func (s *SMTPSender) Name() string { return EmailProviderSMTP }
+
+func (s *SMTPSender) SendEmail(ctx context.Context, msg EmailMessage) error {
+	from, err := mail.ParseAddress(msg.From)
+	if err != nil {
+		return fmt.Errorf("%w: from %q: %v", ErrInvalidAddress, msg.From, err)
+	}
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return fmt.Errorf("%w: to %q: %v", ErrInvalidAddress, msg.To, err)
+	}
+	m := gomail.NewMessage()
+	m.SetHeader("From", msg.From)
+	m.SetHeader("To", msg.To)
+	m.SetHeader("Subject", msg.Subject)
+	if msg.Text != "" {
+		// Bagian terakhir adalah versi yang paling diutamakan (RFC 2046).
+		m.SetBody("text/plain", msg.Text)
+		m.AddAlternative("text/html", msg.HTML)
+	} else {
+		m.SetBody("text/html", msg.HTML)
+	}
+
+	// gomail.Send membungkus error dengan %v sehingga *textproto.Error (kode
+	// balasan SMTP) hilang; Send koneksi dipanggil langsung.
+	return s.pool.send(ctx, from.Address, []string{to.Address}, m)
+}
+
+// RunReaper menutup koneksi idle yang kedaluwarsa setiap interval hingga ctx
+// dibatalkan, lalu menutup seluruh pool. interval kosong berarti IdleTimeout.
+func (s *SMTPSender) RunReaper(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = s.pool.config.IdleTimeout
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			s.pool.Close()
+			return
+		case <-ticker.C:
+			s.pool.closeIdle()
+		}
+	}
+}
+
+// Probe membuka dan menutup koneksi SMTP (termasuk STARTTLS dan AUTH).
+func (s *SMTPSender) Probe(ctx context.Context) error {
+	conn, err := s.dialer.Dial()
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// postJSON mengirim body JSON dan mengubah respons non-2xx menjadi
+// ProviderError. sign boleh nil.
+func postJSON(ctx context.Context, client *http.Client, provider, url string, payload interface{}, sign func(*http.Request, []byte)) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return doRequest(ctx, client, provider, http.MethodPost, url, body, sign)
+}
+
+func doRequest(ctx context.Context, client *http.Client, provider, method, url string, body []byte, sign func(*http.Request, []byte)) error {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if sign != nil {
+		sign(req, body)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return &ProviderError{Provider: provider, StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(respBody))}
+}
+
+// SESSender mengirim email melalui API SES v2 (SendEmail) dengan request yang
+// ditandatangani AWS Signature Version 4.
+type SESSender struct {
+	endpoint  string
+	region    string
+	accessKey string
+	secretKey string
+	client    *http.Client
+	now       func() time.Time
+}
+
+func NewSESSender(endpoint, region, accessKey, secretKey string) *SESSender {
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://email.%s.amazonaws.com", region)
+	}
+	return &SESSender{
+		endpoint:  strings.TrimRight(endpoint, "/"),
+		region:    region,
+		accessKey: accessKey,
+		secretKey: secretKey,
+		client:    &http.Client{Timeout: 10 * time.Second},
+		now:       time.Now,
+	}
+}
+
+func (s *SESSender) Name() string { return EmailProviderSES }
+
+type sesContent struct {
+	Data    string `json:"Data"`
+	Charset string `json:"Charset"`
+}
+
+type sesSendEmailRequest struct {
+	FromEmailAddress string `json:"FromEmailAddress"`
+	Destination      struct {
+		ToAddresses []string `json:"ToAddresses"`
+	} `json:"Destination"`
+	Content struct {
+		Simple struct {
+			Subject sesContent `json:"Subject"`
+			Body    struct {
+				HTML sesContent  `json:"Html"`
+				Text *sesContent `json:"Text,omitempty"`
+			} `json:"Body"`
+		} `json:"Simple"`
+	} `json:"Content"`
+}
+
+func (s *SESSender) SendEmail(ctx context.Context, msg EmailMessage) error {
+	var payload sesSendEmailRequest
+	payload.FromEmailAddress = msg.From
+	payload.Destination.ToAddresses = []string{msg.To}
+	payload.Content.Simple.Subject = sesContent{Data: msg.Subject, Charset: "UTF-8"}
+	payload.Content.Simple.Body.HTML = sesContent{Data: msg.HTML, Charset: "UTF-8"}
+	if msg.Text != "" {
+		payload.Content.Simple.Body.Text = &sesContent{Data: msg.Text, Charset: "UTF-8"}
+	}
+	return postJSON(ctx, s.client, EmailProviderSES, s.endpoint+"/v2/email/outbound-emails", payload, s.sign)
+}
+
+// Probe memanggil GetAccount, yang sekaligus memeriksa kredensial.
+func (s *SESSender) Probe(ctx context.Context) error {
+	return doRequest(ctx, s.client, EmailProviderSES, http.MethodGet, s.endpoint+"/v2/email/account", nil, s.sign)
+}
+
+// sign menambahkan header Authorization SigV4 untuk service "ses".
+func (s *SESSender) sign(req *http.Request, body []byte) {
+	amzDate := s.now().UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	payloadHash := sha256Hex(body)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "content-type;host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := "content-type:" + req.Header.Get("Content-Type") + "\n" +
+		"host:" + req.URL.Host + "\n" +
+		"x-amz-content-sha256:" + payloadHash + "\n" +
+		"x-amz-date:" + amzDate + "\n"
+	canonicalRequest := strings.Join([]string{
+		req.Method, req.URL.EscapedPath(), req.URL.RawQuery, canonicalHeaders, signedHeaders, payloadHash,
+	}, "\n")
+	scope := date + "/" + s.region + "/ses/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), date)
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "ses")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, scope, signedHeaders, signature))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// SendGridSender mengirim email melalui API SendGrid v3 (mail/send).
+type SendGridSender struct {
+	endpoint string
+	apiKey   string
+	client   *http.Client
+}
+
+func NewSendGridSender(endpoint, apiKey string) *SendGridSender {
+	if endpoint == "" {
+		endpoint = "https://api.sendgrid.com"
+	}
+	return &SendGridSender{
+		endpoint: strings.TrimRight(endpoint, "/"),
+		apiKey:   apiKey,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (s *SendGridSender) Name() string { return EmailProviderSendGrid }
+
+type sendGridAddress struct {
+	Email string `json:"email"`
+}
+
+type sendGridContent struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type sendGridPersonalization struct {
+	To []sendGridAddress `json:"to"`
+}
+
+type sendGridMailRequest struct {
+	Personalizations []sendGridPersonalization `json:"personalizations"`
+	From             sendGridAddress           `json:"from"`
+	Subject          string                    `json:"subject"`
+	Content          []sendGridContent         `json:"content"`
+}
+
+func (s *SendGridSender) SendEmail(ctx context.Context, msg EmailMessage) error {
+	payload := sendGridMailRequest{
+		Personalizations: []sendGridPersonalization{{To: []sendGridAddress{{Email: msg.To}}}},
+		From:             sendGridAddress{Email: msg.From},
+		Subject:          msg.Subject,
+	}
+	// SendGrid mewajibkan text/plain berada sebelum text/html.
+	if msg.Text != "" {
+		payload.Content = append(payload.Content, sendGridContent{Type: "text/plain", Value: msg.Text})
+	}
+	payload.Content = append(payload.Content, sendGridContent{Type: "text/html", Value: msg.HTML})
+	return postJSON(ctx, s.client, EmailProviderSendGrid, s.endpoint+"/v3/mail/send", payload, s.authorize)
+}
+
+// Probe membaca scope API key, yang sekaligus memeriksa kredensial.
+func (s *SendGridSender) Probe(ctx context.Context) error {
+	return doRequest(ctx, s.client, EmailProviderSendGrid, http.MethodGet, s.endpoint+"/v3/scopes", nil, s.authorize)
+}
+
+func (s *SendGridSender) authorize(req *http.Request, _ []byte) {
+	req.Header.Set("Authorization", "Bearer "+s.apiKey)
+}
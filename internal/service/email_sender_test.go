@@ -0,0 +1,279 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testMessage = EmailMessage{From: "no-reply@prismerp.com", To: "budi@example.com", Subject: "Halo", HTML: "<p>hai</p>"}
+
+// smtpStandIn adalah server SMTP minimal untuk pengujian. Isi DATA yang
+// diterima dikirim ke data; conns menghitung koneksi yang diterima.
+type smtpStandIn struct {
+	host  string
+	port  int
+	data  chan string
+	conns atomic.Int32
+	// closeAfterData memutus koneksi setelah satu email diterima, meniru
+	// server yang menutup koneksi idle.
+	closeAfterData atomic.Bool
+}
+
+// startSMTPStandIn menjalankan smtpStandIn. rcptReply adalah balasan untuk
+// RCPT TO.
+func startSMTPStandIn(t *testing.T, rcptReply string) *smtpStandIn {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+	addr := ln.Addr().(*net.TCPAddr)
+	standIn := &smtpStandIn{host: addr.IP.String(), port: addr.Port, data: make(chan string, 16)}
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			standIn.conns.Add(1)
+			go standIn.serve(conn, rcptReply)
+		}
+	}()
+	return standIn
+}
+
+func (s *smtpStandIn) serve(conn net.Conn, rcptReply string) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { _, _ = io.WriteString(conn, line+"\r\n") }
+	reply("220 localhost ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"):
+			reply("250 localhost")
+		case strings.HasPrefix(cmd, "RCPT"):
+			reply(rcptReply)
+		case strings.HasPrefix(cmd, "DATA"):
+			reply("354 go ahead")
+			var body strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil || l == ".\r\n" {
+					break
+				}
+				body.WriteString(l)
+			}
+			s.data <- body.String()
+			reply("250 queued")
+			if s.closeAfterData.Load() {
+				return
+			}
+		case strings.HasPrefix(cmd, "QUIT"):
+			reply("221 bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func TestSMTPSender_SendsToServer(t *testing.T) {
+	standIn := startSMTPStandIn(t, "250 OK")
+	sender := NewSMTPSender(standIn.host, standIn.port, "", "")
+
+	require.NoError(t, sender.SendEmail(context.Background(), testMessage))
+
+	select {
+	case body := <-standIn.data:
+		assert.Contains(t, body, "To: budi@example.com")
+		assert.Contains(t, body, "Subject: Halo")
+		assert.Contains(t, body, "<p>hai</p>")
+	case <-time.After(2 * time.Second):
+		t.Fatal("server SMTP tidak menerima DATA")
+	}
+}
+
+func TestSMTPSender_SendsMultipartAlternative(t *testing.T) {
+	standIn := startSMTPStandIn(t, "250 OK")
+	sender := NewSMTPSender(standIn.host, standIn.port, "", "")
+	msg := testMessage
+	msg.Text = "hai"
+
+	require.NoError(t, sender.SendEmail(context.Background(), msg))
+
+	body := <-standIn.data
+	assert.Contains(t, body, "Content-Type: multipart/alternative")
+	plain, html := strings.Index(body, "Content-Type: text/plain"), strings.Index(body, "Content-Type: text/html")
+	require.True(t, plain >= 0 && html >= 0, body)
+	assert.Less(t, plain, html, "versi HTML (yang diutamakan) berada di bagian terakhir")
+}
+
+func TestSMTPSender_RejectedRecipientKeepsReplyCode(t *testing.T) {
+	standIn := startSMTPStandIn(t, "550 5.1.1 mailbox does not exist")
+	sender := NewSMTPSender(standIn.host, standIn.port, "", "")
+
+	err := sender.SendEmail(context.Background(), testMessage)
+
+	var smtpErr *textproto.Error
+	require.ErrorAs(t, err, &smtpErr)
+	assert.Equal(t, 550, smtpErr.Code)
+	assert.True(t, IsHardBounce(classifyDeliveryError(err)))
+}
+
+func TestSMTPSender_InvalidAddressIsPermanent(t *testing.T) {
+	sender := NewSMTPSender("127.0.0.1", 1, "", "")
+	msg := testMessage
+	msg.To = "bukan alamat"
+
+	err := sender.SendEmail(context.Background(), msg)
+	assert.ErrorIs(t, err, ErrInvalidAddress)
+	assert.True(t, IsPermanent(classifyDeliveryError(err)))
+}
+
+func TestSendGridSender(t *testing.T) {
+	var got sendGridMailRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v3/mail/send", r.URL.Path)
+		assert.Equal(t, "Bearer sg-key", r.Header.Get("Authorization"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	sender := NewSendGridSender(server.URL, "sg-key")
+	require.NoError(t, sender.SendEmail(context.Background(), testMessage))
+
+	require.Len(t, got.Personalizations, 1)
+	assert.Equal(t, "budi@example.com", got.Personalizations[0].To[0].Email)
+	assert.Equal(t, "no-reply@prismerp.com", got.From.Email)
+	assert.Equal(t, "Halo", got.Subject)
+	assert.Equal(t, []sendGridContent{{Type: "text/html", Value: "<p>hai</p>"}}, got.Content)
+}
+
+func TestSendGridSender_PlainTextComesFirst(t *testing.T) {
+	var got sendGridMailRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+	msg := testMessage
+	msg.Text = "hai"
+
+	require.NoError(t, NewSendGridSender(server.URL, "sg-key").SendEmail(context.Background(), msg))
+	assert.Equal(t, []sendGridContent{{Type: "text/plain", Value: "hai"}, {Type: "text/html", Value: "<p>hai</p>"}}, got.Content)
+}
+
+func TestSendGridSender_ErrorStatusReturnsProviderError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = io.WriteString(w, `{"errors":[{"message":"invalid api key"}]}`)
+	}))
+	defer server.Close()
+
+	err := NewSendGridSender(server.URL, "bad").SendEmail(context.Background(), testMessage)
+
+	var providerErr *ProviderError
+	require.ErrorAs(t, err, &providerErr)
+	assert.Equal(t, http.StatusUnauthorized, providerErr.StatusCode)
+	assert.Contains(t, providerErr.Body, "invalid api key")
+	assert.Equal(t, "sendgrid_4xx", ErrorClass(err))
+}
+
+func TestSESSender(t *testing.T) {
+	var got sesSendEmailRequest
+	var auth, amzDate string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v2/email/outbound-emails", r.URL.Path)
+		auth, amzDate = r.Header.Get("Authorization"), r.Header.Get("X-Amz-Date")
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		_, _ = io.WriteString(w, `{"MessageId":"m-1"}`)
+	}))
+	defer server.Close()
+
+	sender := NewSESSender(server.URL, "ap-southeast-1", "AKIDEXAMPLE", "secret")
+	sender.now = func() time.Time { return time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC) }
+	require.NoError(t, sender.SendEmail(context.Background(), testMessage))
+
+	assert.Equal(t, "20250106T090000Z", amzDate)
+	assert.True(t, strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20250106/ap-southeast-1/ses/aws4_request, "), auth)
+	assert.Contains(t, auth, "SignedHeaders=content-type;host;x-amz-content-sha256;x-amz-date, Signature=")
+	assert.Equal(t, "no-reply@prismerp.com", got.FromEmailAddress)
+	assert.Equal(t, []string{"budi@example.com"}, got.Destination.ToAddresses)
+	assert.Equal(t, "Halo", got.Content.Simple.Subject.Data)
+	assert.Equal(t, "<p>hai</p>", got.Content.Simple.Body.HTML.Data)
+	assert.Nil(t, got.Content.Simple.Body.Text)
+}
+
+func TestSESSender_IncludesPlainText(t *testing.T) {
+	var got sesSendEmailRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		_, _ = io.WriteString(w, `{"MessageId":"m-1"}`)
+	}))
+	defer server.Close()
+	msg := testMessage
+	msg.Text = "hai"
+
+	require.NoError(t, NewSESSender(server.URL, "ap-southeast-1", "AKIDEXAMPLE", "secret").SendEmail(context.Background(), msg))
+	require.NotNil(t, got.Content.Simple.Body.Text)
+	assert.Equal(t, "hai", got.Content.Simple.Body.Text.Data)
+}
+
+func TestSESSender_SignatureIsDeterministic(t *testing.T) {
+	sender := NewSESSender("https://email.ap-southeast-1.amazonaws.com", "ap-southeast-1", "AKIDEXAMPLE", "secret")
+	sender.now = func() time.Time { return time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC) }
+	sign := func(body string) string {
+		req := httptest.NewRequest(http.MethodPost, "https://email.ap-southeast-1.amazonaws.com/v2/email/outbound-emails", nil)
+		req.Header.Set("Content-Type", "application/json")
+		sender.sign(req, []byte(body))
+		return req.Header.Get("Authorization")
+	}
+
+	assert.Equal(t, sign(`{"a":1}`), sign(`{"a":1}`))
+	assert.NotEqual(t, sign(`{"a":1}`), sign(`{"a":2}`), "body ikut ditandatangani")
+}
+
+func TestNewEmailSenderFromEnv(t *testing.T) {
+	t.Setenv("SENDGRID_API_KEY", "sg-key")
+	t.Setenv("SES_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("SES_SECRET_ACCESS_KEY", "secret")
+
+	sender, err := NewEmailSenderFromEnv(EmailProviderConfig{Provider: EmailProviderSendGrid})
+	require.NoError(t, err)
+	assert.Equal(t, EmailProviderSendGrid, sender.Name())
+
+	sender, err = NewEmailSenderFromEnv(EmailProviderConfig{Provider: EmailProviderSES, SESRegion: "ap-southeast-1"})
+	require.NoError(t, err)
+	assert.Equal(t, EmailProviderSES, sender.Name())
+
+	_, err = NewEmailSenderFromEnv(EmailProviderConfig{Provider: EmailProviderSES})
+	assert.Error(t, err, "region SES wajib")
+
+	_, err = NewEmailSenderFromEnv(EmailProviderConfig{Provider: "carrier-pigeon"})
+	assert.Error(t, err)
+
+	t.Setenv("SMTP_HOST", "smtp.internal")
+	t.Setenv("SMTP_PORT", "587")
+	sender, err = NewEmailSenderFromEnv(EmailProviderConfig{Provider: EmailProviderSMTP})
+	require.NoError(t, err)
+	assert.Equal(t, EmailProviderSMTP, sender.Name())
+}
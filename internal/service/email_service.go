@@ -2,49 +2,79 @@ package service
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"html/template"
 	"log"
+	"net"
+	"net/textproto"
 	"os"
 	"path/filepath"
-	"strconv"
-
-	"gopkg.in/gomail.v2"
+	"strings"
+	texttemplate "text/template"
 )
 
-type EmailService struct {
-	dialer    *gomail.Dialer
-	templates *template.Template
+// ErrTemplate menandai kegagalan render template (bukan kegagalan SMTP).
+var ErrTemplate = errors.New("template error")
+
+// ErrorClass mengelompokkan error pengiriman untuk keperluan triase DLQ.
+func ErrorClass(err error) string {
+	var smtpErr *textproto.Error
+	var netErr net.Error
+	var providerErr *ProviderError
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrTemplate):
+		return "template"
+	case errors.As(err, &smtpErr):
+		return fmt.Sprintf("smtp_%dxx", smtpErr.Code/100)
+	case errors.As(err, &providerErr):
+		return fmt.Sprintf("%s_%dxx", providerErr.Provider, providerErr.StatusCode/100)
+	case errors.As(err, &netErr):
+		return "network"
+	default:
+		return "unknown"
+	}
 }
 
-func NewEmailService() *EmailService {
-	host := os.Getenv("MAILTRAP_HOST")
-	port, _ := strconv.Atoi(os.Getenv("MAILTRAP_PORT"))
-	user := os.Getenv("MAILTRAP_USER")
-	pass := os.Getenv("MAILTRAP_PASS")
+// DefaultEmailFrom adalah alamat pengirim jika EMAIL_FROM tidak diset.
+const DefaultEmailFrom = "no-reply@prismerp.com"
 
-	// Return mock/dummy service jika kredensial tidak ada.
-	if host == "" {
-		log.Println("PERINGATAN: Kredensial Mailtrap tidak diset. Email akan disimulasikan (tidak terkirim).")
-		// Tetap load template agar bisa diuji terpisah.
-		tpl, _ := loadTemplates()
-		return &EmailService{dialer: nil, templates: tpl}
-	}
+// EmailService merender template email lalu mengirimnya melalui EmailSender,
+// sehingga worker tidak bergantung pada provider tertentu. Setiap email
+// dikirim dengan alternatif teks polos: dari template <nama>.txt jika ada,
+// atau diturunkan dari HTML yang sudah dirender.
+type EmailService struct {
+	sender        EmailSender
+	from          string
+	templates     *template.Template
+	textTemplates *texttemplate.Template // opsional
+}
 
-	dialer := gomail.NewDialer(host, port, user, pass)
-	templates, err := loadTemplates()
+func NewEmailService(sender EmailSender, from string) *EmailService {
+	if from == "" {
+		from = DefaultEmailFrom
+	}
+	templates, textTemplates, err := loadTemplates()
 	if err != nil {
-		log.Fatalf("Gagal memuat template email: %v", err)
+		// Mode simulasi tetap berjalan tanpa template agar bisa diuji terpisah.
+		if _, simulated := sender.(SimulatedSender); !simulated {
+			log.Fatalf("Gagal memuat template email: %v", err)
+		}
 	}
-
 	return &EmailService{
-		dialer:    dialer,
-		templates: templates,
+		sender:        sender,
+		from:          from,
+		templates:     templates,
+		textTemplates: textTemplates,
 	}
 }
 
-// loadTemplates adalah helper untuk mencari dan mem-parse template.
-func loadTemplates() (*template.Template, error) {
+// loadTemplates adalah helper untuk mencari dan mem-parse template HTML
+// beserta template teks (*.txt) yang opsional.
+func loadTemplates() (*template.Template, *texttemplate.Template, error) {
 	// FIX: Cari direktori 'templates' dari path saat ini hingga ke atas.
 	// Ini membuat loading template lebih andal di berbagai lingkungan (dev, test, prod).
 	var templateDir string
@@ -58,35 +88,80 @@ func loadTemplates() (*template.Template, error) {
 	}
 
 	if templateDir == "" {
-		return nil, fmt.Errorf("direktori 'templates' tidak ditemukan")
+		return nil, nil, fmt.Errorf("direktori 'templates' tidak ditemukan")
 	}
 
 	log.Printf("Memuat template dari direktori: %s", templateDir)
-	return template.ParseGlob(filepath.Join(templateDir, "*.html"))
+	templates, err := template.ParseGlob(filepath.Join(templateDir, "*.html"))
+	if err != nil {
+		return nil, nil, err
+	}
+	textFiles, err := filepath.Glob(filepath.Join(templateDir, "*.txt"))
+	if err != nil || len(textFiles) == 0 {
+		return templates, nil, err
+	}
+	textTemplates, err := texttemplate.ParseFiles(textFiles...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return templates, textTemplates, nil
 }
 
+// Send merender template lalu mengirim email. Error yang dikembalikan selalu
+// *DeliveryError sehingga caller bisa membedakan kegagalan permanen dari
+// kegagalan sementara (lihat IsPermanent dan IsHardBounce).
 func (s *EmailService) Send(to, subject, templateName string, data interface{}) error {
-	if s.dialer == nil {
-		log.Printf("Mode Simulasi: Mengirim email '%s' ke %s", templateName, to)
+	if _, simulated := s.sender.(SimulatedSender); simulated && s.templates == nil {
+		if err := s.sender.SendEmail(context.Background(), EmailMessage{From: s.from, To: to, Subject: subject}); err != nil {
+			return classifyDeliveryError(err)
+		}
 		return nil
 	}
-
 	if s.templates == nil {
-		return fmt.Errorf("templates tidak diinisialisasi dengan benar")
+		// Template tidak akan muncul dengan sendirinya; retry hanya menunda DLQ.
+		return classifyDeliveryError(fmt.Errorf("%w: templates tidak diinisialisasi dengan benar", ErrTemplate))
 	}
 
+	if templateName == LegacyBodyTemplate {
+		data = legacyBodyData(data)
+	}
 	var body bytes.Buffer
 	err := s.templates.ExecuteTemplate(&body, templateName, data)
 	if err != nil {
-		return fmt.Errorf("gagal mengeksekusi template %s: %w", templateName, err)
+		return classifyDeliveryError(fmt.Errorf("%w: gagal mengeksekusi template %s: %w", ErrTemplate, templateName, err))
 	}
 
-	m := gomail.NewMessage()
-	m.SetHeader("From", "no-reply@prismerp.com")
-	m.SetHeader("To", to)
-	m.SetHeader("Subject", subject)
-	m.SetBody("text/html", body.String())
+	text, err := s.renderText(templateName, body.String(), data)
+	if err != nil {
+		return classifyDeliveryError(err)
+	}
 
-	log.Printf("Mengirim email dengan template '%s' ke %s...", templateName, to)
-	return s.dialer.DialAndSend(m)
+	log.Printf("Mengirim email dengan template '%s' ke %s via %s...", templateName, to, s.sender.Name())
+	err = s.sender.SendEmail(context.Background(), EmailMessage{
+		From:    s.from,
+		To:      to,
+		Subject: subject,
+		HTML:    body.String(),
+		Text:    text,
+	})
+	if err != nil {
+		return classifyDeliveryError(err)
+	}
+	return nil
+}
+
+// renderText merender template teks pasangan templateName (welcome.html ->
+// welcome.txt). Jika tidak ada, teks diturunkan dari HTML yang sudah dirender.
+func (s *EmailService) renderText(templateName, html string, data interface{}) (string, error) {
+	if s.textTemplates != nil {
+		name := strings.TrimSuffix(templateName, filepath.Ext(templateName)) + ".txt"
+		if tmpl := s.textTemplates.Lookup(name); tmpl != nil {
+			var text bytes.Buffer
+			if err := tmpl.Execute(&text, data); err != nil {
+				return "", fmt.Errorf("%w: gagal mengeksekusi template %s: %w", ErrTemplate, name, err)
+			}
+			return text.String(), nil
+		}
+	}
+	return htmlToText(html), nil
 }
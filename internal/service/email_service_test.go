@@ -1,6 +1,11 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/textproto"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -20,71 +25,159 @@ func TestNewEmailService_WithCredentials(t *testing.T) {
 	// ACT: Panggil fungsi yang ingin diuji.
 	// Kita perlu menggunakan require.NotPanics karena jika template tidak ditemukan,
 	// NewEmailService akan memanggil log.Fatalf yang menyebabkan panic.
-	// Untuk test ini, kita asumsikan template ada.
 	// Pastikan direktori 'templates' ada relatif terhadap root proyek saat menjalankan tes.
+	sender, err := NewEmailSenderFromEnv(EmailProviderConfig{Provider: EmailProviderSMTP})
+	require.NoError(t, err)
 	var service *EmailService
 	require.NotPanics(t, func() {
-		service = NewEmailService()
+		service = NewEmailService(sender, "")
 	}, "NewEmailService tidak seharusnya panic jika template ada")
 
 	// ASSERT: Verifikasi hasilnya.
 	require.NotNil(t, service, "Service tidak boleh nil")
-	assert.NotNil(t, service.dialer, "Dialer harus diinisialisasi saat kredensial ada")
+	assert.IsType(t, &SMTPSender{}, service.sender, "Sender SMTP harus dipakai saat kredensial ada")
+	assert.Equal(t, DefaultEmailFrom, service.from)
 	assert.NotNil(t, service.templates, "Templates harus di-load")
 }
 
 // TestNewEmailService_WithoutCredentials menguji fallback jika env var tidak ada.
 func TestNewEmailService_WithoutCredentials(t *testing.T) {
 	// ARRANGE: Pastikan tidak ada env var yang di-set (default state).
+	sender, err := NewEmailSenderFromEnv(EmailProviderConfig{})
+	require.NoError(t, err)
 
 	// ACT
 	var service *EmailService
 	require.NotPanics(t, func() {
-		service = NewEmailService()
+		service = NewEmailService(sender, "")
 	})
 
 	// ASSERT
 	require.NotNil(t, service)
-	assert.Nil(t, service.dialer, "Dialer seharusnya nil jika kredensial tidak ada")
+	assert.IsType(t, SimulatedSender{}, service.sender, "Email seharusnya disimulasikan jika kredensial tidak ada")
 }
 
 // TestEmailService_Send_TemplateNotFound menguji penanganan error jika template tidak ada.
 func TestEmailService_Send_TemplateNotFound(t *testing.T) {
 	// ARRANGE
-	// Inisialisasi service dengan dialer (agar tidak masuk ke mode simulasi)
-	t.Setenv("MAILTRAP_HOST", "host")
-	t.Setenv("MAILTRAP_PORT", "123")
-	t.Setenv("MAILTRAP_USER", "user")
-	t.Setenv("MAILTRAP_PASS", "pass")
-
+	// Inisialisasi service dengan sender SMTP (agar tidak masuk ke mode simulasi)
 	var service *EmailService
 	require.NotPanics(t, func() {
-		service = NewEmailService()
+		service = NewEmailService(NewSMTPSender("host", 123, "user", "pass"), "")
 	})
-	require.NotNil(t, service.dialer)
 
 	// ACT: Coba kirim email dengan nama template yang tidak ada.
 	err := service.Send("test@example.com", "Subjek", "template_tidak_ada.html", nil)
 
 	// ASSERT: Verifikasi bahwa kita mendapatkan error yang berhubungan dengan template.
 	assert.Error(t, err, "Fungsi Send seharusnya mengembalikan error")
+	assert.ErrorIs(t, err, ErrTemplate)
 	assert.Contains(t, err.Error(), "template_tidak_ada.html", "Pesan error harus menyebutkan nama template yang hilang")
 }
 
-// TestEmailService_Send_Simulated menguji mode simulasi saat dialer nil.
+// TestEmailService_Send_Simulated menguji mode simulasi tanpa kredensial.
 func TestEmailService_Send_Simulated(t *testing.T) {
 	// ARRANGE
-	// Buat service tanpa kredensial
 	var service *EmailService
 	require.NotPanics(t, func() {
-		service = NewEmailService()
+		service = NewEmailService(SimulatedSender{}, "")
 	})
-	require.Nil(t, service.dialer)
 
 	// ACT
-	// Karena dialer nil, fungsi ini seharusnya hanya mencetak log dan mengembalikan nil
+	// SimulatedSender hanya mencetak log dan mengembalikan nil
 	err := service.Send("test@example.com", "Subjek", "welcome.html", nil)
 
 	// ASSERT
 	assert.NoError(t, err, "Mode simulasi seharusnya tidak mengembalikan error")
 }
+
+// recordingSender menyimpan email terakhir yang dikirim.
+type recordingSender struct {
+	last EmailMessage
+	err  error
+}
+
+func (r *recordingSender) Name() string { return "recording" }
+
+func (r *recordingSender) SendEmail(ctx context.Context, msg EmailMessage) error {
+	r.last = msg
+	return r.err
+}
+
+func TestEmailService_Send_RendersTemplateForSender(t *testing.T) {
+	sender := &recordingSender{}
+	service := NewEmailService(sender, "alerts@prismerp.com")
+
+	err := service.Send("budi@example.com", "Halo", "legacy_body.html", map[string]interface{}{"body": "isi pesan"})
+	require.NoError(t, err)
+	assert.Equal(t, "alerts@prismerp.com", sender.last.From)
+	assert.Equal(t, "budi@example.com", sender.last.To)
+	assert.Equal(t, "Halo", sender.last.Subject)
+	assert.Contains(t, sender.last.HTML, "isi pesan")
+}
+
+func TestEmailService_Send_PlainTextAlternative(t *testing.T) {
+	sender := &recordingSender{}
+	service := NewEmailService(sender, "")
+
+	// password_reset.txt tersedia sehingga teksnya dipakai apa adanya.
+	data := map[string]interface{}{"FirstName": "Budi", "ResetLink": "https://app.prismerp.com/reset?t=a&b"}
+	require.NoError(t, service.Send("budi@example.com", "Reset", "password_reset.html", data))
+	assert.Contains(t, sender.last.Text, "Hello Budi,")
+	assert.Contains(t, sender.last.Text, "https://app.prismerp.com/reset?t=a&b", "template teks tidak di-escape seperti HTML")
+
+	// legacy_body.html tidak punya pasangan .txt; teks diturunkan dari HTML.
+	require.NoError(t, service.Send("budi@example.com", "Halo", "legacy_body.html", map[string]interface{}{"body": "<p>baris 1</p><p>baris 2</p>"}))
+	assert.Equal(t, "baris 1\n\nbaris 2", sender.last.Text)
+}
+
+func TestEmailService_Send_LegacyBodyIsRenderedAsHTML(t *testing.T) {
+	sender := &recordingSender{}
+	service := NewEmailService(sender, "")
+	// Payload versi 0 menyimpan HTML jadi di field body.
+	job, err := unmarshalJob([]byte(`{"to":"budi@example.com","subject":"Halo","body":"<p>Halo <b>Budi</b> &amp; tim</p>"}`))
+	require.NoError(t, err)
+
+	require.NoError(t, service.Send(job.To, job.Subject, job.TemplateName, job.TemplateData))
+	assert.Contains(t, sender.last.HTML, "<p>Halo <b>Budi</b> &amp; tim</p>")
+	assert.NotContains(t, sender.last.HTML, "&lt;")
+	assert.Equal(t, "Halo Budi & tim", sender.last.Text)
+}
+
+func TestEmailService_Send_ClassifiesSenderError(t *testing.T) {
+	bounce := &textproto.Error{Code: 550, Msg: "5.1.1 mailbox does not exist"}
+	service := NewEmailService(&recordingSender{err: bounce}, "")
+
+	err := service.Send("ghost@example.com", "Halo", "legacy_body.html", map[string]interface{}{"body": "isi"})
+
+	var deliveryErr *DeliveryError
+	require.ErrorAs(t, err, &deliveryErr)
+	assert.True(t, deliveryErr.Permanent)
+	assert.True(t, deliveryErr.HardBounce)
+	assert.ErrorIs(t, err, bounce)
+	assert.Equal(t, "smtp_5xx", ErrorClass(err))
+
+	err = service.Send("budi@example.com", "Halo", "tidak_ada.html", nil)
+	assert.True(t, IsPermanent(err), "template yang rusak tidak akan berhasil jika diulang")
+}
+
+func TestEmailService_Send_MissingTemplatesIsPermanent(t *testing.T) {
+	service := &EmailService{sender: &recordingSender{}}
+
+	err := service.Send("budi@example.com", "Halo", "welcome.html", nil)
+
+	var deliveryErr *DeliveryError
+	require.ErrorAs(t, err, &deliveryErr)
+	assert.True(t, deliveryErr.Permanent, "template yang tidak dimuat tidak akan pulih jika diulang")
+	assert.ErrorIs(t, err, ErrTemplate)
+}
+
+func TestErrorClass(t *testing.T) {
+	assert.Equal(t, "", ErrorClass(nil))
+	assert.Equal(t, "smtp_5xx", ErrorClass(&textproto.Error{Code: 550, Msg: "mailbox unavailable"}))
+	assert.Equal(t, "smtp_4xx", ErrorClass(fmt.Errorf("send: %w", &textproto.Error{Code: 421, Msg: "try later"})))
+	assert.Equal(t, "network", ErrorClass(&net.OpError{Op: "dial", Err: errors.New("connection refused")}))
+	assert.Equal(t, "template", ErrorClass(fmt.Errorf("%w: missing", ErrTemplate)))
+	assert.Equal(t, "sendgrid_4xx", ErrorClass(&ProviderError{Provider: EmailProviderSendGrid, StatusCode: 400}))
+	assert.Equal(t, "unknown", ErrorClass(errors.New("boom")))
+}
@@ -0,0 +1,205 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// htmlToText menurunkan versi teks polos dari email HTML yang sudah dirender.
+// Struktur blok (paragraf, heading, list, tabel) dipertahankan sebagai baris
+// baru, sedangkan link ditulis sebagai footnote "[n]" dengan daftar URL di
+// akhir teks.
+func htmlToText(body string) string {
+	doc, err := html.Parse(strings.NewReader(body))
+	if err != nil {
+		return ""
+	}
+	w := &textWriter{footnotes: map[string]int{}}
+	w.walk(doc)
+
+	text := strings.TrimSpace(blankLines.ReplaceAllString(w.b.String(), "\n\n"))
+	if len(w.links) > 0 {
+		var footer strings.Builder
+		for i, link := range w.links {
+			fmt.Fprintf(&footer, "\n[%d] %s", i+1, link)
+		}
+		text += "\n" + footer.String()
+	}
+	return text
+}
+
+var blankLines = regexp.MustCompile(`\n[ \t]*\n(?:[ \t]*\n)+`)
+
+// Elemen yang dipisahkan baris kosong dan elemen yang cukup diawali baris baru.
+var (
+	paragraphElements = map[atom.Atom]bool{
+		atom.P: true, atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+		atom.Table: true, atom.Ul: true, atom.Ol: true, atom.Blockquote: true, atom.Pre: true,
+	}
+	lineElements = map[atom.Atom]bool{
+		atom.Div: true, atom.Tr: true, atom.Li: true, atom.Section: true, atom.Header: true, atom.Footer: true,
+		atom.Article: true, atom.Dt: true, atom.Dd: true, atom.Center: true,
+	}
+	hiddenElements = map[atom.Atom]bool{
+		atom.Head: true, atom.Style: true, atom.Script: true, atom.Title: true, atom.Noscript: true, atom.Template: true,
+	}
+)
+
+type textWriter struct {
+	b strings.Builder
+	// space menandai spasi tertunda sebelum kata berikutnya pada baris yang sama.
+	space     bool
+	pre       int
+	links     []string
+	footnotes map[string]int
+}
+
+func (w *textWriter) walk(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		w.text(n.Data)
+		return
+	case html.ElementNode:
+		switch {
+		case hiddenElements[n.DataAtom]:
+			return
+		case n.DataAtom == atom.Br:
+			w.newlines(1)
+			return
+		case n.DataAtom == atom.Img:
+			w.text(attr(n, "alt"))
+			return
+		}
+	}
+
+	gap := 0
+	switch {
+	case paragraphElements[n.DataAtom]:
+		gap = 2
+	case lineElements[n.DataAtom]:
+		gap = 1
+	}
+	w.newlines(gap)
+	switch n.DataAtom {
+	case atom.Li:
+		w.word("-")
+		w.space = true
+	case atom.Td, atom.Th:
+		w.space = true
+	}
+	if n.DataAtom == atom.Pre || preservesWhitespace(attr(n, "style")) {
+		w.pre++
+		defer func() { w.pre-- }()
+	}
+
+	start := w.b.Len()
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		w.walk(c)
+	}
+	if n.DataAtom == atom.A {
+		w.footnote(attr(n, "href"), w.b.String()[start:])
+	}
+	if n.DataAtom == atom.Td || n.DataAtom == atom.Th {
+		w.space = true
+	}
+	w.newlines(gap)
+}
+
+func (w *textWriter) text(s string) {
+	if w.pre > 0 {
+		w.b.WriteString(s)
+		return
+	}
+	if s == "" {
+		return
+	}
+	if isHTMLSpace(s[0]) {
+		w.space = true
+	}
+	words := strings.Fields(s)
+	for i, word := range words {
+		if i > 0 {
+			w.space = true
+		}
+		w.word(word)
+	}
+	if len(words) > 0 && isHTMLSpace(s[len(s)-1]) {
+		w.space = true
+	}
+}
+
+func (w *textWriter) word(s string) {
+	if w.space && !w.atLineStart() {
+		w.b.WriteByte(' ')
+	}
+	w.space = false
+	w.b.WriteString(s)
+}
+
+func (w *textWriter) atLineStart() bool {
+	s := w.b.String()
+	return s == "" || s[len(s)-1] == '\n'
+}
+
+// newlines memastikan teks diakhiri minimal n baris baru.
+func (w *textWriter) newlines(n int) {
+	if n == 0 || w.b.Len() == 0 {
+		return
+	}
+	w.space = false
+	s := w.b.String()
+	for have := len(s) - len(strings.TrimRight(s, "\n")); have < n; have++ {
+		w.b.WriteByte('\n')
+	}
+}
+
+// footnote menambahkan penanda "[n]" setelah teks link. Link internal (#),
+// javascript: dan link yang teksnya sudah berisi alamat tujuan dilewati; URL
+// yang sama memakai nomor yang sama.
+func (w *textWriter) footnote(href, text string) {
+	href = strings.TrimSpace(href)
+	if href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(strings.ToLower(href), "javascript:") {
+		return
+	}
+	target := href
+	for _, scheme := range []string{"mailto:", "tel:"} {
+		if len(target) >= len(scheme) && strings.EqualFold(target[:len(scheme)], scheme) {
+			target = target[len(scheme):]
+		}
+	}
+	if strings.Contains(text, target) {
+		return
+	}
+	n, ok := w.footnotes[href]
+	if !ok {
+		w.links = append(w.links, href)
+		n = len(w.links)
+		w.footnotes[href] = n
+	}
+	w.space = true
+	w.word(fmt.Sprintf("[%d]", n))
+}
+
+// preservesWhitespace melaporkan apakah inline style memakai white-space
+// pre, pre-wrap atau pre-line.
+func preservesWhitespace(style string) bool {
+	style = strings.ReplaceAll(strings.ToLower(style), " ", "")
+	return strings.Contains(style, "white-space:pre")
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func isHTMLSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
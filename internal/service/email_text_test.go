@@ -0,0 +1,40 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTMLToText_KeepsStructure(t *testing.T) {
+	body := `<!DOCTYPE html><html><head><title>Judul</title><style>p { color: red; }</style></head>
+<body>
+  <h1>Halo   Budi</h1>
+  <p>Baris pertama<br>baris kedua</p>
+  <ul><li>Satu</li><li>Dua</li></ul>
+  <table><tr><td>Total</td><td>Rp 10.000</td></tr></table>
+  <img src="logo.png" alt="Prism ERP">
+</body></html>`
+
+	assert.Equal(t, "Halo Budi\n\nBaris pertama\nbaris kedua\n\n- Satu\n- Dua\n\nTotal Rp 10.000\n\nPrism ERP", htmlToText(body))
+}
+
+func TestHTMLToText_LinksBecomeFootnotes(t *testing.T) {
+	body := `<p>Klik <a href="https://app.prismerp.com/reset?t=abc">Reset Password</a> atau
+<a href="https://app.prismerp.com/reset?t=abc">tautan ini</a>.</p>
+<p><a href="#">Live Chat</a> <a href="mailto:support@prismerp.com">support@prismerp.com</a>
+<a href="mailto:support@prismerp.com">Email Support</a> <a href="https://prismerp.com">https://prismerp.com</a></p>`
+
+	assert.Equal(t, "Klik Reset Password [1] atau tautan ini [1].\n\n"+
+		"Live Chat support@prismerp.com Email Support [2] https://prismerp.com\n\n"+
+		"[1] https://app.prismerp.com/reset?t=abc\n"+
+		"[2] mailto:support@prismerp.com", htmlToText(body))
+}
+
+func TestHTMLToText_PreservesPreformattedText(t *testing.T) {
+	body := `<div style="white-space: pre-wrap;">baris 1
+baris 2</div><pre>  kode
+  kode</pre>`
+
+	assert.Equal(t, "baris 1\nbaris 2\n\n  kode\n  kode", htmlToText(body))
+}
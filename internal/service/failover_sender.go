@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ErrNoHealthyProvider dikembalikan FailoverSender saat circuit breaker
+// semua provider sedang terbuka.
+var ErrNoHealthyProvider = errors.New("no healthy email provider")
+
+// BreakerConfig mengatur circuit breaker per provider. Breaker terbuka setelah
+// FailureThreshold kegagalan berturut-turut dan tetap terbuka minimal Cooldown.
+type BreakerConfig struct {
+	FailureThreshold int
+	Cooldown         time.Duration
+	// ProbeTimeout membatasi satu probe kesehatan.
+	ProbeTimeout time.Duration
+}
+
+// providerState adalah status circuit breaker satu provider.
+type providerState struct {
+	sender   EmailSender
+	failures int
+	open     bool
+	// retryAt adalah waktu paling awal breaker yang terbuka boleh diperiksa
+	// lagi (oleh probe, atau oleh satu request jika provider tidak bisa di-probe).
+	retryAt time.Time
+}
+
+// FailoverSender mengirim email melalui rantai provider berurutan. Kegagalan
+// yang disebabkan provider (down, throttling, kredensial) membuat email
+// dicoba di provider berikutnya dan dihitung oleh circuit breaker provider
+// tersebut. Penolakan atas isi pesan atau alamat tujuan dikembalikan langsung
+// karena provider lain akan menolaknya juga.
+//
+// Breaker yang terbuka hanya ditutup kembali oleh probe yang berhasil (lihat
+// Run). Provider yang tidak mengimplementasikan EmailProber diberi satu
+// request percobaan setelah cooldown.
+type FailoverSender struct {
+	mu        sync.Mutex
+	providers []*providerState
+	config    BreakerConfig
+	now       func() time.Time
+}
+
+var _ EmailSender = (*FailoverSender)(nil)
+
+func NewFailoverSender(providers []EmailSender, config BreakerConfig) *FailoverSender {
+	if config.FailureThreshold < 1 {
+		config.FailureThreshold = 1
+	}
+	if config.ProbeTimeout <= 0 {
+		config.ProbeTimeout = 10 * time.Second
+	}
+	f := &FailoverSender{config: config, now: time.Now}
+	for _, sender := range providers {
+		f.providers = append(f.providers, &providerState{sender: sender})
+		emailProviderHealthy.WithLabelValues(sender.Name()).Set(1)
+	}
+	return f
+}
+
+func (f *FailoverSender) Name() string {
+	names := make([]string, 0, len(f.providers))
+	for _, p := range f.providers {
+		names = append(names, p.sender.Name())
+	}
+	return "failover(" + strings.Join(names, ",") + ")"
+}
+
+func (f *FailoverSender) SendEmail(ctx context.Context, msg EmailMessage) error {
+	var lastErr error
+	for _, p := range f.providers {
+		if !f.available(p) {
+			continue
+		}
+		err := p.sender.SendEmail(ctx, msg)
+		if err == nil || !providerFault(err) {
+			// Penolakan pesan berarti provider sendiri sehat.
+			f.recordSuccess(p)
+			return err
+		}
+		f.recordFailure(p, err)
+		emailProviderFailovers.WithLabelValues(p.sender.Name()).Inc()
+		log.Warn().Err(err).Str("provider", p.sender.Name()).Msg("Email provider failed, trying next provider")
+		lastErr = err
+	}
+	if lastErr == nil {
+		return ErrNoHealthyProvider
+	}
+	return lastErr
+}
+
+// available melaporkan apakah provider boleh menerima request. Untuk provider
+// tanpa probe, breaker yang cooldown-nya habis memberi satu request percobaan
+// dan cooldown diperpanjang sampai hasilnya dicatat.
+func (f *FailoverSender) available(p *providerState) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !p.open {
+		return true
+	}
+	if _, probed := p.sender.(EmailProber); probed {
+		return false
+	}
+	now := f.now()
+	if now.Before(p.retryAt) {
+		return false
+	}
+	p.retryAt = now.Add(f.config.Cooldown)
+	return true
+}
+
+func (f *FailoverSender) recordSuccess(p *providerState) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	p.failures = 0
+	if p.open {
+		p.open = false
+		emailProviderHealthy.WithLabelValues(p.sender.Name()).Set(1)
+		log.Info().Str("provider", p.sender.Name()).Msg("Email provider recovered, circuit closed")
+	}
+}
+
+func (f *FailoverSender) recordFailure(p *providerState, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	p.failures++
+	if p.open || p.failures < f.config.FailureThreshold {
+		return
+	}
+	p.open = true
+	p.retryAt = f.now().Add(f.config.Cooldown)
+	emailProviderHealthy.WithLabelValues(p.sender.Name()).Set(0)
+	log.Error().Err(err).Str("provider", p.sender.Name()).Int("failures", p.failures).Msg("Email provider unhealthy, circuit opened")
+}
+
+// ProbeUnhealthy mem-probe provider yang breaker-nya terbuka dan cooldown-nya
+// sudah habis. Provider yang lolos probe kembali menerima traffic.
+func (f *FailoverSender) ProbeUnhealthy(ctx context.Context) {
+	for _, p := range f.providers {
+		prober, ok := p.sender.(EmailProber)
+		if !ok {
+			continue
+		}
+		f.mu.Lock()
+		due := p.open && !f.now().Before(p.retryAt)
+		f.mu.Unlock()
+		if !due {
+			continue
+		}
+
+		probeCtx, cancel := context.WithTimeout(ctx, f.config.ProbeTimeout)
+		err := prober.Probe(probeCtx)
+		cancel()
+		if err == nil {
+			f.recordSuccess(p)
+			continue
+		}
+		f.mu.Lock()
+		p.retryAt = f.now().Add(f.config.Cooldown)
+		f.mu.Unlock()
+		log.Warn().Err(err).Str("provider", p.sender.Name()).Msg("Email provider probe failed")
+	}
+}
+
+// Run menjalankan ProbeUnhealthy secara periodik hingga ctx dibatalkan.
+func (f *FailoverSender) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			f.ProbeUnhealthy(ctx)
+		}
+	}
+}
+
+// providerFault melaporkan apakah err kemungkinan disebabkan provider
+// sehingga provider lain layak dicoba. Hanya penolakan permanen atas pesan
+// atau penerima yang tidak dicoba ulang di provider lain.
+func providerFault(err error) bool {
+	return !classifyDeliveryError(err).Permanent
+}
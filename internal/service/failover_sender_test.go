@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/textproto"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeProvider mengembalikan err untuk setiap pengiriman dan mencatat jumlahnya.
+type fakeProvider struct {
+	name  string
+	err   error
+	sends int
+}
+
+func (p *fakeProvider) Name() string { return p.name }
+
+func (p *fakeProvider) SendEmail(ctx context.Context, msg EmailMessage) error {
+	p.sends++
+	return p.err
+}
+
+// probedProvider adalah fakeProvider yang bisa di-probe.
+type probedProvider struct {
+	fakeProvider
+	probeErr error
+	probes   int
+}
+
+func (p *probedProvider) Probe(ctx context.Context) error {
+	p.probes++
+	return p.probeErr
+}
+
+var errRelayDown = &net.OpError{Op: "dial", Err: errors.New("connection refused")}
+
+func newTestFailover(providers ...EmailSender) (*FailoverSender, *time.Time) {
+	f := NewFailoverSender(providers, BreakerConfig{FailureThreshold: 2, Cooldown: 30 * time.Second})
+	now := time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC)
+	f.now = func() time.Time { return now }
+	return f, &now
+}
+
+func TestFailover_FallsBackOnProviderFault(t *testing.T) {
+	primary := &fakeProvider{name: "smtp", err: errRelayDown}
+	secondary := &fakeProvider{name: "sendgrid"}
+	f, _ := newTestFailover(primary, secondary)
+
+	require.NoError(t, f.SendEmail(context.Background(), testMessage))
+	assert.Equal(t, 1, primary.sends)
+	assert.Equal(t, 1, secondary.sends)
+}
+
+func TestFailover_MessageRejectionIsNotRetriedElsewhere(t *testing.T) {
+	rejected := &textproto.Error{Code: 550, Msg: "mailbox unavailable"}
+	primary := &fakeProvider{name: "smtp", err: rejected}
+	secondary := &fakeProvider{name: "sendgrid"}
+	f, _ := newTestFailover(primary, secondary)
+
+	err := f.SendEmail(context.Background(), testMessage)
+	assert.ErrorIs(t, err, rejected)
+	assert.Zero(t, secondary.sends, "penerima yang ditolak akan ditolak juga oleh provider lain")
+}
+
+func TestFailover_BreakerOpensAndProbeClosesIt(t *testing.T) {
+	primary := &probedProvider{fakeProvider: fakeProvider{name: "smtp", err: errRelayDown}, probeErr: errRelayDown}
+	secondary := &fakeProvider{name: "sendgrid"}
+	f, now := newTestFailover(primary, secondary)
+	ctx := context.Background()
+
+	require.NoError(t, f.SendEmail(ctx, testMessage))
+	require.NoError(t, f.SendEmail(ctx, testMessage))
+	require.Equal(t, 2, primary.sends)
+
+	// Breaker terbuka: primary dilewati tanpa dicoba.
+	require.NoError(t, f.SendEmail(ctx, testMessage))
+	assert.Equal(t, 2, primary.sends)
+	assert.Equal(t, 3, secondary.sends)
+
+	// Probe sebelum cooldown habis tidak dijalankan.
+	f.ProbeUnhealthy(ctx)
+	assert.Zero(t, primary.probes)
+
+	// Probe gagal menjaga breaker tetap terbuka.
+	*now = now.Add(31 * time.Second)
+	f.ProbeUnhealthy(ctx)
+	assert.Equal(t, 1, primary.probes)
+	require.NoError(t, f.SendEmail(ctx, testMessage))
+	assert.Equal(t, 2, primary.sends)
+
+	// Probe berhasil mengembalikan traffic ke primary.
+	primary.probeErr, primary.err = nil, nil
+	*now = now.Add(31 * time.Second)
+	f.ProbeUnhealthy(ctx)
+	require.NoError(t, f.SendEmail(ctx, testMessage))
+	assert.Equal(t, 3, primary.sends)
+	assert.Equal(t, 4, secondary.sends)
+}
+
+func TestFailover_UnprobedProviderGetsTrialAfterCooldown(t *testing.T) {
+	primary := &fakeProvider{name: "smtp", err: errRelayDown}
+	secondary := &fakeProvider{name: "sendgrid"}
+	f, now := newTestFailover(primary, secondary)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, f.SendEmail(ctx, testMessage))
+	}
+	assert.Equal(t, 2, primary.sends, "breaker terbuka setelah 2 kegagalan")
+
+	primary.err = nil
+	*now = now.Add(31 * time.Second)
+	require.NoError(t, f.SendEmail(ctx, testMessage))
+	assert.Equal(t, 3, primary.sends, "satu request percobaan setelah cooldown")
+	require.NoError(t, f.SendEmail(ctx, testMessage))
+	assert.Equal(t, 4, primary.sends, "breaker tertutup kembali")
+}
+
+func TestFailover_AllProvidersOpen(t *testing.T) {
+	primary := &probedProvider{fakeProvider: fakeProvider{name: "smtp", err: errRelayDown}}
+	f, _ := newTestFailover(primary)
+	ctx := context.Background()
+
+	assert.ErrorIs(t, f.SendEmail(ctx, testMessage), errRelayDown)
+	assert.ErrorIs(t, f.SendEmail(ctx, testMessage), errRelayDown)
+	assert.ErrorIs(t, f.SendEmail(ctx, testMessage), ErrNoHealthyProvider)
+}
+
+func TestProviderFault(t *testing.T) {
+	assert.True(t, providerFault(errRelayDown))
+	assert.True(t, providerFault(&textproto.Error{Code: 421, Msg: "try later"}))
+	assert.True(t, providerFault(&textproto.Error{Code: 535, Msg: "auth failed"}))
+	assert.False(t, providerFault(&textproto.Error{Code: 550, Msg: "no such user"}))
+	assert.True(t, providerFault(&ProviderError{Provider: EmailProviderSendGrid, StatusCode: 503}))
+	assert.True(t, providerFault(&ProviderError{Provider: EmailProviderSendGrid, StatusCode: 429}))
+	assert.False(t, providerFault(&ProviderError{Provider: EmailProviderSendGrid, StatusCode: 400}))
+}
@@ -0,0 +1,380 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// NotificationTenantQueuePrefix + tenant adalah sub-antrian milik tenant.
+	NotificationTenantQueuePrefix = "notification_tenant_queue:"
+	// NotificationTenantInflightPrefix + tenant adalah sorted set job in-flight
+	// tenant (score = deadline lease dalam ms).
+	NotificationTenantInflightPrefix = "notification_tenant_inflight:"
+	// NotificationTenantInflightTenantsKey berisi tenant yang mungkin masih
+	// memiliki job in-flight, untuk reaper.
+	NotificationTenantInflightTenantsKey = "notification_tenant_inflight_tenants"
+	// NotificationTenantRingKey berisi tenant yang sub-antriannya tidak kosong,
+	// dalam urutan round-robin.
+	NotificationTenantRingKey = "notification_tenant_ring"
+	// NotificationTenantCreditsKey menyimpan sisa jatah job tenant di giliran ini.
+	NotificationTenantCreditsKey = "notification_tenant_credits"
+	// NotificationTenantSignalKey membangunkan worker yang menunggu job baru.
+	NotificationTenantSignalKey = "notification_tenant_signal"
+
+	// DefaultTenantID dipakai untuk job tanpa tenant.
+	DefaultTenantID = "default"
+)
+
+// FairQueueService adalah implementasi Queue yang menyimpan job per tenant
+// dan melayani tenant secara round-robin berbobot, sehingga bulk import satu
+// tenant tidak membuat tenant lain kelaparan. Setiap giliran tenant boleh
+// mengambil sebanyak bobotnya sebelum pindah ke tenant berikutnya.
+//
+// maxInflight membatasi jumlah job satu tenant yang sedang diproses secara
+// bersamaan. Job in-flight dicatat dengan lease sehingga slot yang ditinggal
+// worker mati otomatis kembali setelah lease habis.
+type FairQueueService struct {
+	redisClient   *redis.Client
+	weights       map[string]int
+	defaultWeight int
+	maxInflight   int
+	lease         time.Duration
+	wait          time.Duration
+	now           func() time.Time
+}
+
+var _ Queue = (*FairQueueService)(nil)
+
+func NewFairQueueService(redisClient *redis.Client, weights map[string]int, maxInflight int, lease time.Duration) *FairQueueService {
+	if weights == nil {
+		weights = map[string]int{}
+	}
+	return &FairQueueService{
+		redisClient:   redisClient,
+		weights:       weights,
+		defaultWeight: 1,
+		maxInflight:   maxInflight,
+		lease:         lease,
+		wait:          time.Second,
+		now:           time.Now,
+	}
+}
+
+func tenantOf(job *NotificationJob) string {
+	if job.TenantID == "" {
+		return DefaultTenantID
+	}
+	return job.TenantID
+}
+
+// fairEnqueueScript menambahkan job ke sub-antrian tenant dan memasukkan
+// tenant ke ring jika sebelumnya kosong. Invarian: tenant ada di ring jika
+// dan hanya jika sub-antriannya berisi job.
+const fairEnqueueLua = `
+local length = redis.call(ARGV[3], KEYS[1], ARGV[2])
+if length == 1 then
+	redis.call('RPUSH', KEYS[2], ARGV[1])
+end
+redis.call('LPUSH', KEYS[3], '1')
+redis.call('LTRIM', KEYS[3], 0, 0)
+return length
+`
+
+var fairEnqueueScript = redis.NewScript(fairEnqueueLua)
+
+func (q *FairQueueService) Enqueue(ctx context.Context, job NotificationJob) error {
+	payload, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return q.push(ctx, tenantOf(&job), string(payload), "LPUSH")
+}
+
+func (q *FairQueueService) EnqueueBatch(ctx context.Context, jobs []NotificationJob) error {
+	return enqueueBatch(ctx, q.redisClient, q, jobs)
+}
+
+// enqueueTo memakai EVAL karena fallback NOSCRIPT dari Script.Run tidak
+// berlaku di dalam pipeline.
+func (q *FairQueueService) enqueueTo(ctx context.Context, pipe redis.Pipeliner, job NotificationJob) error {
+	payload, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	tenant := tenantOf(&job)
+	keys := []string{NotificationTenantQueuePrefix + tenant, NotificationTenantRingKey, NotificationTenantSignalKey}
+	fairEnqueueScript.Eval(ctx, pipe, keys, tenant, string(payload), "LPUSH")
+	return nil
+}
+
+// push memakai LPUSH untuk job baru (diambil paling akhir) dan RPUSH untuk
+// job yang dikembalikan via Nack (diambil paling dulu).
+func (q *FairQueueService) push(ctx context.Context, tenant, payload, cmd string) error {
+	keys := []string{NotificationTenantQueuePrefix + tenant, NotificationTenantRingKey, NotificationTenantSignalKey}
+	return fairEnqueueScript.Run(ctx, q.redisClient, keys, tenant, payload, cmd).Err()
+}
+
+// fairClaimScript memproses satu tenant di kepala ring (ARGV[1]). Lease
+// in-flight yang habis (worker mati) dikembalikan ke depan sub-antrian lebih
+// dulu. Tenant yang mencapai batas in-flight dilewati; tenant yang
+// sub-antriannya kosong dikeluarkan dari ring; tenant yang jatahnya habis
+// diputar ke ekor ring. Hasilnya {"job", payload} atau {"next", tenant di
+// kepala ring berikutnya}. Semua key dikirim lewat KEYS.
+var fairClaimScript = redis.NewScript(`
+local ring, credits_key, queue, inflight, inflight_tenants = KEYS[1], KEYS[2], KEYS[3], KEYS[4], KEYS[5]
+local tenant = ARGV[1]
+local head = redis.call('LINDEX', ring, 0)
+if head ~= tenant then
+	return {'next', head or ''}
+end
+local expired = redis.call('ZRANGEBYSCORE', inflight, '-inf', ARGV[2])
+if #expired > 0 then
+	for _, payload in ipairs(expired) do
+		redis.call('RPUSH', queue, payload)
+	end
+	redis.call('ZREM', inflight, unpack(expired))
+end
+local max_inflight = tonumber(ARGV[4])
+if max_inflight > 0 and redis.call('ZCARD', inflight) >= max_inflight then
+	redis.call('LMOVE', ring, ring, 'LEFT', 'RIGHT')
+	redis.call('HDEL', credits_key, tenant)
+	return {'next', redis.call('LINDEX', ring, 0) or ''}
+end
+local payload = redis.call('RPOP', queue)
+if not payload then
+	redis.call('LPOP', ring)
+	redis.call('HDEL', credits_key, tenant)
+	return {'next', redis.call('LINDEX', ring, 0) or ''}
+end
+redis.call('ZADD', inflight, ARGV[3], payload)
+redis.call('SADD', inflight_tenants, tenant)
+local credits = tonumber(redis.call('HGET', credits_key, tenant)) or tonumber(ARGV[5])
+credits = credits - 1
+if redis.call('LLEN', queue) == 0 then
+	redis.call('LPOP', ring)
+	redis.call('HDEL', credits_key, tenant)
+elseif credits <= 0 then
+	redis.call('LMOVE', ring, ring, 'LEFT', 'RIGHT')
+	redis.call('HDEL', credits_key, tenant)
+else
+	redis.call('HSET', credits_key, tenant, credits)
+end
+return {'job', payload}
+`)
+
+// Dequeue mengambil job dari tenant berikutnya. Jika belum ada job yang bisa
+// diambil, Dequeue menunggu sinyal enqueue (maksimal wait) lalu mencoba sekali
+// lagi; redis.Nil dikembalikan jika tetap kosong.
+func (q *FairQueueService) Dequeue(ctx context.Context) (*NotificationJob, error) {
+	job, err := q.claim(ctx)
+	if err != nil || job != nil {
+		return job, err
+	}
+	if err := q.redisClient.BRPop(ctx, q.wait, NotificationTenantSignalKey).Err(); err != nil {
+		return nil, err
+	}
+	job, err = q.claim(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if job == nil {
+		return nil, redis.Nil
+	}
+	return job, nil
+}
+
+// claim menjalankan fairClaimScript mulai dari kepala ring, paling banyak
+// sekali untuk setiap tenant di ring.
+func (q *FairQueueService) claim(ctx context.Context) (*NotificationJob, error) {
+	var head *redis.StringSliceCmd
+	var length *redis.IntCmd
+	_, err := q.redisClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		head = pipe.LRange(ctx, NotificationTenantRingKey, 0, 0)
+		length = pipe.LLen(ctx, NotificationTenantRingKey)
+		return nil
+	})
+	if err != nil || len(head.Val()) == 0 {
+		return nil, err
+	}
+	now := q.now()
+	tenant := head.Val()[0]
+	for i := int64(0); i < length.Val() && tenant != ""; i++ {
+		weight, ok := q.weights[tenant]
+		if !ok {
+			weight = q.defaultWeight
+		}
+		keys := []string{
+			NotificationTenantRingKey,
+			NotificationTenantCreditsKey,
+			NotificationTenantQueuePrefix + tenant,
+			NotificationTenantInflightPrefix + tenant,
+			NotificationTenantInflightTenantsKey,
+		}
+		result, err := fairClaimScript.Run(ctx, q.redisClient, keys,
+			tenant, now.UnixMilli(), now.Add(q.lease).UnixMilli(), q.maxInflight, weight).StringSlice()
+		if err != nil {
+			return nil, err
+		}
+		if len(result) != 2 {
+			return nil, fmt.Errorf("hasil klaim fair queue tidak valid")
+		}
+		if result[0] == "job" {
+			return q.decode(ctx, now, tenant, result[1])
+		}
+		tenant = result[1]
+	}
+	return nil, nil
+}
+
+func (q *FairQueueService) decode(ctx context.Context, now time.Time, tenant, payload string) (*NotificationJob, error) {
+	job, err := decodeJob(payload)
+	if errors.Is(err, ErrUnsupportedJobVersion) {
+		// Job dari worker yang lebih baru tetap in-flight; setelah lease habis
+		// ia dikembalikan ke sub-antrian tenant untuk diambil worker lain.
+		return nil, err
+	}
+	if err != nil {
+		err = quarantine(ctx, q.redisClient, now, QuarantineSourceFair, payload, err)
+		if errors.Is(err, ErrPoisonMessage) {
+			// Lepas slot in-flight agar tenant tidak tertahan sampai lease habis.
+			if relErr := q.redisClient.ZRem(ctx, NotificationTenantInflightPrefix+tenant, payload).Err(); relErr != nil {
+				log.Warn().Err(relErr).Str("tenant", tenant).Msg("Failed to release in-flight slot of quarantined payload")
+			}
+		}
+		return nil, err
+	}
+	return job, nil
+}
+
+// fairReapScript mengembalikan lease in-flight yang habis milik satu tenant
+// ke depan sub-antriannya dan memasukkan tenant ke ring jika sub-antriannya
+// sebelumnya kosong. Tenant tanpa job in-flight dihapus dari indeks.
+var fairReapScript = redis.NewScript(`
+local queue, inflight, ring, signal, inflight_tenants = KEYS[1], KEYS[2], KEYS[3], KEYS[4], KEYS[5]
+local expired = redis.call('ZRANGEBYSCORE', inflight, '-inf', ARGV[2])
+if #expired > 0 then
+	local before = redis.call('LLEN', queue)
+	for _, payload in ipairs(expired) do
+		redis.call('RPUSH', queue, payload)
+	end
+	redis.call('ZREM', inflight, unpack(expired))
+	if before == 0 then
+		redis.call('RPUSH', ring, ARGV[1])
+	end
+	redis.call('LPUSH', signal, '1')
+	redis.call('LTRIM', signal, 0, 0)
+end
+if redis.call('ZCARD', inflight) == 0 then
+	redis.call('SREM', inflight_tenants, ARGV[1])
+end
+return #expired
+`)
+
+// ReapExpired mengembalikan job in-flight yang lease-nya habis ke
+// sub-antrian tenant. Tenant yang masih di ring juga dipulihkan saat Dequeue;
+// reaper dibutuhkan untuk tenant yang sudah keluar dari ring karena
+// sub-antriannya kosong.
+func (q *FairQueueService) ReapExpired(ctx context.Context) (int64, error) {
+	tenants, err := q.redisClient.SMembers(ctx, NotificationTenantInflightTenantsKey).Result()
+	if err != nil {
+		return 0, err
+	}
+	now := q.now()
+	var total int64
+	for _, tenant := range tenants {
+		keys := []string{
+			NotificationTenantQueuePrefix + tenant,
+			NotificationTenantInflightPrefix + tenant,
+			NotificationTenantRingKey,
+			NotificationTenantSignalKey,
+			NotificationTenantInflightTenantsKey,
+		}
+		n, err := fairReapScript.Run(ctx, q.redisClient, keys, tenant, now.UnixMilli()).Int64()
+		if err != nil {
+			return total, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// RunReaper menjalankan ReapExpired secara periodik hingga ctx dibatalkan.
+func (q *FairQueueService) RunReaper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := q.ReapExpired(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Error().Err(err).Msg("Failed to reap expired tenant leases")
+				}
+				continue
+			}
+			if n > 0 {
+				log.Warn().Int64("count", n).Msg("Returned expired tenant jobs to their queues")
+			}
+		}
+	}
+}
+
+// Ack melepas slot in-flight tenant.
+func (q *FairQueueService) Ack(ctx context.Context, job *NotificationJob) error {
+	if job.receipt == "" {
+		return nil
+	}
+	return q.redisClient.ZRem(ctx, NotificationTenantInflightPrefix+tenantOf(job), job.receipt).Err()
+}
+
+// Nack mengembalikan job ke depan sub-antrian tenant dan melepas slotnya.
+func (q *FairQueueService) Nack(ctx context.Context, job *NotificationJob) error {
+	payload := job.receipt
+	if payload == "" {
+		raw, err := json.Marshal(job)
+		if err != nil {
+			return err
+		}
+		payload = string(raw)
+	}
+	if err := q.push(ctx, tenantOf(job), payload, "RPUSH"); err != nil {
+		return fmt.Errorf("gagal mengembalikan job tenant %s: %w", tenantOf(job), err)
+	}
+	return q.Ack(ctx, job)
+}
+
+// Depth menjumlahkan panjang sub-antrian semua tenant di ring.
+func (q *FairQueueService) Depth(ctx context.Context) (int64, error) {
+	tenants, err := q.redisClient.LRange(ctx, NotificationTenantRingKey, 0, -1).Result()
+	if err != nil || len(tenants) == 0 {
+		return 0, err
+	}
+	cmds := make([]*redis.IntCmd, 0, len(tenants))
+	_, err = q.redisClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, tenant := range tenants {
+			cmds = append(cmds, pipe.LLen(ctx, NotificationTenantQueuePrefix+tenant))
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, cmd := range cmds {
+		total += cmd.Val()
+	}
+	return total, nil
+}
+
+func (q *FairQueueService) EnqueueToDLQ(ctx context.Context, job NotificationJob, failure DeliveryFailure) error {
+	return enqueueToDLQ(ctx, q.redisClient, job, failure)
+}
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redismock/v9"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestFairQueue(t *testing.T) (*FairQueueService, redismock.ClientMock, time.Time) {
+	t.Helper()
+	db, mock := redismock.NewClientMock()
+	queue := NewFairQueueService(db, map[string]int{"tenant-big": 3}, 2, time.Minute)
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	queue.now = func() time.Time { return now }
+	return queue, mock, now
+}
+
+func TestFairEnqueue_PushesToTenantSubQueue(t *testing.T) {
+	queue, mock, _ := newTestFairQueue(t)
+	job := NotificationJob{ID: "n-1", TenantID: "tenant-a", To: "a@example.com"}
+	payload, err := json.Marshal(job)
+	require.NoError(t, err)
+
+	keys := []string{NotificationTenantQueuePrefix + "tenant-a", NotificationTenantRingKey, NotificationTenantSignalKey}
+	mock.ExpectEvalSha(fairEnqueueScript.Hash(), keys, "tenant-a", string(payload), "LPUSH").SetVal(int64(1))
+
+	require.NoError(t, queue.Enqueue(context.Background(), job))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFairEnqueue_JobWithoutTenantUsesDefault(t *testing.T) {
+	queue, mock, _ := newTestFairQueue(t)
+	job := NotificationJob{ID: "n-1"}
+	payload, err := json.Marshal(job)
+	require.NoError(t, err)
+
+	keys := []string{NotificationTenantQueuePrefix + DefaultTenantID, NotificationTenantRingKey, NotificationTenantSignalKey}
+	mock.ExpectEvalSha(fairEnqueueScript.Hash(), keys, DefaultTenantID, string(payload), "LPUSH").SetVal(int64(1))
+
+	require.NoError(t, queue.Enqueue(context.Background(), job))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func expectFairRing(mock redismock.ClientMock, head string, length int64) {
+	var ring []string
+	if head != "" {
+		ring = []string{head}
+	}
+	mock.ExpectLRange(NotificationTenantRingKey, 0, 0).SetVal(ring)
+	mock.ExpectLLen(NotificationTenantRingKey).SetVal(length)
+}
+
+func expectFairClaim(mock redismock.ClientMock, now time.Time, tenant string, weight int) *redismock.ExpectedCmd {
+	keys := []string{
+		NotificationTenantRingKey,
+		NotificationTenantCreditsKey,
+		NotificationTenantQueuePrefix + tenant,
+		NotificationTenantInflightPrefix + tenant,
+		NotificationTenantInflightTenantsKey,
+	}
+	return mock.ExpectEvalSha(fairClaimScript.Hash(), keys,
+		tenant, now.UnixMilli(), now.Add(time.Minute).UnixMilli(), 2, weight)
+}
+
+func TestFairDequeue_ClaimsNextTenantJob(t *testing.T) {
+	queue, mock, now := newTestFairQueue(t)
+	payload := `{"id":"n-1","tenant_id":"tenant-a","to":"a@example.com"}`
+	expectFairRing(mock, "tenant-a", 1)
+	expectFairClaim(mock, now, "tenant-a", 1).SetVal([]interface{}{"job", payload})
+
+	job, err := queue.Dequeue(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "tenant-a", job.TenantID)
+	assert.Equal(t, payload, job.receipt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFairDequeue_SkipsBusyTenantWithItsOwnKeys(t *testing.T) {
+	queue, mock, now := newTestFairQueue(t)
+	payload := `{"id":"n-2","tenant_id":"tenant-a"}`
+	expectFairRing(mock, "tenant-big", 2)
+	// tenant-big mencapai batas in-flight dan diputar ke ekor ring.
+	expectFairClaim(mock, now, "tenant-big", 3).SetVal([]interface{}{"next", "tenant-a"})
+	expectFairClaim(mock, now, "tenant-a", 1).SetVal([]interface{}{"job", payload})
+
+	job, err := queue.Dequeue(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "n-2", job.ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFairDequeue_QuarantinesUndecodablePayload(t *testing.T) {
+	queue, mock, now := newTestFairQueue(t)
+	payload := `{"id":`
+	expectFairRing(mock, "tenant-a", 1)
+	expectFairClaim(mock, now, "tenant-a", 1).SetVal([]interface{}{"job", payload})
+	expectQuarantine(t, mock, now, QuarantineSourceFair, payload)
+	mock.ExpectZRem(NotificationTenantInflightPrefix+"tenant-a", payload).SetVal(1)
+
+	_, err := queue.Dequeue(context.Background())
+	assert.ErrorIs(t, err, ErrPoisonMessage)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFairDequeue_KeepsNewerVersionJobInFlight(t *testing.T) {
+	queue, mock, now := newTestFairQueue(t)
+	payload := `{"version":99,"tenant_id":"tenant-a"}`
+	expectFairRing(mock, "tenant-a", 1)
+	expectFairClaim(mock, now, "tenant-a", 1).SetVal([]interface{}{"job", payload})
+
+	_, err := queue.Dequeue(context.Background())
+	assert.ErrorIs(t, err, ErrUnsupportedJobVersion)
+	assert.NoError(t, mock.ExpectationsWereMet(), "lease harus dibiarkan habis agar job kembali ke antrian")
+}
+
+func TestFairDequeue_WaitsForSignalWhenEmpty(t *testing.T) {
+	queue, mock, _ := newTestFairQueue(t)
+	expectFairRing(mock, "", 0)
+	mock.ExpectBRPop(time.Second, NotificationTenantSignalKey).SetVal([]string{NotificationTenantSignalKey, "1"})
+	expectFairRing(mock, "", 0)
+
+	_, err := queue.Dequeue(context.Background())
+	assert.ErrorIs(t, err, redis.Nil)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFairReapExpired_RequeuesExpiredLeases(t *testing.T) {
+	queue, mock, now := newTestFairQueue(t)
+	mock.ExpectSMembers(NotificationTenantInflightTenantsKey).SetVal([]string{"tenant-a", "tenant-b"})
+	for tenant, n := range map[string]int64{"tenant-a": 2, "tenant-b": 0} {
+		keys := []string{
+			NotificationTenantQueuePrefix + tenant,
+			NotificationTenantInflightPrefix + tenant,
+			NotificationTenantRingKey,
+			NotificationTenantSignalKey,
+			NotificationTenantInflightTenantsKey,
+		}
+		mock.ExpectEvalSha(fairReapScript.Hash(), keys, tenant, now.UnixMilli()).SetVal(n)
+	}
+	mock.MatchExpectationsInOrder(false)
+
+	n, err := queue.ReapExpired(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFairAck_ReleasesInflightSlot(t *testing.T) {
+	queue, mock, _ := newTestFairQueue(t)
+	job := &NotificationJob{TenantID: "tenant-a", receipt: `{"id":"n-1"}`}
+	mock.ExpectZRem(NotificationTenantInflightPrefix+"tenant-a", job.receipt).SetVal(1)
+
+	require.NoError(t, queue.Ack(context.Background(), job))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFairNack_ReturnsJobToFrontOfTenantQueue(t *testing.T) {
+	queue, mock, _ := newTestFairQueue(t)
+	job := &NotificationJob{TenantID: "tenant-a", receipt: `{"id":"n-1"}`}
+	keys := []string{NotificationTenantQueuePrefix + "tenant-a", NotificationTenantRingKey, NotificationTenantSignalKey}
+	mock.ExpectEvalSha(fairEnqueueScript.Hash(), keys, "tenant-a", job.receipt, "RPUSH").SetVal(int64(1))
+	mock.ExpectZRem(NotificationTenantInflightPrefix+"tenant-a", job.receipt).SetVal(1)
+
+	require.NoError(t, queue.Nack(context.Background(), job))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
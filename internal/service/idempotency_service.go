@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const NotificationIdempotencyKeyPrefix = "notification_idempotency:"
+
+// ErrIdempotencyKeyMismatch dikembalikan Reserve saat key sudah dipakai
+// tenant yang sama untuk permintaan dengan isi berbeda.
+var ErrIdempotencyKeyMismatch = errors.New("idempotency key reused with a different request")
+
+// IdempotencyStore menyimpan respons pertama untuk sebuah Idempotency-Key
+// sehingga permintaan ulang dari caller tidak membuat job duplikat. Key
+// berlaku per tenant.
+type IdempotencyStore interface {
+	// Reserve mencoba mengklaim key dengan respons yang akan dikirim. Jika key
+	// sudah ada untuk permintaan yang sama (requestHash sama), respons yang
+	// tersimpan dikembalikan dengan reserved=false; jika permintaannya
+	// berbeda, ErrIdempotencyKeyMismatch dikembalikan.
+	Reserve(ctx context.Context, tenant, key, requestHash string, response []byte) (existing []byte, reserved bool, err error)
+	// Release menghapus key, dipakai saat enqueue gagal agar caller bisa retry.
+	Release(ctx context.Context, tenant, key string) error
+}
+
+// idempotencyRecord adalah isi key idempotency di Redis.
+type idempotencyRecord struct {
+	RequestHash string          `json:"request_hash"`
+	Response    json.RawMessage `json:"response"`
+}
+
+type IdempotencyService struct {
+	redisClient *redis.Client
+	window      time.Duration
+}
+
+var _ IdempotencyStore = (*IdempotencyService)(nil)
+
+func NewIdempotencyService(redisClient *redis.Client, window time.Duration) *IdempotencyService {
+	return &IdempotencyService{redisClient: redisClient, window: window}
+}
+
+// idempotencyRedisKey memisahkan key per tenant sehingga tenant berbeda
+// boleh memakai Idempotency-Key yang sama.
+func idempotencyRedisKey(tenant, key string) string {
+	if tenant == "" {
+		tenant = DefaultTenantID
+	}
+	return NotificationIdempotencyKeyPrefix + tenant + ":" + key
+}
+
+func (s *IdempotencyService) Reserve(ctx context.Context, tenant, key, requestHash string, response []byte) ([]byte, bool, error) {
+	redisKey := idempotencyRedisKey(tenant, key)
+	record, err := json.Marshal(idempotencyRecord{RequestHash: requestHash, Response: response})
+	if err != nil {
+		return nil, false, err
+	}
+	reserved, err := s.redisClient.SetNX(ctx, redisKey, record, s.window).Result()
+	if err != nil {
+		return nil, false, err
+	}
+	if reserved {
+		return nil, true, nil
+	}
+	existing, err := s.redisClient.Get(ctx, redisKey).Bytes()
+	if errors.Is(err, redis.Nil) {
+		// Key kedaluwarsa di antara SETNX dan GET; coba klaim sekali lagi.
+		return s.Reserve(ctx, tenant, key, requestHash, response)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	var stored idempotencyRecord
+	if err := json.Unmarshal(existing, &stored); err != nil {
+		return nil, false, err
+	}
+	if stored.RequestHash != requestHash {
+		return nil, false, ErrIdempotencyKeyMismatch
+	}
+	return stored.Response, false, nil
+}
+
+func (s *IdempotencyService) Release(ctx context.Context, tenant, key string) error {
+	return s.redisClient.Del(ctx, idempotencyRedisKey(tenant, key)).Err()
+}
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redismock/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdempotencyReserve_NewKey(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	store := NewIdempotencyService(db, time.Hour)
+	response := []byte(`{"notification_id":"n-1"}`)
+
+	mock.ExpectSetNX(NotificationIdempotencyKeyPrefix+"tenant-a:key-1",
+		[]byte(`{"request_hash":"h-1","response":{"notification_id":"n-1"}}`), time.Hour).SetVal(true)
+
+	existing, reserved, err := store.Reserve(context.Background(), "tenant-a", "key-1", "h-1", response)
+	require.NoError(t, err)
+	assert.True(t, reserved)
+	assert.Nil(t, existing)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestIdempotencyReserve_ExistingKeyReturnsOriginalResponse(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	store := NewIdempotencyService(db, time.Hour)
+	key := NotificationIdempotencyKeyPrefix + DefaultTenantID + ":key-1"
+
+	mock.ExpectSetNX(key, []byte(`{"request_hash":"h-1","response":{"notification_id":"n-2"}}`), time.Hour).SetVal(false)
+	mock.ExpectGet(key).SetVal(`{"request_hash":"h-1","response":{"notification_id":"n-1"}}`)
+
+	existing, reserved, err := store.Reserve(context.Background(), "", "key-1", "h-1", []byte(`{"notification_id":"n-2"}`))
+	require.NoError(t, err)
+	assert.False(t, reserved)
+	assert.Equal(t, `{"notification_id":"n-1"}`, string(existing))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestIdempotencyReserve_DifferentRequestIsRejected(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	store := NewIdempotencyService(db, time.Hour)
+	key := NotificationIdempotencyKeyPrefix + "tenant-a:key-1"
+
+	mock.ExpectSetNX(key, []byte(`{"request_hash":"h-2","response":{"notification_id":"n-2"}}`), time.Hour).SetVal(false)
+	mock.ExpectGet(key).SetVal(`{"request_hash":"h-1","response":{"notification_id":"n-1"}}`)
+
+	_, reserved, err := store.Reserve(context.Background(), "tenant-a", "key-1", "h-2", []byte(`{"notification_id":"n-2"}`))
+	assert.ErrorIs(t, err, ErrIdempotencyKeyMismatch)
+	assert.False(t, reserved)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
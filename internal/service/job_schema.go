@@ -0,0 +1,143 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+)
+
+// JobSchemaVersion adalah versi skema NotificationJob yang ditulis service
+// ini. Naikkan nilainya setiap kali bentuk JSON job berubah dan tambahkan
+// migrasi dari versi sebelumnya ke jobMigrations.
+//
+// Riwayat versi:
+//   - 0: payload awal dengan field "body" berisi isi email.
+//   - 1: "body" diganti "template_name"/"template_data"; belum ada field "version".
+//   - 2: field "version" ditulis eksplisit.
+const JobSchemaVersion = 2
+
+// LegacyBodyTemplate merender isi "body" dari payload versi 0. Versi 0
+// mengirim body apa adanya sebagai text/html, jadi body dirender tanpa
+// escaping (lihat legacyBodyData). Template ini tidak boleh dipilih lewat API.
+const LegacyBodyTemplate = "legacy_body.html"
+
+// ErrUnsupportedJobVersion dikembalikan untuk payload yang ditulis versi
+// skema lebih baru, misalnya oleh worker baru selama rolling deploy. Payload
+// seperti ini bukan poison message: backend antrian membiarkannya di
+// tempatnya agar bisa diambil worker yang lebih baru.
+var ErrUnsupportedJobVersion = errors.New("unsupported job schema version")
+
+// jobMigrations[v] meng-upgrade field payload versi v ke versi v+1.
+var jobMigrations = map[int]func(fields map[string]json.RawMessage) error{
+	0: migrateJobV0,
+	1: func(fields map[string]json.RawMessage) error { return nil },
+}
+
+// migrateJobV0 memindahkan "body" ke template_data dan merendernya lewat
+// LegacyBodyTemplate.
+func migrateJobV0(fields map[string]json.RawMessage) error {
+	body, ok := fields["body"]
+	if !ok {
+		return nil
+	}
+	delete(fields, "body")
+	if _, ok := fields["template_name"]; ok {
+		return nil
+	}
+	var text string
+	if err := json.Unmarshal(body, &text); err != nil {
+		return fmt.Errorf("field body versi 0 tidak valid: %w", err)
+	}
+	templateName, err := json.Marshal(LegacyBodyTemplate)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(map[string]interface{}{"body": text})
+	if err != nil {
+		return err
+	}
+	fields["template_name"] = templateName
+	fields["template_data"] = data
+	return nil
+}
+
+// legacyBodyData menandai "body" sebagai HTML jadi agar html/template tidak
+// meloloskan tag-nya. Data lain dikembalikan apa adanya.
+func legacyBodyData(data interface{}) interface{} {
+	fields, ok := data.(map[string]interface{})
+	if !ok {
+		return data
+	}
+	body, ok := fields["body"].(string)
+	if !ok {
+		return data
+	}
+	out := make(map[string]interface{}, len(fields))
+	for k, v := range fields {
+		out[k] = v
+	}
+	out["body"] = template.HTML(body)
+	return out
+}
+
+// MarshalJSON selalu menulis job dengan JobSchemaVersion sehingga worker
+// versi lain tahu cara membacanya.
+func (j NotificationJob) MarshalJSON() ([]byte, error) {
+	type plain NotificationJob
+	out := plain(j)
+	out.Version = JobSchemaVersion
+	return json.Marshal(out)
+}
+
+// unmarshalJob men-decode payload job dari versi skema mana pun yang
+// didukung dan meng-upgrade-nya ke bentuk NotificationJob saat ini.
+// Payload dari versi yang lebih baru ditolak dengan ErrUnsupportedJobVersion.
+// Versi negatif tidak pernah ditulis producer mana pun dan dianggap rusak.
+func unmarshalJob(data []byte) (NotificationJob, error) {
+	var job NotificationJob
+	var probe struct {
+		Version *int            `json:"version"`
+		Body    json.RawMessage `json:"body"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return job, err
+	}
+
+	version := 1
+	switch {
+	case probe.Version != nil:
+		version = *probe.Version
+	case probe.Body != nil:
+		version = 0
+	}
+	if version < 0 {
+		return job, fmt.Errorf("versi skema job tidak valid: %d", version)
+	}
+	if version > JobSchemaVersion {
+		return job, fmt.Errorf("%w: %d", ErrUnsupportedJobVersion, version)
+	}
+
+	if version < JobSchemaVersion {
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return job, err
+		}
+		for v := version; v < JobSchemaVersion; v++ {
+			if err := jobMigrations[v](fields); err != nil {
+				return job, err
+			}
+		}
+		migrated, err := json.Marshal(fields)
+		if err != nil {
+			return job, err
+		}
+		data = migrated
+	}
+
+	if err := json.Unmarshal(data, &job); err != nil {
+		return job, err
+	}
+	job.Version = JobSchemaVersion
+	return job, nil
+}
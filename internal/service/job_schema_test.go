@@ -0,0 +1,70 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnmarshalJob_V0LegacyBody(t *testing.T) {
+	payload := `{"recipient_user_id":"user-1","to":"a@example.com","subject":"Hi","body":"Halo, selamat datang"}`
+
+	job, err := decodeJob(payload)
+	require.NoError(t, err)
+	assert.Equal(t, JobSchemaVersion, job.Version)
+	assert.Equal(t, "user-1", job.RecipientUserID)
+	assert.Equal(t, "Hi", job.Subject)
+	assert.Equal(t, LegacyBodyTemplate, job.TemplateName)
+	assert.Equal(t, map[string]interface{}{"body": "Halo, selamat datang"}, job.TemplateData)
+	assert.Equal(t, payload, job.receipt, "receipt tetap payload asli agar Ack cocok")
+}
+
+func TestUnmarshalJob_V0InvalidBody(t *testing.T) {
+	_, err := decodeJob(`{"to":"a@example.com","body":{"html":"<p>x</p>"}}`)
+	assert.Error(t, err)
+}
+
+func TestUnmarshalJob_V1WithoutVersion(t *testing.T) {
+	payload := `{"recipient_user_id":"user-1","to":"a@example.com","subject":"Hi","template_name":"welcome.html","template_data":{"Name":"Budi"}}`
+
+	job, err := decodeJob(payload)
+	require.NoError(t, err)
+	assert.Equal(t, JobSchemaVersion, job.Version)
+	assert.Equal(t, "welcome.html", job.TemplateName)
+	assert.Equal(t, "Budi", job.TemplateData["Name"])
+}
+
+func TestUnmarshalJob_CurrentVersion(t *testing.T) {
+	payload := `{"version":2,"id":"n-1","recipient_user_id":"user-1","to":"a@example.com","subject":"Hi","template_name":"welcome.html","template_data":null,"priority":"high","attempt":1}`
+
+	job, err := decodeJob(payload)
+	require.NoError(t, err)
+	assert.Equal(t, "n-1", job.ID)
+	assert.Equal(t, "high", job.Priority)
+	assert.Equal(t, 1, job.Attempt)
+}
+
+func TestUnmarshalJob_RejectsNewerVersion(t *testing.T) {
+	_, err := decodeJob(`{"version":99,"to":"a@example.com"}`)
+	assert.ErrorIs(t, err, ErrUnsupportedJobVersion)
+}
+
+func TestMarshalJob_StampsCurrentVersion(t *testing.T) {
+	payload, err := json.Marshal(NotificationJob{To: "a@example.com"})
+	require.NoError(t, err)
+
+	var fields map[string]interface{}
+	require.NoError(t, json.Unmarshal(payload, &fields))
+	assert.EqualValues(t, JobSchemaVersion, fields["version"])
+}
+
+func TestDecodeDeadLetter_MigratesLegacyJob(t *testing.T) {
+	payload := `{"job":{"to":"a@example.com","body":"Halo"},"reason":"smtp down"}`
+
+	entry, err := decodeDeadLetter(payload)
+	require.NoError(t, err)
+	assert.Equal(t, LegacyBodyTemplate, entry.Job.TemplateName)
+	assert.Equal(t, "smtp down", entry.Reason)
+}
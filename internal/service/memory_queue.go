@@ -0,0 +1,284 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+)
+
+// MemoryQueue adalah implementasi Queue di dalam proses untuk pengembangan
+// lokal dan pengujian, sehingga API, worker dan hub WebSocket bisa berjalan
+// tanpa Redis. Semantiknya mengikuti QueueService mode BRPOP: Dequeue
+// menunggu maksimal wait lalu mengembalikan redis.Nil, Ack tidak melakukan
+// apa-apa, Nack mengembalikan job ke depan lane-nya, dan lane prioritas
+// diperiksa secara strict.
+//
+// Job dengan SendAt di masa depan ditahan sampai jatuh tempo (menggantikan
+// ScheduledQueue), dan DLQ disimpan di memori sehingga MemoryQueue juga
+// memenuhi DeadLetterQueue. Semua isi hilang saat proses berhenti.
+type MemoryQueue struct {
+	mu sync.Mutex
+	// lanes menyimpan job per lane; indeks 0 diambil paling dulu.
+	lanes   map[Priority][]NotificationJob
+	delayed []NotificationJob // diurutkan berdasarkan SendAt
+	dlq     []DeadLetterEntry // terbaru lebih dulu
+	// changed ditutup dan diganti setiap ada job baru untuk membangunkan
+	// Dequeue yang sedang menunggu.
+	changed chan struct{}
+
+	wait time.Duration
+	now  func() time.Time
+}
+
+var (
+	_ Queue           = (*MemoryQueue)(nil)
+	_ DeadLetterQueue = (*MemoryQueue)(nil)
+	_ BatchEnqueuer   = (*MemoryQueue)(nil)
+	_ DepthReporter   = (*MemoryQueue)(nil)
+)
+
+func NewMemoryQueue() *MemoryQueue {
+	return &MemoryQueue{
+		lanes:   map[Priority][]NotificationJob{},
+		changed: make(chan struct{}),
+		wait:    5 * time.Second,
+		now:     time.Now,
+	}
+}
+
+func (q *MemoryQueue) Enqueue(ctx context.Context, job NotificationJob) error {
+	return q.EnqueueBatch(ctx, []NotificationJob{job})
+}
+
+func (q *MemoryQueue) EnqueueBatch(ctx context.Context, jobs []NotificationJob) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	now := q.now()
+	for _, job := range jobs {
+		job.receipt = ""
+		if job.SendAt != nil && job.SendAt.After(now) {
+			q.delayed = append(q.delayed, job)
+			continue
+		}
+		lane := memoryLane(&job)
+		q.lanes[lane] = append(q.lanes[lane], job)
+	}
+	sort.SliceStable(q.delayed, func(i, j int) bool { return q.delayed[i].SendAt.Before(*q.delayed[j].SendAt) })
+	q.signal()
+	return nil
+}
+
+// signal harus dipanggil dengan mu terkunci.
+func (q *MemoryQueue) signal() {
+	close(q.changed)
+	q.changed = make(chan struct{})
+}
+
+func memoryLane(job *NotificationJob) Priority {
+	p, err := ParsePriority(job.Priority)
+	if err != nil {
+		return PriorityNormal
+	}
+	return p
+}
+
+// Dequeue mengambil job dari lane tertinggi yang berisi job. Jika kosong,
+// Dequeue menunggu job baru atau job tertunda yang jatuh tempo.
+func (q *MemoryQueue) Dequeue(ctx context.Context) (*NotificationJob, error) {
+	deadline := q.now().Add(q.wait)
+	for {
+		q.mu.Lock()
+		now := q.now()
+		q.promoteDue(now)
+		if job, ok := q.pop(); ok {
+			q.mu.Unlock()
+			return job, nil
+		}
+		wait := deadline.Sub(now)
+		if len(q.delayed) > 0 {
+			wait = min(wait, q.delayed[0].SendAt.Sub(now))
+		}
+		changed := q.changed
+		q.mu.Unlock()
+
+		if !now.Before(deadline) {
+			return nil, redis.Nil
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-changed:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// promoteDue memindahkan job tertunda yang sudah jatuh tempo ke lane-nya.
+func (q *MemoryQueue) promoteDue(now time.Time) {
+	due := 0
+	for due < len(q.delayed) && !q.delayed[due].SendAt.After(now) {
+		job := q.delayed[due]
+		lane := memoryLane(&job)
+		q.lanes[lane] = append(q.lanes[lane], job)
+		due++
+	}
+	q.delayed = q.delayed[due:]
+}
+
+func (q *MemoryQueue) pop() (*NotificationJob, bool) {
+	for _, p := range Priorities {
+		if jobs := q.lanes[p]; len(jobs) > 0 {
+			job := jobs[0]
+			q.lanes[p] = jobs[1:]
+			return &job, true
+		}
+	}
+	return nil, false
+}
+
+// Ack tidak melakukan apa-apa: seperti mode BRPOP, job sudah keluar dari
+// antrian saat di-Dequeue.
+func (q *MemoryQueue) Ack(ctx context.Context, job *NotificationJob) error {
+	return nil
+}
+
+// Nack mengembalikan job ke depan lane-nya sehingga diambil paling dulu.
+func (q *MemoryQueue) Nack(ctx context.Context, job *NotificationJob) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	lane := memoryLane(job)
+	q.lanes[lane] = append([]NotificationJob{*job}, q.lanes[lane]...)
+	q.signal()
+	return nil
+}
+
+// Depth mengembalikan jumlah job yang siap diproses di semua lane.
+func (q *MemoryQueue) Depth(ctx context.Context) (int64, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var total int64
+	for _, jobs := range q.lanes {
+		total += int64(len(jobs))
+	}
+	return total, nil
+}
+
+func (q *MemoryQueue) EnqueueToDLQ(ctx context.Context, job NotificationJob, failure DeliveryFailure) error {
+	if failure.FailedAt.IsZero() {
+		failure.FailedAt = q.now().UTC()
+	}
+	entry := DeadLetterEntry{
+		Job:             job,
+		DeliveryFailure: failure,
+		EnqueuedAt:      job.EnqueuedAt,
+	}
+	payload, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal job for DLQ: %w", err)
+	}
+	entry.ID = payloadID(string(payload))
+	log.Warn().Str("recipient", job.To).Str("error_class", failure.ErrorClass).Msg("Moving job to Dead-Letter Queue")
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.dlq = append([]DeadLetterEntry{entry}, q.dlq...)
+	return nil
+}
+
+func (q *MemoryQueue) List(ctx context.Context, filter DLQFilter, offset, limit int) ([]DeadLetterEntry, int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	entries := []DeadLetterEntry{}
+	total := 0
+	for i := range q.dlq {
+		if !filter.Match(&q.dlq[i]) {
+			continue
+		}
+		if total >= offset && len(entries) < limit {
+			entries = append(entries, q.dlq[i])
+		}
+		total++
+	}
+	return entries, total, nil
+}
+
+func (q *MemoryQueue) Get(ctx context.Context, id string) (*DeadLetterEntry, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i := range q.dlq {
+		if q.dlq[i].ID == id {
+			entry := q.dlq[i]
+			return &entry, nil
+		}
+	}
+	return nil, ErrDLQEntryNotFound
+}
+
+func (q *MemoryQueue) Replay(ctx context.Context, id string) error {
+	n, err := q.replayWhere(ctx, func(e *DeadLetterEntry) bool { return e.ID == id })
+	if err == nil && n == 0 {
+		return ErrDLQEntryNotFound
+	}
+	return err
+}
+
+func (q *MemoryQueue) ReplayMatching(ctx context.Context, filter DLQFilter) (int, error) {
+	return q.replayWhere(ctx, filter.Match)
+}
+
+// replayWhere mengeluarkan entri yang cocok dari DLQ lalu memasukkannya
+// kembali ke antrian dengan siklus retry dari awal.
+func (q *MemoryQueue) replayWhere(ctx context.Context, match func(*DeadLetterEntry) bool) (int, error) {
+	removed := q.removeWhere(match)
+	jobs := make([]NotificationJob, 0, len(removed))
+	for _, entry := range removed {
+		job := entry.Job
+		job.SendAt = nil
+		job.Attempt = 0
+		job.AttemptHistory = nil
+		jobs = append(jobs, job)
+		log.Info().Str("dlq_id", entry.ID).Str("recipient", job.To).Msg("Replayed DLQ entry")
+	}
+	if err := q.EnqueueBatch(ctx, jobs); err != nil {
+		return 0, err
+	}
+	return len(jobs), nil
+}
+
+func (q *MemoryQueue) Delete(ctx context.Context, id string) error {
+	if len(q.removeWhere(func(e *DeadLetterEntry) bool { return e.ID == id })) == 0 {
+		return ErrDLQEntryNotFound
+	}
+	return nil
+}
+
+// Purge menghapus entri yang cocok dengan filter. Filter kosong menghapus
+// seluruh DLQ.
+func (q *MemoryQueue) Purge(ctx context.Context, filter DLQFilter) (int, error) {
+	return len(q.removeWhere(filter.Match)), nil
+}
+
+func (q *MemoryQueue) removeWhere(match func(*DeadLetterEntry) bool) []DeadLetterEntry {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var removed []DeadLetterEntry
+	kept := q.dlq[:0]
+	for i := range q.dlq {
+		if match(&q.dlq[i]) {
+			removed = append(removed, q.dlq[i])
+		} else {
+			kept = append(kept, q.dlq[i])
+		}
+	}
+	q.dlq = kept
+	return removed
+}
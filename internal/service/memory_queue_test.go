@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestMemoryQueue() *MemoryQueue {
+	q := NewMemoryQueue()
+	q.wait = 50 * time.Millisecond
+	return q
+}
+
+func TestMemoryQueue_DequeuesByPriorityThenFIFO(t *testing.T) {
+	q := newTestMemoryQueue()
+	ctx := context.Background()
+	require.NoError(t, q.Enqueue(ctx, NotificationJob{ID: "bulk", Priority: "bulk"}))
+	require.NoError(t, q.Enqueue(ctx, NotificationJob{ID: "n-1"}))
+	require.NoError(t, q.Enqueue(ctx, NotificationJob{ID: "n-2"}))
+	require.NoError(t, q.Enqueue(ctx, NotificationJob{ID: "critical", Priority: "critical"}))
+
+	var order []string
+	for range 4 {
+		job, err := q.Dequeue(ctx)
+		require.NoError(t, err)
+		order = append(order, job.ID)
+	}
+	assert.Equal(t, []string{"critical", "n-1", "n-2", "bulk"}, order)
+}
+
+func TestMemoryQueue_DequeueTimesOutWithRedisNil(t *testing.T) {
+	q := newTestMemoryQueue()
+	_, err := q.Dequeue(context.Background())
+	assert.ErrorIs(t, err, redis.Nil)
+}
+
+func TestMemoryQueue_DequeueWakesOnEnqueue(t *testing.T) {
+	q := NewMemoryQueue()
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		_ = q.Enqueue(context.Background(), NotificationJob{ID: "n-1"})
+	}()
+
+	start := time.Now()
+	job, err := q.Dequeue(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "n-1", job.ID)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestMemoryQueue_DequeueStopsOnCancel(t *testing.T) {
+	q := NewMemoryQueue()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := q.Dequeue(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestMemoryQueue_HoldsDelayedJobsUntilDue(t *testing.T) {
+	q := newTestMemoryQueue()
+	sendAt := time.Now().Add(20 * time.Millisecond)
+	require.NoError(t, q.Enqueue(context.Background(), NotificationJob{ID: "later", SendAt: &sendAt}))
+
+	depth, err := q.Depth(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, depth, "job tertunda belum boleh dihitung siap")
+
+	job, err := q.Dequeue(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "later", job.ID)
+	assert.False(t, time.Now().Before(sendAt))
+}
+
+func TestMemoryQueue_NackReturnsJobToFront(t *testing.T) {
+	q := newTestMemoryQueue()
+	ctx := context.Background()
+	require.NoError(t, q.Enqueue(ctx, NotificationJob{ID: "n-1"}))
+	require.NoError(t, q.Enqueue(ctx, NotificationJob{ID: "n-2"}))
+
+	job, err := q.Dequeue(ctx)
+	require.NoError(t, err)
+	require.NoError(t, q.Nack(ctx, job))
+
+	job, err = q.Dequeue(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "n-1", job.ID)
+}
+
+func TestMemoryQueue_DLQReplay(t *testing.T) {
+	q := newTestMemoryQueue()
+	ctx := context.Background()
+	job := NotificationJob{ID: "n-1", TemplateName: "welcome.html", Attempt: 3}
+	require.NoError(t, q.EnqueueToDLQ(ctx, job, DeliveryFailure{Reason: "smtp down", Attempts: 3}))
+	require.NoError(t, q.EnqueueToDLQ(ctx, NotificationJob{ID: "n-2", TemplateName: "otp.html"}, DeliveryFailure{Reason: "bad"}))
+
+	entries, total, err := q.List(ctx, DLQFilter{TemplateName: "welcome.html"}, 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "smtp down", entries[0].Reason)
+
+	require.NoError(t, q.Replay(ctx, entries[0].ID))
+	assert.ErrorIs(t, q.Replay(ctx, entries[0].ID), ErrDLQEntryNotFound)
+
+	replayed, err := q.Dequeue(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "n-1", replayed.ID)
+	assert.Zero(t, replayed.Attempt, "replay memulai ulang siklus retry")
+
+	n, err := q.Purge(ctx, DLQFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+}
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Metrik Prometheus untuk lapisan antrian. Didaftarkan ke registry default
+// sehingga ikut terekspos di endpoint /metrics milik ginprometheus.
+var (
+	streamLag = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "notification_stream_lag",
+		Help: "Jumlah entri stream yang belum dikirim ke consumer mana pun.",
+	})
+	streamPending = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "notification_stream_pending",
+		Help: "Jumlah entri stream in-flight (belum di-Ack) per consumer.",
+	}, []string{"consumer"})
+	laneDepth = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "notification_queue_depth",
+		Help: "Jumlah job yang menunggu per lane prioritas.",
+	}, []string{"lane"})
+	backpressureRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "notification_backpressure_rejected_total",
+		Help: "Jumlah job yang ditolak karena antrian melewati high-water mark.",
+	}, []string{"scope", "priority"})
+	quarantinedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "notification_quarantined_total",
+		Help: "Jumlah payload antrian yang tidak bisa di-decode dan dikarantina, per sumber.",
+	}, []string{"source"})
+	emailProviderHealthy = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "notification_email_provider_healthy",
+		Help: "1 jika circuit breaker provider email tertutup, 0 jika terbuka.",
+	}, []string{"provider"})
+	emailProviderFailovers = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "notification_email_provider_failovers_total",
+		Help: "Jumlah pengiriman yang gagal di provider dan dialihkan ke provider berikutnya.",
+	}, []string{"provider"})
+	suppressedRecipients = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "notification_suppressed_recipients_total",
+		Help: "Jumlah alamat email yang dimasukkan ke suppression list, per asal.",
+	}, []string{"source"})
+	smtpPoolConnections = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "notification_smtp_pool_connections",
+		Help: "Jumlah koneksi SMTP di pool, berdasarkan state (idle atau in_use).",
+	}, []string{"state"})
+	smtpPoolDials = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "notification_smtp_pool_dials_total",
+		Help: "Jumlah koneksi SMTP baru (handshake TCP, TLS dan AUTH), berdasarkan hasil.",
+	}, []string{"result"})
+	smtpPoolClosed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "notification_smtp_pool_closed_total",
+		Help: "Jumlah koneksi SMTP yang ditutup, berdasarkan alasan.",
+	}, []string{"reason"})
+	smtpPoolWait = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "notification_smtp_pool_wait_seconds",
+		Help:    "Lama menunggu koneksi SMTP bebas saat pool penuh.",
+		Buckets: prometheus.ExponentialBuckets(0.001, 4, 8),
+	})
+)
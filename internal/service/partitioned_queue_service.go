@@ -0,0 +1,325 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// NotificationPartitionPrefix + indeks adalah list job satu partisi.
+	NotificationPartitionPrefix = "notification_partition:"
+	// NotificationPartitionLockPrefix + indeks menandai partisi yang job
+	// ekornya sedang diproses worker (value = token klaim, TTL = lease).
+	NotificationPartitionLockPrefix = "notification_partition_lock:"
+	// NotificationPartitionSignalKey membangunkan worker yang menunggu job baru
+	// atau partisi yang baru dilepas.
+	NotificationPartitionSignalKey = "notification_partition_signal"
+)
+
+// PartitionedQueueService adalah implementasi Queue yang menjamin urutan per
+// ordering key. Job di-hash ke salah satu partisi berdasarkan OrderingKey
+// (default RecipientUserID) dan setiap partisi hanya boleh memiliki satu job
+// in-flight, sehingga job dengan key yang sama diproses berurutan sementara
+// partisi lain berjalan paralel.
+//
+// Job tidak di-pop saat Dequeue: worker mengunci partisi dan membaca ekor
+// list, lalu Ack menghapus ekor dan melepas kunci. Kunci memiliki lease
+// sehingga partisi milik worker yang mati kembali tersedia dan job-nya
+// dikirim ulang (at-least-once).
+type PartitionedQueueService struct {
+	redisClient *redis.Client
+	partitions  int
+	lease       time.Duration
+	wait        time.Duration
+	now         func() time.Time
+	token       func() string
+	// next adalah partisi awal pemindaian berikutnya agar partisi berindeks
+	// kecil tidak selalu didahulukan.
+	next func() int
+}
+
+var _ Queue = (*PartitionedQueueService)(nil)
+
+func NewPartitionedQueueService(redisClient *redis.Client, partitions int, lease time.Duration) *PartitionedQueueService {
+	if partitions < 1 {
+		partitions = 1
+	}
+	return &PartitionedQueueService{
+		redisClient: redisClient,
+		partitions:  partitions,
+		lease:       lease,
+		wait:        time.Second,
+		now:         time.Now,
+		token:       uuid.NewString,
+		next:        func() int { return rand.IntN(partitions) },
+	}
+}
+
+// OrderingKeyOf mengembalikan key urutan job: OrderingKey jika diisi,
+// selain itu RecipientUserID.
+func OrderingKeyOf(job *NotificationJob) string {
+	if job.OrderingKey != "" {
+		return job.OrderingKey
+	}
+	return job.RecipientUserID
+}
+
+func (q *PartitionedQueueService) partitionOf(job *NotificationJob) int {
+	h := fnv.New32a()
+	h.Write([]byte(OrderingKeyOf(job)))
+	return int(h.Sum32() % uint32(q.partitions))
+}
+
+func (q *PartitionedQueueService) Enqueue(ctx context.Context, job NotificationJob) error {
+	return q.EnqueueBatch(ctx, []NotificationJob{job})
+}
+
+func (q *PartitionedQueueService) EnqueueBatch(ctx context.Context, jobs []NotificationJob) error {
+	return enqueueBatch(ctx, q.redisClient, q, jobs)
+}
+
+func (q *PartitionedQueueService) enqueueTo(ctx context.Context, pipe redis.Pipeliner, job NotificationJob) error {
+	payload, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	pipe.LPush(ctx, NotificationPartitionPrefix+strconv.Itoa(q.partitionOf(&job)), payload)
+	pipe.LPush(ctx, NotificationPartitionSignalKey, "1")
+	pipe.LTrim(ctx, NotificationPartitionSignalKey, 0, 0)
+	return nil
+}
+
+// partitionClaimScript mencari partisi yang tidak terkunci dan tidak kosong
+// mulai dari ARGV[4], menguncinya, lalu mengembalikan indeks dan job ekornya.
+// Nama key partisi disusun di dalam script karena partisi yang diperiksa baru
+// diketahui saat pemindaian.
+var partitionClaimScript = redis.NewScript(`
+local n = tonumber(ARGV[3])
+local start = tonumber(ARGV[4])
+for i = 0, n - 1 do
+	local p = (start + i) % n
+	local lock = ARGV[2] .. p
+	if redis.call('EXISTS', lock) == 0 then
+		local payload = redis.call('LINDEX', ARGV[1] .. p, -1)
+		if payload then
+			redis.call('SET', lock, ARGV[5], 'PX', ARGV[6])
+			return {tostring(p), payload}
+		end
+	end
+end
+return false
+`)
+
+// partitionReleaseScript melepas kunci partisi jika masih dipegang token
+// ARGV[1]. Jika ARGV[2] == "1" ekor list (job yang selesai) ikut dihapus.
+// Worker lain dibangunkan jika partisi masih berisi job.
+var partitionReleaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[2]) ~= ARGV[1] then
+	return 0
+end
+if ARGV[2] == '1' then
+	redis.call('RPOP', KEYS[1])
+end
+redis.call('DEL', KEYS[2])
+if redis.call('LLEN', KEYS[1]) > 0 then
+	redis.call('LPUSH', KEYS[3], '1')
+	redis.call('LTRIM', KEYS[3], 0, 0)
+end
+return 1
+`)
+
+// Dequeue mengambil job dari partisi berikutnya yang tidak sedang diproses.
+// Jika belum ada, Dequeue menunggu sinyal (maksimal wait) lalu mencoba sekali
+// lagi; redis.Nil dikembalikan jika tetap kosong.
+func (q *PartitionedQueueService) Dequeue(ctx context.Context) (*NotificationJob, error) {
+	job, err := q.claim(ctx)
+	if err != nil || job != nil {
+		return job, err
+	}
+	if err := q.redisClient.BRPop(ctx, q.wait, NotificationPartitionSignalKey).Err(); err != nil {
+		return nil, err
+	}
+	job, err = q.claim(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if job == nil {
+		return nil, redis.Nil
+	}
+	return job, nil
+}
+
+func (q *PartitionedQueueService) claim(ctx context.Context) (*NotificationJob, error) {
+	token := q.token()
+	claimed, err := partitionClaimScript.Run(ctx, q.redisClient, nil,
+		NotificationPartitionPrefix, NotificationPartitionLockPrefix, q.partitions, q.next(), token, q.lease.Milliseconds()).StringSlice()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(claimed) != 2 {
+		return nil, fmt.Errorf("hasil klaim partisi tidak valid")
+	}
+	partition, payload := claimed[0], claimed[1]
+	receipt := partition + ":" + token
+	job, err := decodeJob(payload)
+	if errors.Is(err, ErrUnsupportedJobVersion) {
+		// Job dari worker yang lebih baru tetap di ujung partisi; lock-nya
+		// habis setelah lease sehingga worker lain bisa mengklaimnya.
+		return nil, err
+	}
+	if err != nil {
+		err = quarantine(ctx, q.redisClient, q.now(), QuarantineSourcePartition, payload, err)
+		if errors.Is(err, ErrPoisonMessage) {
+			// Buang payload dari partisi agar job berikutnya tidak tertahan.
+			if relErr := q.release(ctx, receipt, true); relErr != nil {
+				log.Warn().Err(relErr).Str("partition", partition).Msg("Failed to drop quarantined payload from partition")
+			}
+		}
+		return nil, err
+	}
+	job.receipt = receipt
+	return job, nil
+}
+
+// release menjalankan partitionReleaseScript untuk receipt "<partisi>:<token>".
+func (q *PartitionedQueueService) release(ctx context.Context, receipt string, done bool) error {
+	partition, token, ok := strings.Cut(receipt, ":")
+	if !ok {
+		return fmt.Errorf("receipt partisi tidak valid: %q", receipt)
+	}
+	keys := []string{
+		NotificationPartitionPrefix + partition,
+		NotificationPartitionLockPrefix + partition,
+		NotificationPartitionSignalKey,
+	}
+	pop := "0"
+	if done {
+		pop = "1"
+	}
+	released, err := partitionReleaseScript.Run(ctx, q.redisClient, keys, token, pop).Int()
+	if err != nil {
+		return err
+	}
+	if released == 0 {
+		// Lease habis dan partisi mungkin sudah diklaim worker lain; job akan
+		// dikirim ulang.
+		log.Warn().Str("partition", partition).Msg("Partition lease expired before release")
+	}
+	return nil
+}
+
+// Ack menghapus job dari ekor partisinya dan melepas kunci partisi.
+func (q *PartitionedQueueService) Ack(ctx context.Context, job *NotificationJob) error {
+	if job.receipt == "" {
+		return nil
+	}
+	return q.release(ctx, job.receipt, true)
+}
+
+// Nack melepas kunci partisi tanpa menghapus job sehingga job yang sama
+// diambil lagi sebelum job lain dengan key yang sama.
+func (q *PartitionedQueueService) Nack(ctx context.Context, job *NotificationJob) error {
+	if job.receipt == "" {
+		return q.Enqueue(ctx, *job)
+	}
+	return q.release(ctx, job.receipt, false)
+}
+
+// RetryHolder diimplementasikan backend yang bisa menahan job gagal di
+// posisinya sampai waktu retry, sehingga job lain dengan ordering key yang
+// sama tidak mendahuluinya. Wrapper meneruskannya ke Queue di bawahnya.
+type RetryHolder interface {
+	// HoldForRetry mengganti job dengan retry dan menahannya sampai
+	// retry.SendAt. held false berarti backend tidak mendukungnya; caller
+	// meng-enqueue retry sebagai job baru lalu meng-Ack job asli.
+	HoldForRetry(ctx context.Context, job *NotificationJob, retry NotificationJob) (held bool, err error)
+}
+
+var (
+	_ RetryHolder = (*PartitionedQueueService)(nil)
+	_ RetryHolder = (*ScheduledQueue)(nil)
+	_ RetryHolder = (*BackpressureQueue)(nil)
+)
+
+// partitionHoldScript mengganti ekor partisi dengan payload retry dan
+// memperpanjang kunci partisi sampai waktu retry. Kunci tidak lagi dimiliki
+// worker mana pun; setelah kedaluwarsa partisi diklaim seperti biasa dan
+// retry menjadi job pertama yang diambil.
+var partitionHoldScript = redis.NewScript(`
+if redis.call('GET', KEYS[2]) ~= ARGV[1] then
+	return 0
+end
+redis.call('LSET', KEYS[1], -1, ARGV[2])
+redis.call('SET', KEYS[2], 'retry', 'PX', ARGV[3])
+return 1
+`)
+
+// HoldForRetry menjaga urutan per ordering key selama backoff: partisi
+// tetap terkunci sampai retry.SendAt sehingga job berikutnya dengan key
+// yang sama menunggu retry selesai.
+func (q *PartitionedQueueService) HoldForRetry(ctx context.Context, job *NotificationJob, retry NotificationJob) (bool, error) {
+	if job.receipt == "" {
+		return false, nil
+	}
+	partition, token, ok := strings.Cut(job.receipt, ":")
+	if !ok {
+		return false, fmt.Errorf("receipt partisi tidak valid: %q", job.receipt)
+	}
+	retry.receipt = ""
+	payload, err := json.Marshal(retry)
+	if err != nil {
+		return false, err
+	}
+	hold := time.Millisecond
+	if retry.SendAt != nil {
+		hold = max(retry.SendAt.Sub(q.now()), hold)
+	}
+	keys := []string{NotificationPartitionPrefix + partition, NotificationPartitionLockPrefix + partition}
+	held, err := partitionHoldScript.Run(ctx, q.redisClient, keys, token, payload, hold.Milliseconds()).Int()
+	if err != nil {
+		return false, err
+	}
+	if held == 0 {
+		// Lease habis dan partisi mungkin sudah diklaim worker lain; job asli
+		// akan dikirim ulang dari awal.
+		log.Warn().Str("partition", partition).Msg("Partition lease expired before retry hold")
+	}
+	return true, nil
+}
+
+// Depth menjumlahkan panjang semua partisi, termasuk job yang sedang diproses.
+func (q *PartitionedQueueService) Depth(ctx context.Context) (int64, error) {
+	cmds := make([]*redis.IntCmd, 0, q.partitions)
+	_, err := q.redisClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for p := 0; p < q.partitions; p++ {
+			cmds = append(cmds, pipe.LLen(ctx, NotificationPartitionPrefix+strconv.Itoa(p)))
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, cmd := range cmds {
+		total += cmd.Val()
+	}
+	return total, nil
+}
+
+func (q *PartitionedQueueService) EnqueueToDLQ(ctx context.Context, job NotificationJob, failure DeliveryFailure) error {
+	return enqueueToDLQ(ctx, q.redisClient, job, failure)
+}
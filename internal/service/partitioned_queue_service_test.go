@@ -0,0 +1,169 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redismock/v9"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestPartitionedQueue(t *testing.T) (*PartitionedQueueService, redismock.ClientMock, time.Time) {
+	t.Helper()
+	db, mock := redismock.NewClientMock()
+	queue := NewPartitionedQueueService(db, 8, time.Minute)
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	queue.now = func() time.Time { return now }
+	queue.token = func() string { return "tok" }
+	queue.next = func() int { return 3 }
+	return queue, mock, now
+}
+
+func expectPartitionClaim(mock redismock.ClientMock) *redismock.ExpectedCmd {
+	return mock.ExpectEvalSha(partitionClaimScript.Hash(), nil,
+		NotificationPartitionPrefix, NotificationPartitionLockPrefix, 8, 3, "tok", int64(60000))
+}
+
+func expectPartitionRelease(mock redismock.ClientMock, partition, pop string) *redismock.ExpectedCmd {
+	keys := []string{NotificationPartitionPrefix + partition, NotificationPartitionLockPrefix + partition, NotificationPartitionSignalKey}
+	return mock.ExpectEvalSha(partitionReleaseScript.Hash(), keys, "tok", pop)
+}
+
+func TestPartitionOf_SameOrderingKeySamePartition(t *testing.T) {
+	queue, _, _ := newTestPartitionedQueue(t)
+
+	a := queue.partitionOf(&NotificationJob{RecipientUserID: "user-1", Subject: "order confirmed"})
+	b := queue.partitionOf(&NotificationJob{RecipientUserID: "user-1", Subject: "order shipped"})
+	assert.Equal(t, a, b)
+
+	explicit := queue.partitionOf(&NotificationJob{RecipientUserID: "user-2", OrderingKey: "user-1"})
+	assert.Equal(t, a, explicit, "ordering_key menimpa recipient_user_id")
+}
+
+func TestPartitionedEnqueue_PushesToHashedPartition(t *testing.T) {
+	queue, mock, _ := newTestPartitionedQueue(t)
+	job := NotificationJob{ID: "n-1", RecipientUserID: "user-1"}
+	payload, err := json.Marshal(job)
+	require.NoError(t, err)
+	partition := strconv.Itoa(queue.partitionOf(&job))
+
+	mock.ExpectTxPipeline()
+	mock.ExpectLPush(NotificationPartitionPrefix+partition, payload).SetVal(1)
+	mock.ExpectLPush(NotificationPartitionSignalKey, "1").SetVal(1)
+	mock.ExpectLTrim(NotificationPartitionSignalKey, 0, 0).SetVal("OK")
+	mock.ExpectTxPipelineExec()
+
+	require.NoError(t, queue.Enqueue(context.Background(), job))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPartitionedDequeue_ClaimsAndAckReleases(t *testing.T) {
+	queue, mock, _ := newTestPartitionedQueue(t)
+	payload := `{"version":2,"id":"n-1","recipient_user_id":"user-1","to":"a@example.com"}`
+
+	expectPartitionClaim(mock).SetVal([]interface{}{"5", payload})
+	expectPartitionRelease(mock, "5", "1").SetVal(int64(1))
+
+	job, err := queue.Dequeue(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "n-1", job.ID)
+	require.NoError(t, queue.Ack(context.Background(), job))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPartitionedNack_KeepsJobAtTail(t *testing.T) {
+	queue, mock, _ := newTestPartitionedQueue(t)
+	payload := `{"version":2,"id":"n-1","recipient_user_id":"user-1"}`
+
+	expectPartitionClaim(mock).SetVal([]interface{}{"5", payload})
+	expectPartitionRelease(mock, "5", "0").SetVal(int64(1))
+
+	job, err := queue.Dequeue(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, queue.Nack(context.Background(), job))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPartitionedHoldForRetry_KeepsPartitionLockedUntilSendAt(t *testing.T) {
+	queue, mock, now := newTestPartitionedQueue(t)
+	payload := `{"version":2,"id":"n-1","recipient_user_id":"user-1"}`
+	keys := []string{NotificationPartitionPrefix + "5", NotificationPartitionLockPrefix + "5"}
+
+	expectPartitionClaim(mock).SetVal([]interface{}{"5", payload})
+	job, err := queue.Dequeue(context.Background())
+	require.NoError(t, err)
+
+	retry := *job
+	retry.Attempt = 1
+	sendAt := now.Add(30 * time.Second)
+	retry.SendAt = &sendAt
+	retry.receipt = ""
+	retryPayload, err := json.Marshal(retry)
+	require.NoError(t, err)
+	mock.ExpectEvalSha(partitionHoldScript.Hash(), keys, "tok", retryPayload, int64(30000)).SetVal(int64(1))
+	// Selama ditahan, partisi tetap terkunci sehingga job berikutnya dengan
+	// key yang sama tidak bisa diklaim.
+	expectPartitionClaim(mock).RedisNil()
+
+	held, err := queue.HoldForRetry(context.Background(), job, retry)
+	require.NoError(t, err)
+	assert.True(t, held)
+	next, err := queue.claim(context.Background())
+	require.NoError(t, err)
+	assert.Nil(t, next)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPartitionedHoldForRetry_WrappersForwardToBackend(t *testing.T) {
+	queue, mock, _ := newTestPartitionedQueue(t)
+	wrapped := NewBackpressureQueue(nil, NewScheduledQueue(nil, queue), BackpressureLimits{})
+
+	held, err := wrapped.HoldForRetry(context.Background(), &NotificationJob{ID: "n-1"}, NotificationJob{ID: "n-1"})
+	require.NoError(t, err)
+	assert.False(t, held, "job tanpa receipt partisi memakai Enqueue + Ack")
+	held, err = NewBackpressureQueue(nil, NewQueueService(nil), BackpressureLimits{}).HoldForRetry(context.Background(), &NotificationJob{}, NotificationJob{})
+	require.NoError(t, err)
+	assert.False(t, held)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPartitionedDequeue_WaitsForSignalWhenAllPartitionsBusy(t *testing.T) {
+	queue, mock, _ := newTestPartitionedQueue(t)
+
+	expectPartitionClaim(mock).RedisNil()
+	mock.ExpectBRPop(time.Second, NotificationPartitionSignalKey).SetVal([]string{NotificationPartitionSignalKey, "1"})
+	expectPartitionClaim(mock).RedisNil()
+
+	_, err := queue.Dequeue(context.Background())
+	assert.ErrorIs(t, err, redis.Nil)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPartitionedDequeue_QuarantinesUndecodablePayload(t *testing.T) {
+	queue, mock, now := newTestPartitionedQueue(t)
+
+	expectPartitionClaim(mock).SetVal([]interface{}{"5", "not-json"})
+	expectQuarantine(t, mock, now, QuarantineSourcePartition, "not-json")
+	expectPartitionRelease(mock, "5", "1").SetVal(int64(1))
+
+	_, err := queue.Dequeue(context.Background())
+	assert.ErrorIs(t, err, ErrPoisonMessage)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPartitionedDepth(t *testing.T) {
+	queue, mock, _ := newTestPartitionedQueue(t)
+	for p := 0; p < 8; p++ {
+		mock.ExpectLLen(NotificationPartitionPrefix + strconv.Itoa(p)).SetVal(int64(p))
+	}
+
+	depth, err := queue.Depth(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(28), depth)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+)
+
+// Priority menentukan lane antrian sebuah job. Lane diperiksa dari yang
+// paling penting sehingga email transaksional (mis. password reset) tidak
+// tertahan di belakang notifikasi massal.
+type Priority string
+
+const (
+	PriorityCritical Priority = "critical"
+	PriorityHigh     Priority = "high"
+	PriorityNormal   Priority = "normal"
+	PriorityBulk     Priority = "bulk"
+)
+
+// Priorities berisi semua lane, diurutkan dari prioritas tertinggi.
+var Priorities = []Priority{PriorityCritical, PriorityHigh, PriorityNormal, PriorityBulk}
+
+// ParsePriority memvalidasi nama lane. String kosong berarti normal.
+func ParsePriority(s string) (Priority, error) {
+	if s == "" {
+		return PriorityNormal, nil
+	}
+	for _, p := range Priorities {
+		if string(p) == s {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("priority tidak dikenal %q", s)
+}
+
+// LaneKey mengembalikan key Redis list untuk sebuah lane. Lane normal tetap
+// memakai notification_queue agar job yang sudah ada tidak perlu dimigrasi.
+func LaneKey(p Priority) string {
+	if p == "" || p == PriorityNormal {
+		return NotificationQueueKey
+	}
+	return NotificationQueueKey + ":" + string(p)
+}
+
+// laneKeyForJob memetakan job ke lane-nya; priority tak dikenal jatuh ke normal.
+func laneKeyForJob(job *NotificationJob) string {
+	p, err := ParsePriority(job.Priority)
+	if err != nil {
+		return NotificationQueueKey
+	}
+	return LaneKey(p)
+}
+
+// LanePolicy mengatur urutan pemeriksaan lane saat Dequeue.
+//
+// Mode strict selalu mendahulukan lane tertinggi yang berisi job. Mode
+// weighted memilih lane pertama secara acak sebanding Weights (lane lain
+// tetap diperiksa dalam urutan strict jika lane terpilih kosong), sehingga
+// lane bulk tetap mendapat porsi walau lane atas selalu terisi.
+type LanePolicy struct {
+	Weighted bool
+	Weights  map[Priority]int
+}
+
+// DefaultLaneWeights dipakai untuk lane yang tidak memiliki bobot.
+var DefaultLaneWeights = map[Priority]int{
+	PriorityCritical: 8,
+	PriorityHigh:     4,
+	PriorityNormal:   2,
+	PriorityBulk:     1,
+}
+
+// order mengembalikan key lane dalam urutan pemeriksaan. pick adalah
+// bilangan acak di [0, 1) dan hanya dipakai pada mode weighted.
+func (p LanePolicy) order(pick float64) []string {
+	keys := make([]string, 0, len(Priorities))
+	first := -1
+	if p.Weighted {
+		first = p.pickLane(pick)
+		keys = append(keys, LaneKey(Priorities[first]))
+	}
+	for i, prio := range Priorities {
+		if i != first {
+			keys = append(keys, LaneKey(prio))
+		}
+	}
+	return keys
+}
+
+func (p LanePolicy) weight(prio Priority) int {
+	if w, ok := p.Weights[prio]; ok {
+		return max(w, 0)
+	}
+	return DefaultLaneWeights[prio]
+}
+
+func (p LanePolicy) pickLane(pick float64) int {
+	total := 0
+	for _, prio := range Priorities {
+		total += p.weight(prio)
+	}
+	if total == 0 {
+		return 0
+	}
+	target := int(pick * float64(total))
+	for i, prio := range Priorities {
+		target -= p.weight(prio)
+		if target < 0 {
+			return i
+		}
+	}
+	return len(Priorities) - 1
+}
+
+// LaneDepths mengembalikan jumlah job yang menunggu di setiap lane.
+func LaneDepths(ctx context.Context, redisClient *redis.Client) (map[Priority]int64, error) {
+	cmds := make(map[Priority]*redis.IntCmd, len(Priorities))
+	_, err := redisClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, p := range Priorities {
+			cmds[p] = pipe.LLen(ctx, LaneKey(p))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	depths := make(map[Priority]int64, len(cmds))
+	for p, cmd := range cmds {
+		depths[p] = cmd.Val()
+	}
+	return depths, nil
+}
+
+// Depth mengembalikan jumlah job yang menunggu di semua lane.
+func (s *QueueService) Depth(ctx context.Context) (int64, error) {
+	depths, err := LaneDepths(ctx, s.redisClient)
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, depth := range depths {
+		total += depth
+	}
+	return total, nil
+}
+
+// RunLaneMonitor memperbarui metrik kedalaman lane secara periodik.
+func RunLaneMonitor(ctx context.Context, redisClient *redis.Client, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			depths, err := LaneDepths(ctx, redisClient)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Error().Err(err).Msg("Failed to read queue lane depths")
+				}
+				continue
+			}
+			for p, depth := range depths {
+				laneDepth.WithLabelValues(string(p)).Set(float64(depth))
+			}
+		}
+	}
+}
+
+func randomPick() float64 {
+	return rand.Float64()
+}
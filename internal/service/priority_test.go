@@ -0,0 +1,36 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePriority(t *testing.T) {
+	p, err := ParsePriority("")
+	require.NoError(t, err)
+	assert.Equal(t, PriorityNormal, p)
+
+	p, err = ParsePriority("bulk")
+	require.NoError(t, err)
+	assert.Equal(t, PriorityBulk, p)
+
+	_, err = ParsePriority("urgent")
+	assert.Error(t, err)
+}
+
+func TestLanePolicy_StrictOrder(t *testing.T) {
+	keys := LanePolicy{}.order(0.99)
+	assert.Equal(t, []string{"notification_queue:critical", "notification_queue:high", "notification_queue", "notification_queue:bulk"}, keys)
+}
+
+func TestLanePolicy_WeightedPick(t *testing.T) {
+	policy := LanePolicy{Weighted: true, Weights: map[Priority]int{PriorityCritical: 1, PriorityHigh: 0, PriorityNormal: 1, PriorityBulk: 2}}
+
+	// Total bobot 4: [0, .25) critical, [.25, .5) normal, [.5, 1) bulk; high tidak pernah dipilih.
+	assert.Equal(t, "notification_queue:critical", policy.order(0.1)[0])
+	assert.Equal(t, "notification_queue", policy.order(0.3)[0])
+	assert.Equal(t, "notification_queue:bulk", policy.order(0.6)[0])
+	assert.Equal(t, []string{"notification_queue:bulk", "notification_queue:critical", "notification_queue:high", "notification_queue"}, policy.order(0.6))
+}
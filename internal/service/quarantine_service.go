@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+)
+
+const NotificationQuarantineKey = "notification_quarantine"
+
+// ErrPoisonMessage dikembalikan Dequeue saat payload tidak bisa di-decode.
+// Payload sudah dipindah ke karantina sehingga worker cukup lanjut ke job
+// berikutnya.
+var ErrPoisonMessage = errors.New("poison message quarantined")
+
+var ErrQuarantineEntryNotFound = errors.New("quarantine entry not found")
+
+// Sumber payload yang dikarantina, dipakai sebagai label metrik.
+const (
+	QuarantineSourceList      = "list"
+	QuarantineSourceStream    = "stream"
+	QuarantineSourceFair      = "fair"
+	QuarantineSourceScheduled = "scheduled"
+	QuarantineSourcePartition = "partition"
+)
+
+// QuarantineEntry menyimpan payload mentah yang gagal di-decode beserta
+// alasannya agar bisa diperiksa tanpa membaca log worker.
+type QuarantineEntry struct {
+	ID            string    `json:"id,omitempty"`
+	Source        string    `json:"source"`
+	Payload       string    `json:"payload"`
+	Error         string    `json:"error"`
+	QuarantinedAt time.Time `json:"quarantined_at"`
+
+	raw string
+}
+
+// quarantine menyimpan payload ke notification_quarantine dan mengembalikan
+// error yang membungkus ErrPoisonMessage. Jika penyimpanan gagal, error Redis
+// yang dikembalikan sehingga caller tidak membuang payload dari sumbernya.
+func quarantine(ctx context.Context, redisClient *redis.Client, at time.Time, source, payload string, decodeErr error) error {
+	entry, err := json.Marshal(QuarantineEntry{
+		Source:        source,
+		Payload:       payload,
+		Error:         decodeErr.Error(),
+		QuarantinedAt: at.UTC(),
+	})
+	if err != nil {
+		return err
+	}
+	if err := redisClient.LPush(ctx, NotificationQuarantineKey, entry).Err(); err != nil {
+		log.Error().Err(err).Str("source", source).Str("payload", payload).Msg("Failed to quarantine undecodable payload")
+		return fmt.Errorf("gagal mengkarantina payload: %w", err)
+	}
+	quarantinedTotal.WithLabelValues(source).Inc()
+	log.Warn().Err(decodeErr).Str("source", source).Msg("Quarantined undecodable queue payload")
+	return fmt.Errorf("%w: %v", ErrPoisonMessage, decodeErr)
+}
+
+type Quarantine interface {
+	List(ctx context.Context, offset, limit int) ([]QuarantineEntry, int, error)
+	Get(ctx context.Context, id string) (*QuarantineEntry, error)
+	Delete(ctx context.Context, id string) error
+	Purge(ctx context.Context) (int, error)
+}
+
+// QuarantineService membaca dan mengelola isi notification_quarantine.
+type QuarantineService struct {
+	redisClient *redis.Client
+}
+
+var _ Quarantine = (*QuarantineService)(nil)
+
+func NewQuarantineService(redisClient *redis.Client) *QuarantineService {
+	return &QuarantineService{redisClient: redisClient}
+}
+
+func decodeQuarantineEntry(raw string) QuarantineEntry {
+	var entry QuarantineEntry
+	if err := json.Unmarshal([]byte(raw), &entry); err != nil {
+		// Entri karantina selalu ditulis oleh service ini; jika tetap rusak,
+		// tampilkan apa adanya supaya masih bisa dihapus.
+		entry = QuarantineEntry{Payload: raw, Error: err.Error()}
+	}
+	entry.ID = payloadID(raw)
+	entry.raw = raw
+	return entry
+}
+
+func (s *QuarantineService) List(ctx context.Context, offset, limit int) ([]QuarantineEntry, int, error) {
+	total, err := s.redisClient.LLen(ctx, NotificationQuarantineKey).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+	raws, err := s.redisClient.LRange(ctx, NotificationQuarantineKey, int64(offset), int64(offset+limit-1)).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+	entries := make([]QuarantineEntry, 0, len(raws))
+	for _, raw := range raws {
+		entries = append(entries, decodeQuarantineEntry(raw))
+	}
+	return entries, int(total), nil
+}
+
+func (s *QuarantineService) Get(ctx context.Context, id string) (*QuarantineEntry, error) {
+	for start := int64(0); ; start += dlqScanChunk {
+		raws, err := s.redisClient.LRange(ctx, NotificationQuarantineKey, start, start+dlqScanChunk-1).Result()
+		if err != nil {
+			return nil, err
+		}
+		for _, raw := range raws {
+			if payloadID(raw) == id {
+				entry := decodeQuarantineEntry(raw)
+				return &entry, nil
+			}
+		}
+		if len(raws) < dlqScanChunk {
+			return nil, ErrQuarantineEntryNotFound
+		}
+	}
+}
+
+func (s *QuarantineService) Delete(ctx context.Context, id string) error {
+	entry, err := s.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	removed, err := s.redisClient.LRem(ctx, NotificationQuarantineKey, 1, entry.raw).Result()
+	if err != nil {
+		return err
+	}
+	if removed == 0 {
+		return ErrQuarantineEntryNotFound
+	}
+	return nil
+}
+
+func (s *QuarantineService) Purge(ctx context.Context) (int, error) {
+	var length *redis.IntCmd
+	_, err := s.redisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		length = pipe.LLen(ctx, NotificationQuarantineKey)
+		pipe.Del(ctx, NotificationQuarantineKey)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int(length.Val()), nil
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redismock/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// expectQuarantine menyiapkan LPUSH entri karantina untuk payload yang gagal
+// di-decode oleh decodeJob.
+func expectQuarantine(t *testing.T, mock redismock.ClientMock, at time.Time, source, payload string) {
+	t.Helper()
+	_, decodeErr := decodeJob(payload)
+	require.Error(t, decodeErr)
+	expectQuarantineErr(t, mock, at, source, payload, decodeErr)
+}
+
+func expectQuarantineErr(t *testing.T, mock redismock.ClientMock, at time.Time, source, payload string, decodeErr error) {
+	t.Helper()
+	entry, err := json.Marshal(QuarantineEntry{Source: source, Payload: payload, Error: decodeErr.Error(), QuarantinedAt: at.UTC()})
+	require.NoError(t, err)
+	mock.ExpectLPush(NotificationQuarantineKey, entry).SetVal(1)
+}
+
+func TestQuarantine_StoresPayloadAndReturnsPoisonError(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	at := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	expectQuarantineErr(t, mock, at, QuarantineSourceList, "not-json", errors.New("boom"))
+
+	err := quarantine(context.Background(), db, at, QuarantineSourceList, "not-json", errors.New("boom"))
+	assert.ErrorIs(t, err, ErrPoisonMessage)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestQuarantine_RedisFailureIsNotPoisonError(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	at := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	entry, err := json.Marshal(QuarantineEntry{Source: QuarantineSourceList, Payload: "x", Error: "boom", QuarantinedAt: at})
+	require.NoError(t, err)
+	mock.ExpectLPush(NotificationQuarantineKey, entry).SetErr(errors.New("connection refused"))
+
+	err = quarantine(context.Background(), db, at, QuarantineSourceList, "x", errors.New("boom"))
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrPoisonMessage, "payload belum aman sehingga caller tidak boleh membuangnya")
+}
+
+func TestQuarantineList_Paged(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	quarantined := NewQuarantineService(db)
+	raw := `{"source":"list","payload":"{oops","error":"unexpected end of JSON input","quarantined_at":"2025-01-01T12:00:00Z"}`
+
+	mock.ExpectLLen(NotificationQuarantineKey).SetVal(3)
+	mock.ExpectLRange(NotificationQuarantineKey, 2, 3).SetVal([]string{raw})
+
+	entries, total, err := quarantined.List(context.Background(), 2, 2)
+	require.NoError(t, err)
+	assert.Equal(t, 3, total)
+	require.Len(t, entries, 1)
+	assert.Equal(t, payloadID(raw), entries[0].ID)
+	assert.Equal(t, "{oops", entries[0].Payload)
+	assert.Equal(t, "list", entries[0].Source)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestQuarantineDelete(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	quarantined := NewQuarantineService(db)
+	raw := `{"source":"stream","payload":"x","error":"bad","quarantined_at":"2025-01-01T12:00:00Z"}`
+
+	mock.ExpectLRange(NotificationQuarantineKey, 0, dlqScanChunk-1).SetVal([]string{raw})
+	mock.ExpectLRem(NotificationQuarantineKey, 1, raw).SetVal(1)
+
+	require.NoError(t, quarantined.Delete(context.Background(), payloadID(raw)))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestQuarantineGet_NotFound(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	quarantined := NewQuarantineService(db)
+
+	mock.ExpectLRange(NotificationQuarantineKey, 0, dlqScanChunk-1).SetVal([]string{})
+
+	_, err := quarantined.Get(context.Background(), "deadbeef")
+	assert.ErrorIs(t, err, ErrQuarantineEntryNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestQuarantinePurge(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	quarantined := NewQuarantineService(db)
+
+	mock.ExpectTxPipeline()
+	mock.ExpectLLen(NotificationQuarantineKey).SetVal(4)
+	mock.ExpectDel(NotificationQuarantineKey).SetVal(1)
+	mock.ExpectTxPipelineExec()
+
+	n, err := quarantined.Purge(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 4, n)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
@@ -13,31 +14,100 @@ import (
 const (
 	NotificationQueueKey = "notification_queue"
 	NotificationDLQKey   = "notification_dlq" // <-- KEY BARU
+
+	// Key untuk mode reliable (at-least-once).
+	NotificationProcessingKeyPrefix = "notification_processing:"
+	NotificationProcessingListsKey  = "notification_processing_lists"
+	NotificationInflightKey         = "notification_inflight"
 )
 
 // PERBAIKAN: Tambahkan field RecipientUserID
 type NotificationJob struct {
+	// Version adalah versi skema payload (lihat JobSchemaVersion); selalu
+	// diisi saat job diserialisasi.
+	Version         int                    `json:"version"`
+	ID              string                 `json:"id,omitempty"`
 	RecipientUserID string                 `json:"recipient_user_id"`
 	To              string                 `json:"to"`
 	Subject         string                 `json:"subject"`
 	TemplateName    string                 `json:"template_name"` // <-- Ganti 'Body' dengan 'TemplateName'
 	TemplateData    map[string]interface{} `json:"template_data"` // <-- Data dinamis untuk template
+	// SendAt menunda pengiriman hingga waktu tertentu (lihat ScheduledQueue).
+	SendAt *time.Time `json:"send_at,omitempty"`
+	// EnqueuedAt adalah waktu job pertama kali diterima oleh service.
+	EnqueuedAt *time.Time `json:"enqueued_at,omitempty"`
+	// ExpiresAt adalah batas waktu kirim; job yang lewat batas dibuang worker
+	// dengan status expired dan retry tidak dijadwalkan melewatinya.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// TenantID mengelompokkan job untuk fair queuing (lihat FairQueueService).
+	TenantID string `json:"tenant_id,omitempty"`
+	// Priority memilih lane antrian (lihat Priority); kosong berarti normal.
+	Priority string `json:"priority,omitempty"`
+	// OrderingKey mengelompokkan job yang harus diproses berurutan (lihat
+	// PartitionedQueueService); kosong berarti RecipientUserID.
+	OrderingKey string `json:"ordering_key,omitempty"`
+	// Attempt adalah jumlah percobaan kirim yang sudah gagal; AttemptHistory
+	// membawa detailnya antar re-enqueue hingga job masuk DLQ.
+	Attempt        int               `json:"attempt,omitempty"`
+	AttemptHistory []DeliveryAttempt `json:"attempt_history,omitempty"`
+
+	// receipt mengidentifikasi pengiriman in-flight untuk Ack/Nack: payload
+	// mentah untuk backend list, message ID untuk backend stream.
+	receipt string
+}
+
+// Expired melaporkan apakah batas waktu kirim job sudah lewat pada now.
+func (j *NotificationJob) Expired(now time.Time) bool {
+	return j.ExpiresAt != nil && !now.Before(*j.ExpiresAt)
 }
 
 type Queue interface {
 	Enqueue(ctx context.Context, job NotificationJob) error
 	Dequeue(ctx context.Context) (*NotificationJob, error)
-	EnqueueToDLQ(ctx context.Context, job NotificationJob) error
+	// Ack menandai job selesai diproses (terkirim atau sudah dipindah ke DLQ).
+	Ack(ctx context.Context, job *NotificationJob) error
+	// Nack mengembalikan job ke antrian agar diproses ulang oleh worker lain.
+	Nack(ctx context.Context, job *NotificationJob) error
+	// EnqueueToDLQ memindahkan job yang gagal permanen ke DLQ beserta
+	// metadata kegagalannya.
+	EnqueueToDLQ(ctx context.Context, job NotificationJob, failure DeliveryFailure) error
 }
 
 type QueueService struct {
 	redisClient *redis.Client
+
+	// processingKey kosong berarti mode BRPOP biasa (at-most-once).
+	processingKey     string
+	visibilityTimeout time.Duration
+	lanes             LanePolicy
+	now               func() time.Time
+	pick              func() float64
 }
 
 var _ Queue = (*QueueService)(nil)
 
-func NewQueueService(redisClient *redis.Client) Queue {
-	return &QueueService{redisClient: redisClient}
+func NewQueueService(redisClient *redis.Client) *QueueService {
+	return &QueueService{redisClient: redisClient, now: time.Now, pick: randomPick}
+}
+
+// NewReliableQueueService membuat queue dengan semantik at-least-once.
+// Job dipindahkan secara atomik ke processing list milik consumer (BLMOVE)
+// dan baru dihapus saat Ack. Job yang tidak di-Ack dalam visibilityTimeout
+// dikembalikan ke antrian oleh reaper (lihat RunReaper).
+func NewReliableQueueService(redisClient *redis.Client, consumer string, visibilityTimeout time.Duration) *QueueService {
+	return &QueueService{
+		redisClient:       redisClient,
+		processingKey:     NotificationProcessingKeyPrefix + consumer,
+		visibilityTimeout: visibilityTimeout,
+		now:               time.Now,
+		pick:              randomPick,
+	}
+}
+
+// WithLanePolicy mengatur urutan lane prioritas saat Dequeue (default strict).
+func (s *QueueService) WithLanePolicy(policy LanePolicy) *QueueService {
+	s.lanes = policy
+	return s
 }
 
 func (s *QueueService) Enqueue(ctx context.Context, job NotificationJob) error {
@@ -45,11 +115,29 @@ func (s *QueueService) Enqueue(ctx context.Context, job NotificationJob) error {
 	if err != nil {
 		return err
 	}
-	return s.redisClient.LPush(ctx, NotificationQueueKey, payload).Err()
+	return s.redisClient.LPush(ctx, laneKeyForJob(&job), payload).Err()
+}
+
+func (s *QueueService) EnqueueBatch(ctx context.Context, jobs []NotificationJob) error {
+	return enqueueBatch(ctx, s.redisClient, s, jobs)
+}
+
+func (s *QueueService) enqueueTo(ctx context.Context, pipe redis.Pipeliner, job NotificationJob) error {
+	payload, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	pipe.LPush(ctx, laneKeyForJob(&job), payload)
+	return nil
 }
 
 func (s *QueueService) Dequeue(ctx context.Context) (*NotificationJob, error) {
-	result, err := s.redisClient.BRPop(ctx, 5*time.Second, NotificationQueueKey).Result()
+	if s.processingKey != "" {
+		return s.dequeueReliable(ctx)
+	}
+
+	// BRPOP dengan beberapa key mengambil dari key pertama yang berisi job.
+	result, err := s.redisClient.BRPop(ctx, 5*time.Second, s.lanes.order(s.pick())...).Result()
 	if err != nil {
 		return nil, err // Error akan ditangani oleh worker (redis.Nil jika timeout)
 	}
@@ -59,19 +147,205 @@ func (s *QueueService) Dequeue(ctx context.Context) (*NotificationJob, error) {
 		return nil, fmt.Errorf("hasil BRPop tidak valid")
 	}
 
-	var job NotificationJob
-	err = json.Unmarshal([]byte(result[1]), &job)
+	job, err := decodeJob(result[1])
+	if errors.Is(err, ErrUnsupportedJobVersion) {
+		// Job dari worker yang lebih baru: kembalikan ke ujung lane yang diambil
+		// paling akhir. Di ujung yang dibaca BRPOP, worker lama akan langsung
+		// mengambilnya lagi; di ujung lain job lain diproses lebih dulu dan
+		// worker baru punya kesempatan mengambilnya.
+		if pushErr := s.redisClient.LPush(ctx, result[0], result[1]).Err(); pushErr != nil {
+			return nil, quarantine(ctx, s.redisClient, s.now(), QuarantineSourceList, result[1], err)
+		}
+		return nil, err
+	}
+	if err != nil {
+		// Payload sudah keluar dari Redis; simpan di karantina agar tidak hilang.
+		return nil, quarantine(ctx, s.redisClient, s.now(), QuarantineSourceList, result[1], err)
+	}
+	return job, nil
+}
+
+func (s *QueueService) dequeueReliable(ctx context.Context) (*NotificationJob, error) {
+	payload, err := s.moveFromLanes(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return &job, nil
+
+	job, decodeErr := decodeJob(payload)
+	if decodeErr != nil && !errors.Is(decodeErr, ErrUnsupportedJobVersion) {
+		// Payload yang rusak tidak boleh dikembalikan reaper ke antrian.
+		if qErr := quarantine(ctx, s.redisClient, s.now(), QuarantineSourceList, payload, decodeErr); !errors.Is(qErr, ErrPoisonMessage) {
+			return nil, qErr
+		}
+		if rmErr := s.redisClient.LRem(ctx, s.processingKey, 1, payload).Err(); rmErr != nil {
+			log.Warn().Err(rmErr).Str("processing_key", s.processingKey).Msg("Failed to remove quarantined payload from processing list")
+		}
+		return nil, fmt.Errorf("%w: %v", ErrPoisonMessage, decodeErr)
+	}
+
+	// Catat deadline visibilitas. Jika langkah ini gagal, reaper tetap akan
+	// memberi deadline pada entri yang belum punya skor.
+	deadline := s.now().Add(s.visibilityTimeout).UnixMilli()
+	_, err = s.redisClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAdd(ctx, NotificationInflightKey, redis.Z{Score: float64(deadline), Member: payload})
+		pipe.SAdd(ctx, NotificationProcessingListsKey, s.processingKey)
+		return nil
+	})
+	if err != nil {
+		log.Warn().Err(err).Str("processing_key", s.processingKey).Msg("Failed to record visibility deadline")
+	}
+	if decodeErr != nil {
+		// Job dari worker yang lebih baru tetap di processing list; reaper
+		// mengembalikannya ke lane setelah visibility timeout.
+		return nil, decodeErr
+	}
+
+	return job, nil
 }
 
-func (s *QueueService) EnqueueToDLQ(ctx context.Context, job NotificationJob) error {
-	payload, err := json.Marshal(job)
+// moveFromLanes memindahkan satu job ke processing list. BLMOVE hanya
+// menerima satu sumber, jadi lane diperiksa dulu tanpa blocking sesuai urutan
+// prioritas, lalu menunggu sebentar di lane pertama jika semuanya kosong.
+func (s *QueueService) moveFromLanes(ctx context.Context) (string, error) {
+	lanes := s.lanes.order(s.pick())
+	for _, lane := range lanes {
+		payload, err := s.redisClient.LMove(ctx, lane, s.processingKey, "RIGHT", "LEFT").Result()
+		if err == nil {
+			return payload, nil
+		}
+		if !errors.Is(err, redis.Nil) {
+			return "", err
+		}
+	}
+	return s.redisClient.BLMove(ctx, lanes[0], s.processingKey, "RIGHT", "LEFT", time.Second).Result()
+}
+
+// nackScript mengembalikan job ke ujung kanan antrian (diambil paling dulu),
+// tetapi hanya jika job masih ada di processing list. Ini mencegah duplikasi
+// jika reaper sudah lebih dulu mengembalikannya.
+var nackScript = redis.NewScript(`
+if redis.call('LREM', KEYS[1], 1, ARGV[1]) > 0 then
+	redis.call('ZREM', KEYS[2], ARGV[1])
+	redis.call('RPUSH', KEYS[3], ARGV[1])
+	return 1
+end
+return 0
+`)
+
+func (s *QueueService) Ack(ctx context.Context, job *NotificationJob) error {
+	if s.processingKey == "" || job.receipt == "" {
+		return nil
+	}
+	_, err := s.redisClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LRem(ctx, s.processingKey, 1, job.receipt)
+		pipe.ZRem(ctx, NotificationInflightKey, job.receipt)
+		return nil
+	})
+	return err
+}
+
+func (s *QueueService) Nack(ctx context.Context, job *NotificationJob) error {
+	if s.processingKey == "" || job.receipt == "" {
+		// Mode BRPOP: job sudah keluar dari Redis, jadi masukkan kembali.
+		payload, err := json.Marshal(job)
+		if err != nil {
+			return err
+		}
+		return s.redisClient.RPush(ctx, laneKeyForJob(job), payload).Err()
+	}
+	keys := []string{s.processingKey, NotificationInflightKey, laneKeyForJob(job)}
+	return nackScript.Run(ctx, s.redisClient, keys, job.receipt).Err()
+}
+
+// reapScript memeriksa semua processing list yang terdaftar. Entri tanpa
+// deadline diberi deadline baru, entri yang deadline-nya lewat dikembalikan
+// ke lane sesuai field priority pada payload (default lane normal).
+var reapScript = redis.NewScript(`
+local lanes = {critical = true, high = true, bulk = true}
+local function lane_for(payload)
+	local ok, job = pcall(cjson.decode, payload)
+	if ok and type(job) == 'table' and lanes[job.priority] then
+		return KEYS[3] .. ':' .. job.priority
+	end
+	return KEYS[3]
+end
+local requeued = 0
+for _, list in ipairs(redis.call('SMEMBERS', KEYS[1])) do
+	for _, payload in ipairs(redis.call('LRANGE', list, 0, -1)) do
+		local deadline = redis.call('ZSCORE', KEYS[2], payload)
+		if not deadline then
+			redis.call('ZADD', KEYS[2], ARGV[2], payload)
+		elseif tonumber(deadline) <= tonumber(ARGV[1]) then
+			redis.call('LREM', list, 1, payload)
+			redis.call('ZREM', KEYS[2], payload)
+			redis.call('RPUSH', lane_for(payload), payload)
+			requeued = requeued + 1
+		end
+	end
+end
+return requeued
+`)
+
+// ReapExpired mengembalikan job yang visibility timeout-nya habis ke lane
+// asalnya dan mengembalikan jumlah job yang dipulihkan.
+func (s *QueueService) ReapExpired(ctx context.Context) (int64, error) {
+	now := s.now()
+	keys := []string{NotificationProcessingListsKey, NotificationInflightKey, NotificationQueueKey}
+	return reapScript.Run(ctx, s.redisClient, keys,
+		now.UnixMilli(), now.Add(s.visibilityTimeout).UnixMilli()).Int64()
+}
+
+// RunReaper menjalankan ReapExpired secara periodik hingga ctx dibatalkan.
+func (s *QueueService) RunReaper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := s.ReapExpired(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Error().Err(err).Msg("Failed to reap expired in-flight jobs")
+				}
+				continue
+			}
+			if n > 0 {
+				log.Warn().Int64("count", n).Msg("Returned expired in-flight jobs to the queue")
+			}
+		}
+	}
+}
+
+func (s *QueueService) EnqueueToDLQ(ctx context.Context, job NotificationJob, failure DeliveryFailure) error {
+	return enqueueToDLQ(ctx, s.redisClient, job, failure)
+}
+
+// enqueueToDLQ dipakai bersama oleh semua backend Redis. Job dibungkus
+// dalam envelope DeadLetterEntry agar triase tidak perlu membaca log.
+func enqueueToDLQ(ctx context.Context, redisClient *redis.Client, job NotificationJob, failure DeliveryFailure) error {
+	if failure.FailedAt.IsZero() {
+		failure.FailedAt = time.Now().UTC()
+	}
+	entry := DeadLetterEntry{
+		Job:             job,
+		DeliveryFailure: failure,
+		EnqueuedAt:      job.EnqueuedAt,
+	}
+	payload, err := json.Marshal(entry)
 	if err != nil {
 		return fmt.Errorf("failed to marshal job for DLQ: %w", err)
 	}
-	log.Warn().Str("recipient", job.To).Msg("Moving job to Dead-Letter Queue")
-	return s.redisClient.LPush(ctx, NotificationDLQKey, payload).Err()
+	log.Warn().Str("recipient", job.To).Str("error_class", failure.ErrorClass).Msg("Moving job to Dead-Letter Queue")
+	return redisClient.LPush(ctx, NotificationDLQKey, payload).Err()
+}
+
+func decodeJob(payload string) (*NotificationJob, error) {
+	job, err := unmarshalJob([]byte(payload))
+	if err != nil {
+		return nil, err
+	}
+	job.receipt = payload
+	return &job, nil
 }
@@ -8,6 +8,7 @@ import (
 	"time" // <-- Impor paket time
 
 	"github.com/go-redis/redismock/v9"
+	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -45,7 +46,7 @@ func TestDequeue_Success(t *testing.T) {
 	require.NoError(t, err)
 
 	// FIX: Sesuaikan timeout BRPop agar cocok dengan implementasi (5 detik).
-	mock.ExpectBRPop(5*time.Second, NotificationQueueKey).SetVal([]string{NotificationQueueKey, string(payload)})
+	mock.ExpectBRPop(5*time.Second, strictLaneKeys...).SetVal([]string{NotificationQueueKey, string(payload)})
 
 	dequeuedJob, err := queueService.Dequeue(context.Background())
 	assert.NoError(t, err, "Dequeue seharusnya tidak menghasilkan error")
@@ -62,7 +63,7 @@ func TestDequeue_RedisError(t *testing.T) {
 
 	expectedError := errors.New("koneksi redis putus")
 	// FIX: Sesuaikan timeout BRPop agar cocok dengan implementasi (5 detik).
-	mock.ExpectBRPop(5*time.Second, NotificationQueueKey).SetErr(expectedError)
+	mock.ExpectBRPop(5*time.Second, strictLaneKeys...).SetErr(expectedError)
 
 	dequeuedJob, err := queueService.Dequeue(context.Background())
 
@@ -72,19 +73,222 @@ func TestDequeue_RedisError(t *testing.T) {
 	assert.NoError(t, mock.ExpectationsWereMet(), "Ekspektasi mock tidak terpenuhi")
 }
 
-// TestEnqueueToDLQ_Success tidak berubah.
+// TestEnqueueToDLQ_Success memastikan job dibungkus envelope metadata kegagalan.
 func TestEnqueueToDLQ_Success(t *testing.T) {
 	db, mock := redismock.NewClientMock()
 	queueService := NewQueueService(db)
+	enqueuedAt := time.Date(2025, 1, 1, 11, 0, 0, 0, time.UTC)
+	failedAt := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
 	job := NotificationJob{
 		RecipientUserID: "user-789",
 		To:              "failed@example.com",
 		Subject:         "Failed Job",
+		EnqueuedAt:      &enqueuedAt,
 	}
-	payload, err := json.Marshal(job)
+	failure := DeliveryFailure{
+		Reason:         "550 mailbox unavailable",
+		ErrorClass:     "smtp_5xx",
+		Attempts:       1,
+		AttemptHistory: []DeliveryAttempt{{Attempt: 1, At: failedAt, Error: "550 mailbox unavailable"}},
+		WorkerHost:     "worker-1",
+		FailedAt:       failedAt,
+	}
+	payload, err := json.Marshal(DeadLetterEntry{Job: job, DeliveryFailure: failure, EnqueuedAt: &enqueuedAt})
 	require.NoError(t, err)
 	mock.ExpectLPush(NotificationDLQKey, payload).SetVal(1)
-	err = queueService.EnqueueToDLQ(context.Background(), job)
+	err = queueService.EnqueueToDLQ(context.Background(), job, failure)
 	assert.NoError(t, err, "EnqueueToDLQ seharusnya tidak menghasilkan error")
 	assert.NoError(t, mock.ExpectationsWereMet(), "Ekspektasi mock tidak terpenuhi")
 }
+
+var strictLaneKeys = []string{
+	NotificationQueueKey + ":critical",
+	NotificationQueueKey + ":high",
+	NotificationQueueKey,
+	NotificationQueueKey + ":bulk",
+}
+
+func TestEnqueue_RoutesByPriority(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	queueService := NewQueueService(db)
+	job := NotificationJob{To: "a@example.com", TemplateName: "password_reset.html", Priority: "critical"}
+	payload, err := json.Marshal(job)
+	require.NoError(t, err)
+
+	mock.ExpectLPush(NotificationQueueKey+":critical", payload).SetVal(1)
+
+	require.NoError(t, queueService.Enqueue(context.Background(), job))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDequeue_WeightedPolicyChecksPickedLaneFirst(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	queueService := NewQueueService(db).WithLanePolicy(LanePolicy{Weighted: true})
+	// Bobot default 8/4/2/1: pick 0.99 jatuh ke lane bulk.
+	queueService.pick = func() float64 { return 0.99 }
+	payload := `{"to":"a@example.com","priority":"bulk"}`
+
+	mock.ExpectBRPop(5*time.Second,
+		NotificationQueueKey+":bulk",
+		NotificationQueueKey+":critical",
+		NotificationQueueKey+":high",
+		NotificationQueueKey,
+	).SetVal([]string{NotificationQueueKey + ":bulk", payload})
+
+	job, err := queueService.Dequeue(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "bulk", job.Priority)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReliableDequeue_BlocksOnFirstLaneWhenAllEmpty(t *testing.T) {
+	queueService, mock, _ := newTestReliableQueue(t)
+	processingKey := NotificationProcessingKeyPrefix + "worker-1"
+	for _, key := range strictLaneKeys {
+		mock.ExpectLMove(key, processingKey, "RIGHT", "LEFT").SetErr(redis.Nil)
+	}
+	mock.ExpectBLMove(strictLaneKeys[0], processingKey, "RIGHT", "LEFT", time.Second).SetErr(redis.Nil)
+
+	_, err := queueService.Dequeue(context.Background())
+	assert.ErrorIs(t, err, redis.Nil)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func newTestReliableQueue(t *testing.T) (*QueueService, redismock.ClientMock, time.Time) {
+	t.Helper()
+	db, mock := redismock.NewClientMock()
+	queueService := NewReliableQueueService(db, "worker-1", time.Minute)
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	queueService.now = func() time.Time { return now }
+	return queueService, mock, now
+}
+
+func TestReliableDequeue_MovesToProcessingList(t *testing.T) {
+	queueService, mock, now := newTestReliableQueue(t)
+	job := NotificationJob{RecipientUserID: "user-1", To: "a@example.com", Subject: "Hi"}
+	payload, err := json.Marshal(job)
+	require.NoError(t, err)
+
+	processingKey := NotificationProcessingKeyPrefix + "worker-1"
+	// Lane critical dan high kosong, job diambil dari lane normal.
+	mock.ExpectLMove(LaneKey(PriorityCritical), processingKey, "RIGHT", "LEFT").SetErr(redis.Nil)
+	mock.ExpectLMove(LaneKey(PriorityHigh), processingKey, "RIGHT", "LEFT").SetErr(redis.Nil)
+	mock.ExpectLMove(NotificationQueueKey, processingKey, "RIGHT", "LEFT").SetVal(string(payload))
+	mock.ExpectZAdd(NotificationInflightKey, redis.Z{
+		Score:  float64(now.Add(time.Minute).UnixMilli()),
+		Member: string(payload),
+	}).SetVal(1)
+	mock.ExpectSAdd(NotificationProcessingListsKey, processingKey).SetVal(1)
+
+	dequeuedJob, err := queueService.Dequeue(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, job.RecipientUserID, dequeuedJob.RecipientUserID)
+	assert.Equal(t, string(payload), dequeuedJob.receipt, "Payload mentah harus disimpan untuk Ack")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDequeue_QuarantinesUndecodablePayload(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	queueService := NewQueueService(db)
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	queueService.now = func() time.Time { return now }
+
+	mock.ExpectBRPop(5*time.Second, strictLaneKeys...).SetVal([]string{NotificationQueueKey, "not-json"})
+	expectQuarantine(t, mock, now, QuarantineSourceList, "not-json")
+
+	job, err := queueService.Dequeue(context.Background())
+	assert.Nil(t, job)
+	assert.ErrorIs(t, err, ErrPoisonMessage)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReliableDequeue_QuarantinesUndecodablePayload(t *testing.T) {
+	queueService, mock, now := newTestReliableQueue(t)
+	payload := `{"to":42}`
+	processingKey := NotificationProcessingKeyPrefix + "worker-1"
+	mock.ExpectLMove(LaneKey(PriorityCritical), processingKey, "RIGHT", "LEFT").SetVal(payload)
+	expectQuarantine(t, mock, now, QuarantineSourceList, payload)
+	mock.ExpectLRem(processingKey, 1, payload).SetVal(1)
+
+	_, err := queueService.Dequeue(context.Background())
+	assert.ErrorIs(t, err, ErrPoisonMessage)
+	assert.NoError(t, mock.ExpectationsWereMet(), "payload rusak tidak boleh diberi deadline in-flight")
+}
+
+func TestDequeue_ReturnsNewerVersionJobToFarEndOfLane(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	queueService := NewQueueService(db)
+	newer := `{"version":99,"to":"a@example.com"}`
+	older, err := json.Marshal(NotificationJob{ID: "n-2", To: "b@example.com"})
+	require.NoError(t, err)
+
+	mock.ExpectBRPop(5*time.Second, strictLaneKeys...).SetVal([]string{LaneKey(PriorityHigh), newer})
+	mock.ExpectLPush(LaneKey(PriorityHigh), newer).SetVal(2)
+	// Dequeue berikutnya membaca ujung kanan, jadi job lain yang keluar.
+	mock.ExpectBRPop(5*time.Second, strictLaneKeys...).SetVal([]string{LaneKey(PriorityHigh), string(older)})
+
+	_, err = queueService.Dequeue(context.Background())
+	assert.ErrorIs(t, err, ErrUnsupportedJobVersion)
+	assert.NotErrorIs(t, err, ErrPoisonMessage)
+
+	job, err := queueService.Dequeue(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "n-2", job.ID)
+	assert.NoError(t, mock.ExpectationsWereMet(), "job versi baru tidak boleh dikarantina")
+}
+
+func TestReliableDequeue_LeavesNewerVersionJobForReaper(t *testing.T) {
+	queueService, mock, now := newTestReliableQueue(t)
+	payload := `{"version":99,"to":"a@example.com"}`
+	processingKey := NotificationProcessingKeyPrefix + "worker-1"
+	mock.ExpectLMove(LaneKey(PriorityCritical), processingKey, "RIGHT", "LEFT").SetVal(payload)
+	mock.ExpectZAdd(NotificationInflightKey, redis.Z{Score: float64(now.Add(time.Minute).UnixMilli()), Member: payload}).SetVal(1)
+	mock.ExpectSAdd(NotificationProcessingListsKey, processingKey).SetVal(1)
+
+	_, err := queueService.Dequeue(context.Background())
+	assert.ErrorIs(t, err, ErrUnsupportedJobVersion)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReliableAck_RemovesFromProcessingList(t *testing.T) {
+	queueService, mock, _ := newTestReliableQueue(t)
+	job := &NotificationJob{To: "a@example.com", receipt: `{"to":"a@example.com"}`}
+
+	mock.ExpectLRem(NotificationProcessingKeyPrefix+"worker-1", 1, job.receipt).SetVal(1)
+	mock.ExpectZRem(NotificationInflightKey, job.receipt).SetVal(1)
+
+	require.NoError(t, queueService.Ack(context.Background(), job))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReliableNack_ReturnsJobToQueue(t *testing.T) {
+	queueService, mock, _ := newTestReliableQueue(t)
+	job := &NotificationJob{To: "a@example.com", receipt: `{"to":"a@example.com"}`}
+
+	keys := []string{NotificationProcessingKeyPrefix + "worker-1", NotificationInflightKey, NotificationQueueKey}
+	mock.ExpectEvalSha(nackScript.Hash(), keys, job.receipt).SetVal(int64(1))
+
+	require.NoError(t, queueService.Nack(context.Background(), job))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReapExpired(t *testing.T) {
+	queueService, mock, now := newTestReliableQueue(t)
+
+	keys := []string{NotificationProcessingListsKey, NotificationInflightKey, NotificationQueueKey}
+	mock.ExpectEvalSha(reapScript.Hash(), keys, now.UnixMilli(), now.Add(time.Minute).UnixMilli()).SetVal(int64(2))
+
+	n, err := queueService.ReapExpired(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAck_NoopWithoutReliableMode(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	queueService := NewQueueService(db)
+
+	err := queueService.Ack(context.Background(), &NotificationJob{To: "a@example.com", receipt: "x"})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet(), "Ack tanpa mode reliable tidak boleh menyentuh Redis")
+}
@@ -0,0 +1,364 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/robfig/cron/v3"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	NotificationSchedulesKey       = "notification_schedules"     // hash: id -> RecurringSchedule
+	NotificationSchedulesDueKey    = "notification_schedules_due" // zset: id -> next run (ms)
+	NotificationSchedulesLeaderKey = "notification_schedules_leader"
+)
+
+var ErrScheduleNotFound = errors.New("schedule not found")
+
+// RecurringSchedule adalah jadwal berulang berbasis ekspresi cron. Setiap
+// kali jatuh tempo, Job disalin menjadi NotificationJob baru.
+type RecurringSchedule struct {
+	ID        string          `json:"id"`
+	Cron      string          `json:"cron"`
+	Timezone  string          `json:"timezone,omitempty"`
+	Job       NotificationJob `json:"job"`
+	NextRunAt time.Time       `json:"next_run_at"`
+	LastRunAt *time.Time      `json:"last_run_at,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// ParseCron memvalidasi ekspresi cron standar (5 field atau deskriptor
+// seperti @weekly) pada zona waktu tertentu (default UTC).
+func ParseCron(expr, timezone string) (cron.Schedule, error) {
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(timezone); err != nil {
+		return nil, fmt.Errorf("timezone tidak valid %q: %w", timezone, err)
+	}
+	sched, err := cron.ParseStandard(fmt.Sprintf("CRON_TZ=%s %s", timezone, expr))
+	if err != nil {
+		return nil, fmt.Errorf("ekspresi cron tidak valid %q: %w", expr, err)
+	}
+	return sched, nil
+}
+
+type ScheduleStore interface {
+	Create(ctx context.Context, schedule RecurringSchedule) (*RecurringSchedule, error)
+	Get(ctx context.Context, id string) (*RecurringSchedule, error)
+	List(ctx context.Context) ([]RecurringSchedule, error)
+	Update(ctx context.Context, schedule RecurringSchedule) (*RecurringSchedule, error)
+	Delete(ctx context.Context, id string) error
+}
+
+type ScheduleService struct {
+	redisClient *redis.Client
+	now         func() time.Time
+}
+
+var _ ScheduleStore = (*ScheduleService)(nil)
+
+func NewScheduleService(redisClient *redis.Client) *ScheduleService {
+	return &ScheduleService{redisClient: redisClient, now: time.Now}
+}
+
+func (s *ScheduleService) Create(ctx context.Context, schedule RecurringSchedule) (*RecurringSchedule, error) {
+	now := s.now().UTC()
+	schedule.ID = uuid.NewString()
+	schedule.CreatedAt = now
+	schedule.UpdatedAt = now
+	raw, err := s.encode(&schedule)
+	if err != nil {
+		return nil, err
+	}
+	_, err = s.redisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, NotificationSchedulesKey, schedule.ID, raw)
+		pipe.ZAdd(ctx, NotificationSchedulesDueKey, redis.Z{Score: float64(schedule.NextRunAt.UnixMilli()), Member: schedule.ID})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+func (s *ScheduleService) Get(ctx context.Context, id string) (*RecurringSchedule, error) {
+	schedule, _, err := s.load(ctx, id)
+	return schedule, err
+}
+
+// load membaca schedule beserta payload mentahnya, dipakai replace sebagai
+// pembanding.
+func (s *ScheduleService) load(ctx context.Context, id string) (*RecurringSchedule, string, error) {
+	raw, err := s.redisClient.HGet(ctx, NotificationSchedulesKey, id).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, "", ErrScheduleNotFound
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	var schedule RecurringSchedule
+	if err := json.Unmarshal([]byte(raw), &schedule); err != nil {
+		return nil, "", fmt.Errorf("gagal decode schedule %s: %w", id, err)
+	}
+	return &schedule, raw, nil
+}
+
+func (s *ScheduleService) List(ctx context.Context) ([]RecurringSchedule, error) {
+	all, err := s.redisClient.HGetAll(ctx, NotificationSchedulesKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	schedules := make([]RecurringSchedule, 0, len(all))
+	for id, raw := range all {
+		var schedule RecurringSchedule
+		if err := json.Unmarshal([]byte(raw), &schedule); err != nil {
+			log.Error().Err(err).Str("schedule_id", id).Msg("Skipping undecodable schedule")
+			continue
+		}
+		schedules = append(schedules, schedule)
+	}
+	return schedules, nil
+}
+
+// scheduleUpdateAttempts membatasi percobaan ulang Update saat schedule
+// berubah di antara baca dan tulis (mis. oleh Tick).
+const scheduleUpdateAttempts = 3
+
+func (s *ScheduleService) Update(ctx context.Context, schedule RecurringSchedule) (*RecurringSchedule, error) {
+	for attempt := 1; ; attempt++ {
+		existing, raw, err := s.load(ctx, schedule.ID)
+		if err != nil {
+			return nil, err
+		}
+		schedule.CreatedAt = existing.CreatedAt
+		schedule.LastRunAt = existing.LastRunAt
+		schedule.UpdatedAt = s.now().UTC()
+		err = s.replace(ctx, &schedule, raw)
+		if errors.Is(err, errScheduleChanged) && attempt < scheduleUpdateAttempts {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &schedule, nil
+	}
+}
+
+func (s *ScheduleService) Delete(ctx context.Context, id string) error {
+	var removed *redis.IntCmd
+	_, err := s.redisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		removed = pipe.HDel(ctx, NotificationSchedulesKey, id)
+		pipe.ZRem(ctx, NotificationSchedulesDueKey, id)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if removed.Val() == 0 {
+		return ErrScheduleNotFound
+	}
+	return nil
+}
+
+// encode memvalidasi cron, menghitung NextRunAt lalu men-encode schedule.
+func (s *ScheduleService) encode(schedule *RecurringSchedule) ([]byte, error) {
+	sched, err := ParseCron(schedule.Cron, schedule.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	schedule.Job.SendAt = nil
+	schedule.NextRunAt = sched.Next(s.now()).UTC()
+	return json.Marshal(schedule)
+}
+
+// errScheduleChanged berarti schedule diubah proses lain sejak dibaca.
+var errScheduleChanged = errors.New("schedule changed concurrently")
+
+// replaceScheduleScript menulis schedule dan indeks due hanya jika entri
+// hash masih ada dan isinya sama persis dengan yang dibaca caller (termasuk
+// updated_at). Delete atau Update yang terjadi di antaranya tidak tertimpa.
+var replaceScheduleScript = redis.NewScript(`
+local current = redis.call('HGET', KEYS[1], ARGV[1])
+if not current then
+	return 0
+end
+if current ~= ARGV[2] then
+	return -1
+end
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[3])
+redis.call('ZADD', KEYS[2], ARGV[4], ARGV[1])
+return 1
+`)
+
+// replace menulis schedule jika entri yang tersimpan masih sama dengan
+// expected. Mengembalikan ErrScheduleNotFound jika schedule sudah dihapus
+// dan errScheduleChanged jika sudah diubah.
+func (s *ScheduleService) replace(ctx context.Context, schedule *RecurringSchedule, expected string) error {
+	raw, err := s.encode(schedule)
+	if err != nil {
+		return err
+	}
+	result, err := replaceScheduleScript.Run(ctx, s.redisClient, []string{NotificationSchedulesKey, NotificationSchedulesDueKey},
+		schedule.ID, expected, raw, schedule.NextRunAt.UnixMilli()).Int()
+	if err != nil {
+		return err
+	}
+	switch result {
+	case 0:
+		return ErrScheduleNotFound
+	case -1:
+		return errScheduleChanged
+	}
+	return nil
+}
+
+// RecurringScheduler mematerialisasi RecurringSchedule menjadi NotificationJob.
+// Hanya satu replika (pemegang lock Redis) yang aktif pada satu waktu.
+type RecurringScheduler struct {
+	store   *ScheduleService
+	queue   Queue
+	status  StatusStore // opsional
+	owner   string
+	lockTTL time.Duration
+	newID   func() string
+}
+
+func NewRecurringScheduler(store *ScheduleService, queue Queue, status StatusStore, owner string) *RecurringScheduler {
+	return &RecurringScheduler{
+		store:   store,
+		queue:   queue,
+		status:  status,
+		owner:   owner,
+		lockTTL: 30 * time.Second,
+		newID:   uuid.NewString,
+	}
+}
+
+// renewLockScript memperpanjang lock hanya jika masih dimiliki replika ini.
+var renewLockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// acquireLeadership mengambil atau memperpanjang lock leader.
+func (r *RecurringScheduler) acquireLeadership(ctx context.Context) (bool, error) {
+	renewed, err := renewLockScript.Run(ctx, r.store.redisClient, []string{NotificationSchedulesLeaderKey},
+		r.owner, r.lockTTL.Milliseconds()).Int64()
+	if err != nil {
+		return false, err
+	}
+	if renewed == 1 {
+		return true, nil
+	}
+	return r.store.redisClient.SetNX(ctx, NotificationSchedulesLeaderKey, r.owner, r.lockTTL).Result()
+}
+
+// Tick mematerialisasi semua jadwal yang jatuh tempo dan mengembalikan
+// jumlah job yang dibuat. Jadwal yang terlewat (mis. saat service mati)
+// hanya dijalankan sekali lalu dilanjutkan dari waktu sekarang.
+func (r *RecurringScheduler) Tick(ctx context.Context) (int, error) {
+	now := r.store.now()
+	ids, err := r.store.redisClient.ZRangeByScore(ctx, NotificationSchedulesDueKey, &redis.ZRangeBy{
+		Min: "-inf",
+		Max: fmt.Sprintf("%d", now.UnixMilli()),
+	}).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	created := 0
+	for _, id := range ids {
+		schedule, raw, err := r.store.load(ctx, id)
+		if errors.Is(err, ErrScheduleNotFound) {
+			r.store.redisClient.ZRem(ctx, NotificationSchedulesDueKey, id)
+			continue
+		}
+		if err != nil {
+			return created, err
+		}
+
+		// Setiap eksekusi adalah notifikasi tersendiri dengan ID dan status sendiri.
+		job := schedule.Job
+		job.ID = r.newID()
+		enqueuedAt := now.UTC()
+		job.EnqueuedAt = &enqueuedAt
+		if r.status != nil {
+			if err := r.status.Record(ctx, job.ID, StateQueued); err != nil {
+				log.Warn().Err(err).Str("notification_id", job.ID).Msg("Failed to record notification status")
+			}
+		}
+		if err := r.queue.Enqueue(ctx, job); err != nil {
+			// Schedule tetap jatuh tempo dan dicoba lagi pada tick berikutnya;
+			// schedule lain tidak boleh ikut tertahan.
+			log.Error().Err(err).Str("schedule_id", id).Msg("Failed to enqueue job from recurring schedule")
+			r.forgetStatus(ctx, job.ID)
+			continue
+		}
+		created++
+
+		// Hanya last_run_at dan next_run_at yang berubah; jika schedule dihapus
+		// atau diubah selama tick, perubahan itu yang dipertahankan.
+		runAt := now.UTC()
+		schedule.LastRunAt = &runAt
+		err = r.store.replace(ctx, schedule, raw)
+		if errors.Is(err, ErrScheduleNotFound) || errors.Is(err, errScheduleChanged) {
+			log.Info().Err(err).Str("schedule_id", id).Msg("Recurring schedule changed during tick, keeping the newer version")
+			continue
+		}
+		if err != nil {
+			return created, err
+		}
+	}
+	return created, nil
+}
+
+// forgetStatus menghapus status queued milik job yang gagal di-enqueue,
+// sama seperti handler untuk permintaan yang ditolak backpressure.
+func (r *RecurringScheduler) forgetStatus(ctx context.Context, id string) {
+	if r.status == nil {
+		return
+	}
+	if err := r.status.Delete(ctx, id); err != nil {
+		log.Warn().Err(err).Str("notification_id", id).Msg("Failed to delete status of unqueued notification")
+	}
+}
+
+// Run menjalankan Tick secara periodik selama replika ini menjadi leader.
+func (r *RecurringScheduler) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			leader, err := r.acquireLeadership(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Error().Err(err).Msg("Failed to acquire recurring scheduler lock")
+				}
+				continue
+			}
+			if !leader {
+				continue
+			}
+			n, err := r.Tick(ctx)
+			if err != nil && ctx.Err() == nil {
+				log.Error().Err(err).Msg("Failed to materialize recurring schedules")
+			}
+			if n > 0 {
+				log.Info().Int("count", n).Msg("Materialized jobs from recurring schedules")
+			}
+		}
+	}
+}
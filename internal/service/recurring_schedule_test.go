@@ -0,0 +1,245 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redismock/v9"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCron(t *testing.T) {
+	sched, err := ParseCron("0 9 * * 1", "Asia/Jakarta")
+	require.NoError(t, err)
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC) // Rabu
+	assert.Equal(t, time.Date(2025, 1, 6, 2, 0, 0, 0, time.UTC), sched.Next(from).UTC(), "Senin 09:00 WIB = 02:00 UTC")
+
+	_, err = ParseCron("bukan cron", "")
+	assert.Error(t, err)
+	_, err = ParseCron("@weekly", "Mars/Olympus")
+	assert.Error(t, err)
+}
+
+func TestScheduleDelete_NotFound(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	store := NewScheduleService(db)
+
+	mock.ExpectTxPipeline()
+	mock.ExpectHDel(NotificationSchedulesKey, "missing").SetVal(0)
+	mock.ExpectZRem(NotificationSchedulesDueKey, "missing").SetVal(0)
+	mock.ExpectTxPipelineExec()
+
+	assert.ErrorIs(t, store.Delete(context.Background(), "missing"), ErrScheduleNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRecurringSchedulerTick_MaterializesDueSchedule(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	store := NewScheduleService(db)
+	now := time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+	scheduler := NewRecurringScheduler(store, NewQueueService(db), nil, "replica-1")
+	scheduler.newID = func() string { return "notif-1" }
+
+	schedule := RecurringSchedule{
+		ID:        "weekly-digest",
+		Cron:      "0 9 * * 1",
+		Job:       NotificationJob{RecipientUserID: "user-1", To: "a@example.com", Subject: "Digest", TemplateName: "digest.html"},
+		NextRunAt: now,
+	}
+	raw, err := json.Marshal(schedule)
+	require.NoError(t, err)
+	job := schedule.Job
+	job.ID = "notif-1"
+	job.EnqueuedAt = &now
+	jobPayload, err := json.Marshal(job)
+	require.NoError(t, err)
+
+	// Setelah dijalankan: LastRunAt diisi dan NextRunAt maju satu minggu.
+	updated := schedule
+	runAt := now
+	updated.LastRunAt = &runAt
+	updated.NextRunAt = now.Add(7 * 24 * time.Hour)
+	updatedRaw, err := json.Marshal(updated)
+	require.NoError(t, err)
+
+	mock.ExpectZRangeByScore(NotificationSchedulesDueKey, &redis.ZRangeBy{Min: "-inf", Max: "1736154000000"}).SetVal([]string{"weekly-digest"})
+	mock.ExpectHGet(NotificationSchedulesKey, "weekly-digest").SetVal(string(raw))
+	mock.ExpectLPush(NotificationQueueKey, jobPayload).SetVal(1)
+	expectReplaceSchedule(mock, "weekly-digest", raw, updatedRaw, updated.NextRunAt).SetVal(int64(1))
+
+	n, err := scheduler.Tick(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func expectReplaceSchedule(mock redismock.ClientMock, id string, expected, raw []byte, next time.Time) *redismock.ExpectedCmd {
+	return mock.ExpectEvalSha(replaceScheduleScript.Hash(), []string{NotificationSchedulesKey, NotificationSchedulesDueKey},
+		id, string(expected), raw, next.UnixMilli())
+}
+
+func TestRecurringSchedulerTick_ScheduleDeletedDuringTickStaysDeleted(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	store := NewScheduleService(db)
+	now := time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+	scheduler := NewRecurringScheduler(store, NewQueueService(db), nil, "replica-1")
+	scheduler.newID = func() string { return "notif-1" }
+
+	schedule := RecurringSchedule{ID: "daily", Cron: "0 9 * * *", Job: NotificationJob{To: "a@example.com"}, NextRunAt: now}
+	raw, err := json.Marshal(schedule)
+	require.NoError(t, err)
+	job := schedule.Job
+	job.ID = "notif-1"
+	job.EnqueuedAt = &now
+	jobPayload, err := json.Marshal(job)
+	require.NoError(t, err)
+	updated := schedule
+	updated.LastRunAt = &now
+	updated.NextRunAt = now.Add(24 * time.Hour)
+	updatedRaw, err := json.Marshal(updated)
+	require.NoError(t, err)
+
+	mock.ExpectZRangeByScore(NotificationSchedulesDueKey, &redis.ZRangeBy{Min: "-inf", Max: "1736154000000"}).SetVal([]string{"daily"})
+	mock.ExpectHGet(NotificationSchedulesKey, "daily").SetVal(string(raw))
+	mock.ExpectLPush(NotificationQueueKey, jobPayload).SetVal(1)
+	// DELETE /schedules/daily masuk setelah schedule dibaca: skrip tidak
+	// menulis apa pun sehingga schedule tidak muncul kembali.
+	expectReplaceSchedule(mock, "daily", raw, updatedRaw, updated.NextRunAt).SetVal(int64(0))
+
+	n, err := scheduler.Tick(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestScheduleUpdate_RetriesWhenScheduleChangedConcurrently(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	store := NewScheduleService(db)
+	now := time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+	created := now.Add(-time.Hour)
+
+	before := RecurringSchedule{ID: "daily", Cron: "0 9 * * *", CreatedAt: created, UpdatedAt: created}
+	beforeRaw, err := json.Marshal(before)
+	require.NoError(t, err)
+	// Tick menulis last_run_at di antara baca dan tulis Update.
+	ticked := before
+	ticked.LastRunAt = &now
+	tickedRaw, err := json.Marshal(ticked)
+	require.NoError(t, err)
+
+	update := RecurringSchedule{ID: "daily", Cron: "0 10 * * *"}
+	want := update
+	want.CreatedAt = created
+	want.UpdatedAt = now
+	want.NextRunAt = time.Date(2025, 1, 6, 10, 0, 0, 0, time.UTC)
+	staleRaw, err := json.Marshal(want)
+	require.NoError(t, err)
+	want.LastRunAt = &now
+	wantRaw, err := json.Marshal(want)
+	require.NoError(t, err)
+
+	mock.ExpectHGet(NotificationSchedulesKey, "daily").SetVal(string(beforeRaw))
+	expectReplaceSchedule(mock, "daily", beforeRaw, staleRaw, want.NextRunAt).SetVal(int64(-1))
+	mock.ExpectHGet(NotificationSchedulesKey, "daily").SetVal(string(tickedRaw))
+	expectReplaceSchedule(mock, "daily", tickedRaw, wantRaw, want.NextRunAt).SetVal(int64(1))
+
+	got, err := store.Update(context.Background(), update)
+	require.NoError(t, err)
+	assert.Equal(t, &now, got.LastRunAt, "last_run_at dari Tick tidak boleh hilang")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestScheduleUpdate_DeletedScheduleIsNotRecreated(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	store := NewScheduleService(db)
+	now := time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+
+	before := RecurringSchedule{ID: "daily", Cron: "0 9 * * *", CreatedAt: now, UpdatedAt: now}
+	beforeRaw, err := json.Marshal(before)
+	require.NoError(t, err)
+	update := RecurringSchedule{ID: "daily", Cron: "0 10 * * *", CreatedAt: now, UpdatedAt: now,
+		NextRunAt: time.Date(2025, 1, 6, 10, 0, 0, 0, time.UTC)}
+	updateRaw, err := json.Marshal(update)
+	require.NoError(t, err)
+
+	mock.ExpectHGet(NotificationSchedulesKey, "daily").SetVal(string(beforeRaw))
+	expectReplaceSchedule(mock, "daily", beforeRaw, updateRaw, update.NextRunAt).SetVal(int64(0))
+
+	_, err = store.Update(context.Background(), RecurringSchedule{ID: "daily", Cron: "0 10 * * *"})
+	assert.ErrorIs(t, err, ErrScheduleNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// recordingStatus mencatat status yang ditulis dan dihapus Tick.
+type recordingStatus struct {
+	StatusStore
+	recorded []string
+	deleted  []string
+}
+
+func (s *recordingStatus) Record(ctx context.Context, id string, state DeliveryState) error {
+	s.recorded = append(s.recorded, id)
+	return nil
+}
+
+func (s *recordingStatus) Delete(ctx context.Context, id string) error {
+	s.deleted = append(s.deleted, id)
+	return nil
+}
+
+func TestRecurringSchedulerTick_FailedEnqueueDoesNotBlockOtherSchedules(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	store := NewScheduleService(db)
+	now := time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+	status := &recordingStatus{}
+	scheduler := NewRecurringScheduler(store, NewQueueService(db), status, "replica-1")
+	ids := []string{"notif-1", "notif-2"}
+	scheduler.newID = func() string {
+		id := ids[0]
+		ids = ids[1:]
+		return id
+	}
+
+	mock.ExpectZRangeByScore(NotificationSchedulesDueKey, &redis.ZRangeBy{Min: "-inf", Max: "1736154000000"}).SetVal([]string{"first", "second"})
+	for i, id := range []string{"first", "second"} {
+		schedule := RecurringSchedule{ID: id, Cron: "0 9 * * *", Job: NotificationJob{To: id + "@example.com"}, NextRunAt: now}
+		raw, err := json.Marshal(schedule)
+		require.NoError(t, err)
+		job := schedule.Job
+		job.ID = fmt.Sprintf("notif-%d", i+1)
+		job.EnqueuedAt = &now
+		jobPayload, err := json.Marshal(job)
+		require.NoError(t, err)
+
+		mock.ExpectHGet(NotificationSchedulesKey, id).SetVal(string(raw))
+		if id == "first" {
+			mock.ExpectLPush(NotificationQueueKey, jobPayload).SetErr(errors.New("queue down"))
+			continue
+		}
+		mock.ExpectLPush(NotificationQueueKey, jobPayload).SetVal(1)
+		updated := schedule
+		updated.LastRunAt = &now
+		updated.NextRunAt = now.Add(24 * time.Hour)
+		updatedRaw, err := json.Marshal(updated)
+		require.NoError(t, err)
+		expectReplaceSchedule(mock, id, raw, updatedRaw, updated.NextRunAt).SetVal(int64(1))
+	}
+
+	n, err := scheduler.Tick(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, []string{"notif-1", "notif-2"}, status.recorded)
+	assert.Equal(t, []string{"notif-1"}, status.deleted, "status job yang gagal di-enqueue tidak boleh tertinggal")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,304 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	NotificationScheduledKey = "notification_scheduled"
+	// NotificationScheduledClaimedKey menyimpan job yang sedang dipromosikan
+	// (skor = batas waktu klaim dalam milidetik).
+	NotificationScheduledClaimedKey = "notification_scheduled_claimed"
+	// NotificationScheduledIndexKey adalah hash ID job -> payload terjadwal,
+	// dipakai CancelScheduled untuk menghapus job tanpa memindai jadwal.
+	NotificationScheduledIndexKey = "notification_scheduled_index"
+)
+
+// scheduledClaimTimeout adalah batas waktu promosi satu batch sebelum job
+// yang diklaim dikembalikan ke jadwal.
+const scheduledClaimTimeout = time.Minute
+
+// ScheduledQueue membungkus Queue lain dan memarkir job dengan SendAt di masa
+// depan pada sorted set (skor = waktu kirim dalam milidetik). Run memindahkan
+// job yang sudah jatuh tempo ke antrian di bawahnya.
+type ScheduledQueue struct {
+	Queue
+	redisClient  *redis.Client
+	batchSize    int64
+	claimTimeout time.Duration
+	now          func() time.Time
+}
+
+var _ Queue = (*ScheduledQueue)(nil)
+
+func NewScheduledQueue(redisClient *redis.Client, inner Queue) *ScheduledQueue {
+	return &ScheduledQueue{
+		Queue:        inner,
+		redisClient:  redisClient,
+		batchSize:    100,
+		claimTimeout: scheduledClaimTimeout,
+		now:          time.Now,
+	}
+}
+
+func (q *ScheduledQueue) Enqueue(ctx context.Context, job NotificationJob) error {
+	if job.SendAt == nil || !job.SendAt.After(q.now()) {
+		return q.Queue.Enqueue(ctx, job)
+	}
+	_, err := q.redisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		return q.schedule(ctx, pipe, job)
+	})
+	return err
+}
+
+func (q *ScheduledQueue) EnqueueBatch(ctx context.Context, jobs []NotificationJob) error {
+	return enqueueBatch(ctx, q.redisClient, q, jobs)
+}
+
+func (q *ScheduledQueue) enqueueTo(ctx context.Context, pipe redis.Pipeliner, job NotificationJob) error {
+	if job.SendAt == nil || !job.SendAt.After(q.now()) {
+		inner, err := innerEnqueuer(q.Queue)
+		if err != nil {
+			return err
+		}
+		return inner.enqueueTo(ctx, pipe, job)
+	}
+	return q.schedule(ctx, pipe, job)
+}
+
+// schedule menambahkan job ke jadwal beserta entri indeks ID-nya.
+func (q *ScheduledQueue) schedule(ctx context.Context, pipe redis.Pipeliner, job NotificationJob) error {
+	payload, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	pipe.ZAdd(ctx, NotificationScheduledKey, redis.Z{
+		Score:  float64(job.SendAt.UnixMilli()),
+		Member: payload,
+	})
+	if job.ID != "" {
+		pipe.HSet(ctx, NotificationScheduledIndexKey, job.ID, payload)
+	}
+	return nil
+}
+
+// Depth meneruskan kedalaman antrian dari backend di bawahnya. Job yang
+// masih terjadwal tidak dihitung.
+func (q *ScheduledQueue) Depth(ctx context.Context) (int64, error) {
+	return queueDepth(ctx, q.Queue)
+}
+
+// ScheduleCanceller diimplementasikan backend yang bisa mengeluarkan job
+// terjadwal dari antrian sebelum jatuh tempo.
+type ScheduleCanceller interface {
+	// CancelScheduled menghapus job terjadwal dengan ID tersebut (termasuk
+	// salinan retry yang sedang menunggu) dan mengembalikan job yang dihapus.
+	CancelScheduled(ctx context.Context, id string) ([]NotificationJob, error)
+}
+
+var _ ScheduleCanceller = (*ScheduledQueue)(nil)
+
+// cancelScheduledScript menghapus payload yang ditunjuk indeks ID dari
+// jadwal. Payload yang sudah diklaim PromoteDue tidak ikut terhapus.
+var cancelScheduledScript = redis.NewScript(`
+local payload = redis.call('HGET', KEYS[2], ARGV[1])
+if not payload then
+	return false
+end
+if redis.call('ZREM', KEYS[1], payload) == 0 then
+	return false
+end
+redis.call('HDEL', KEYS[2], ARGV[1])
+return payload
+`)
+
+// CancelScheduled mencari payload lewat indeks ID lalu menghapusnya dari
+// jadwal. Job yang keburu dipromosikan tidak ikut terhapus; worker akan
+// melewatinya karena statusnya sudah cancelled.
+func (q *ScheduledQueue) CancelScheduled(ctx context.Context, id string) ([]NotificationJob, error) {
+	if id == "" {
+		return nil, nil
+	}
+	payload, err := cancelScheduledScript.Run(ctx, q.redisClient,
+		[]string{NotificationScheduledKey, NotificationScheduledIndexKey}, id).Text()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	job, err := decodeJob(payload)
+	if err != nil {
+		// Payload sudah terhapus dari jadwal; cukup laporkan bahwa ada yang dibatalkan.
+		log.Warn().Err(err).Str("id", id).Msg("Cancelled scheduled job with an undecodable payload")
+		return []NotificationJob{{ID: id}}, nil
+	}
+	job.receipt = ""
+	return []NotificationJob{*job}, nil
+}
+
+// HoldForRetry meneruskan ke backend di bawahnya jika didukung.
+func (q *ScheduledQueue) HoldForRetry(ctx context.Context, job *NotificationJob, retry NotificationJob) (bool, error) {
+	holder, ok := q.Queue.(RetryHolder)
+	if !ok {
+		return false, nil
+	}
+	return holder.HoldForRetry(ctx, job, retry)
+}
+
+// claimDueScript memindahkan job jatuh tempo secara atomik dari
+// notification_scheduled ke notification_scheduled_claimed (skor = batas
+// waktu klaim), sehingga beberapa replika scheduler tidak mempromosikan job
+// yang sama. Job baru dihapus dari set klaim setelah berhasil di-enqueue;
+// klaim yang melewati batas waktu (scheduler mati di tengah promosi)
+// dikembalikan ke jadwal lebih dulu agar ikut diambil.
+var claimDueScript = redis.NewScript(`
+local stale = redis.call('ZRANGEBYSCORE', KEYS[2], '-inf', ARGV[1])
+for _, payload in ipairs(stale) do
+	redis.call('ZADD', KEYS[1], ARGV[1], payload)
+end
+if #stale > 0 then
+	redis.call('ZREM', KEYS[2], unpack(stale))
+end
+local due = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+for _, payload in ipairs(due) do
+	redis.call('ZADD', KEYS[2], ARGV[3], payload)
+end
+if #due > 0 then
+	redis.call('ZREM', KEYS[1], unpack(due))
+end
+return due
+`)
+
+// PromoteDue memindahkan job yang sudah jatuh tempo ke antrian dan
+// mengembalikan jumlah job yang dipromosikan. Untuk backend Redis, enqueue
+// dan penghapusan dari set klaim terjadi dalam satu MULTI/EXEC; job tidak
+// hilang walaupun proses mati di tengah batch.
+func (q *ScheduledQueue) PromoteDue(ctx context.Context) (int, error) {
+	now := q.now()
+	due, err := claimDueScript.Run(ctx, q.redisClient, []string{NotificationScheduledKey, NotificationScheduledClaimedKey},
+		now.UnixMilli(), q.batchSize, now.Add(q.claimTimeout).UnixMilli()).StringSlice()
+	if err != nil {
+		return 0, err
+	}
+
+	promoted := 0
+	for i, payload := range due {
+		job, err := decodeJob(payload)
+		if errors.Is(err, ErrUnsupportedJobVersion) {
+			// Job dari worker yang lebih baru dibiarkan di set klaim dan
+			// kembali ke jadwal setelah claimTimeout untuk dipromosikan
+			// worker lain.
+			log.Warn().Err(err).Msg("Leaving scheduled job with a newer schema version for another worker")
+			continue
+		}
+		if err != nil {
+			if qErr := quarantine(ctx, q.redisClient, now, QuarantineSourceScheduled, payload, err); !errors.Is(qErr, ErrPoisonMessage) {
+				q.reschedule(ctx, now, due[i:])
+				return promoted, qErr
+			}
+			if err := q.redisClient.ZRem(ctx, NotificationScheduledClaimedKey, payload).Err(); err != nil {
+				log.Warn().Err(err).Msg("Failed to drop quarantined payload from the claimed set")
+			}
+			continue
+		}
+		job.receipt = ""
+		if err := q.promote(ctx, *job, payload); err != nil {
+			// Kembalikan sisa batch ke jadwal agar dicoba lagi pada tick berikutnya.
+			q.reschedule(ctx, now, due[i:])
+			return promoted, err
+		}
+		promoted++
+	}
+	return promoted, nil
+}
+
+// promote meng-enqueue job lalu menghapus payload-nya dari set klaim.
+func (q *ScheduledQueue) promote(ctx context.Context, job NotificationJob, payload string) error {
+	if inner, ok := q.Queue.(pipelineEnqueuer); ok {
+		_, err := q.redisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			if err := inner.enqueueTo(ctx, pipe, job); err != nil {
+				return err
+			}
+			q.unclaim(ctx, pipe, job.ID, payload)
+			return nil
+		})
+		return err
+	}
+	if err := q.Queue.Enqueue(ctx, job); err != nil {
+		return err
+	}
+	// Jika gagal, klaim kedaluwarsa dan job dipromosikan sekali lagi
+	// (at-least-once).
+	_, err := q.redisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		q.unclaim(ctx, pipe, job.ID, payload)
+		return nil
+	})
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to drop promoted job from the claimed set")
+	}
+	return nil
+}
+
+// unclaim menghapus payload yang sudah dipromosikan dari set klaim dan
+// indeks ID.
+func (q *ScheduledQueue) unclaim(ctx context.Context, pipe redis.Pipeliner, id, payload string) {
+	pipe.ZRem(ctx, NotificationScheduledClaimedKey, payload)
+	if id != "" {
+		pipe.HDel(ctx, NotificationScheduledIndexKey, id)
+	}
+}
+
+// reschedule mengembalikan payload dari set klaim ke jadwal.
+func (q *ScheduledQueue) reschedule(ctx context.Context, at time.Time, payloads []string) {
+	members := make([]redis.Z, 0, len(payloads))
+	claimed := make([]interface{}, 0, len(payloads))
+	for _, payload := range payloads {
+		members = append(members, redis.Z{Score: float64(at.UnixMilli()), Member: payload})
+		claimed = append(claimed, payload)
+	}
+	_, err := q.redisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAdd(ctx, NotificationScheduledKey, members...)
+		pipe.ZRem(ctx, NotificationScheduledClaimedKey, claimed...)
+		return nil
+	})
+	if err != nil {
+		// Klaim akan kedaluwarsa dan dikembalikan oleh claimDueScript.
+		log.Error().Err(err).Int("count", len(payloads)).Msg("Failed to return scheduled jobs to the schedule set")
+	}
+}
+
+// Run menjalankan PromoteDue secara periodik hingga ctx dibatalkan.
+func (q *ScheduledQueue) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for {
+				n, err := q.PromoteDue(ctx)
+				if err != nil {
+					if ctx.Err() == nil {
+						log.Error().Err(err).Msg("Failed to promote scheduled jobs")
+					}
+					break
+				}
+				if n > 0 {
+					log.Info().Int("count", n).Msg("Promoted scheduled jobs to the queue")
+				}
+				// Batch penuh berarti mungkin masih ada job jatuh tempo lain.
+				if int64(n) < q.batchSize {
+					break
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redismock/v9"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestScheduledQueue(t *testing.T) (*ScheduledQueue, redismock.ClientMock, time.Time) {
+	t.Helper()
+	db, mock := redismock.NewClientMock()
+	scheduled := NewScheduledQueue(db, NewQueueService(db))
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	scheduled.now = func() time.Time { return now }
+	return scheduled, mock, now
+}
+
+func TestScheduledEnqueue_FutureJobGoesToSortedSet(t *testing.T) {
+	scheduled, mock, now := newTestScheduledQueue(t)
+	sendAt := now.Add(24 * time.Hour)
+	job := NotificationJob{ID: "n-1", RecipientUserID: "approver-1", To: "a@example.com", SendAt: &sendAt}
+	payload, err := json.Marshal(job)
+	require.NoError(t, err)
+
+	// Jadwal dan indeks ID ditulis dalam satu transaksi.
+	mock.ExpectTxPipeline()
+	mock.ExpectZAdd(NotificationScheduledKey, redis.Z{Score: float64(sendAt.UnixMilli()), Member: payload}).SetVal(1)
+	mock.ExpectHSet(NotificationScheduledIndexKey, "n-1", payload).SetVal(1)
+	mock.ExpectTxPipelineExec()
+
+	require.NoError(t, scheduled.Enqueue(context.Background(), job))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestScheduledEnqueue_PastJobGoesStraightToQueue(t *testing.T) {
+	scheduled, mock, now := newTestScheduledQueue(t)
+	sendAt := now.Add(-time.Minute)
+	job := NotificationJob{RecipientUserID: "user-1", SendAt: &sendAt}
+	payload, err := json.Marshal(job)
+	require.NoError(t, err)
+
+	mock.ExpectLPush(NotificationQueueKey, payload).SetVal(1)
+
+	require.NoError(t, scheduled.Enqueue(context.Background(), job))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func expectClaimDue(mock redismock.ClientMock, now time.Time) *redismock.ExpectedCmd {
+	return mock.ExpectEvalSha(claimDueScript.Hash(), []string{NotificationScheduledKey, NotificationScheduledClaimedKey},
+		now.UnixMilli(), int64(100), now.Add(scheduledClaimTimeout).UnixMilli())
+}
+
+func TestPromoteDue(t *testing.T) {
+	scheduled, mock, now := newTestScheduledQueue(t)
+	sendAt := now.Add(-time.Second)
+	payload, err := json.Marshal(NotificationJob{ID: "n-1", RecipientUserID: "user-1", SendAt: &sendAt})
+	require.NoError(t, err)
+
+	expectClaimDue(mock, now).SetVal([]interface{}{string(payload)})
+	// Enqueue dan penghapusan dari set klaim serta indeks dalam satu transaksi.
+	mock.ExpectTxPipeline()
+	mock.ExpectLPush(NotificationQueueKey, payload).SetVal(1)
+	mock.ExpectZRem(NotificationScheduledClaimedKey, string(payload)).SetVal(1)
+	mock.ExpectHDel(NotificationScheduledIndexKey, "n-1").SetVal(1)
+	mock.ExpectTxPipelineExec()
+
+	n, err := scheduled.PromoteDue(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPromoteDue_FailedEnqueueReturnsJobsToSchedule(t *testing.T) {
+	scheduled, mock, now := newTestScheduledQueue(t)
+	first, _ := json.Marshal(NotificationJob{ID: "n-1"})
+	second, _ := json.Marshal(NotificationJob{ID: "n-2"})
+
+	expectClaimDue(mock, now).SetVal([]interface{}{string(first), string(second)})
+	mock.ExpectTxPipeline()
+	mock.ExpectLPush(NotificationQueueKey, first).SetErr(errors.New("connection reset"))
+	mock.ExpectTxPipeline()
+	mock.ExpectZAdd(NotificationScheduledKey,
+		redis.Z{Score: float64(now.UnixMilli()), Member: string(first)},
+		redis.Z{Score: float64(now.UnixMilli()), Member: string(second)}).SetVal(2)
+	mock.ExpectZRem(NotificationScheduledClaimedKey, string(first), string(second)).SetVal(2)
+	mock.ExpectTxPipelineExec()
+
+	n, err := scheduled.PromoteDue(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, 0, n)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPromoteDue_QuarantinesUndecodableJob(t *testing.T) {
+	scheduled, mock, now := newTestScheduledQueue(t)
+	payload, err := json.Marshal(NotificationJob{RecipientUserID: "user-1"})
+	require.NoError(t, err)
+
+	expectClaimDue(mock, now).SetVal([]interface{}{"{broken", string(payload)})
+	expectQuarantine(t, mock, now, QuarantineSourceScheduled, "{broken")
+	mock.ExpectZRem(NotificationScheduledClaimedKey, "{broken").SetVal(1)
+	mock.ExpectTxPipeline()
+	mock.ExpectLPush(NotificationQueueKey, payload).SetVal(1)
+	mock.ExpectZRem(NotificationScheduledClaimedKey, string(payload)).SetVal(1)
+	mock.ExpectTxPipelineExec()
+
+	n, err := scheduled.PromoteDue(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, n, "job rusak tidak boleh menghentikan promosi sisa batch")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCancelScheduled_RemovesIndexedJob(t *testing.T) {
+	q, mock, now := newTestScheduledQueue(t)
+	sendAt := now.Add(time.Hour)
+	target, _ := json.Marshal(NotificationJob{ID: "n-1", To: "a@example.com", SendAt: &sendAt})
+
+	mock.ExpectEvalSha(cancelScheduledScript.Hash(),
+		[]string{NotificationScheduledKey, NotificationScheduledIndexKey}, "n-1").SetVal(string(target))
+
+	removed, err := q.CancelScheduled(context.Background(), "n-1")
+	require.NoError(t, err)
+	require.Len(t, removed, 1)
+	assert.Equal(t, "a@example.com", removed[0].To)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCancelScheduled_NothingScheduled(t *testing.T) {
+	q, mock, _ := newTestScheduledQueue(t)
+	mock.ExpectEvalSha(cancelScheduledScript.Hash(),
+		[]string{NotificationScheduledKey, NotificationScheduledIndexKey}, "n-1").RedisNil()
+
+	removed, err := q.CancelScheduled(context.Background(), "n-1")
+	require.NoError(t, err)
+	assert.Empty(t, removed)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,254 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/textproto"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"gopkg.in/gomail.v2"
+)
+
+// SMTPPoolConfig mengatur pool koneksi SMTP milik SMTPSender. Field bernilai
+// nol mengikuti DefaultSMTPPoolConfig.
+type SMTPPoolConfig struct {
+	// MaxConns membatasi koneksi yang dipakai bersamaan; pengirim lain
+	// menunggu koneksi bebas.
+	MaxConns int
+	// IdleTimeout menutup koneksi yang tidak dipakai selama ini, sebelum
+	// server menutupnya sendiri.
+	IdleTimeout time.Duration
+	// MaxMessagesPerConn membatasi jumlah email per koneksi; banyak relay
+	// menolak atau memperlambat koneksi yang terlalu lama dipakai.
+	MaxMessagesPerConn int
+}
+
+var DefaultSMTPPoolConfig = SMTPPoolConfig{MaxConns: 4, IdleTimeout: 30 * time.Second, MaxMessagesPerConn: 100}
+
+func (c SMTPPoolConfig) withDefaults() SMTPPoolConfig {
+	if c.MaxConns <= 0 {
+		c.MaxConns = DefaultSMTPPoolConfig.MaxConns
+	}
+	if c.IdleTimeout <= 0 {
+		c.IdleTimeout = DefaultSMTPPoolConfig.IdleTimeout
+	}
+	if c.MaxMessagesPerConn <= 0 {
+		c.MaxMessagesPerConn = DefaultSMTPPoolConfig.MaxMessagesPerConn
+	}
+	return c
+}
+
+// Alasan koneksi ditutup, dipakai sebagai label metrik.
+const (
+	smtpCloseIdleTimeout = "idle_timeout"
+	smtpCloseMaxMessages = "max_messages"
+	smtpCloseError       = "error"
+	smtpCloseShutdown    = "shutdown"
+)
+
+type smtpConn struct {
+	gomail.SendCloser
+	sent     int
+	lastUsed time.Time
+}
+
+// smtpPool menyimpan koneksi SMTP yang sudah melewati handshake TCP, TLS dan
+// AUTH agar bisa dipakai ulang. Koneksi idle diambil LIFO sehingga koneksi
+// yang jarang dipakai kedaluwarsa lebih dulu. Koneksi yang mengembalikan
+// error apa pun tidak dikembalikan ke pool karena transaksi SMTP-nya bisa
+// tertinggal di tengah jalan. Pengiriman di koneksi lama hanya diulang jika
+// koneksi putus sebelum isi pesan dikirim.
+type smtpPool struct {
+	config SMTPPoolConfig
+	dial   func() (gomail.SendCloser, error)
+	now    func() time.Time
+	slots  chan struct{}
+
+	mu     sync.Mutex
+	idle   []*smtpConn
+	open   int
+	closed bool
+}
+
+func newSMTPPool(dial func() (gomail.SendCloser, error), config SMTPPoolConfig) *smtpPool {
+	config = config.withDefaults()
+	return &smtpPool{
+		config: config,
+		dial:   dial,
+		now:    time.Now,
+		slots:  make(chan struct{}, config.MaxConns),
+	}
+}
+
+func (p *smtpPool) send(ctx context.Context, from string, to []string, msg io.WriterTo) error {
+	start := time.Now()
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-p.slots }()
+	smtpPoolWait.Observe(time.Since(start).Seconds())
+
+	conn, reused, err := p.get()
+	if err != nil {
+		return err
+	}
+	tracked := &trackedMessage{WriterTo: msg}
+	err = conn.Send(from, to, tracked)
+	if err != nil && reused && !tracked.started && staleConnError(err) {
+		// Server mungkin sudah memutus koneksi lama tanpa sepengetahuan
+		// pool. Isi pesan belum dikirim, jadi aman diulang sekali di koneksi
+		// baru; setelah DATA diterima, server bisa saja sudah menerima email
+		// sehingga mengulang berisiko mengirim duplikat.
+		log.Debug().Err(err).Msg("Pooled SMTP connection failed, reconnecting")
+		p.discard(conn, smtpCloseError)
+		if conn, err = p.dialConn(); err != nil {
+			return err
+		}
+		err = conn.Send(from, to, msg)
+	}
+	if err != nil {
+		p.discard(conn, smtpCloseError)
+		return err
+	}
+	p.put(conn)
+	return nil
+}
+
+// staleConnError melaporkan apakah error berasal dari koneksi yang sudah
+// tidak layak dipakai: koneksi putus tanpa balasan, atau balasan 421 yang
+// dikirim server saat menutup sesi idle.
+func staleConnError(err error) bool {
+	var reply *textproto.Error
+	if !errors.As(err, &reply) {
+		return true
+	}
+	return reply.Code == 421
+}
+
+// trackedMessage mencatat apakah isi pesan sudah mulai ditulis. gomail baru
+// menulis pesan setelah MAIL FROM, RCPT TO dan DATA diterima server.
+type trackedMessage struct {
+	io.WriterTo
+	started bool
+}
+
+func (m *trackedMessage) WriteTo(w io.Writer) (int64, error) {
+	m.started = true
+	return m.WriterTo.WriteTo(w)
+}
+
+// get mengambil koneksi idle yang masih segar atau membuka koneksi baru.
+func (p *smtpPool) get() (conn *smtpConn, reused bool, err error) {
+	p.closeIdle()
+	p.mu.Lock()
+	if n := len(p.idle); n > 0 {
+		conn = p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		p.updateGauges()
+		p.mu.Unlock()
+		return conn, true, nil
+	}
+	p.mu.Unlock()
+	conn, err = p.dialConn()
+	return conn, false, err
+}
+
+func (p *smtpPool) dialConn() (*smtpConn, error) {
+	sc, err := p.dial()
+	if err != nil {
+		smtpPoolDials.WithLabelValues("error").Inc()
+		return nil, err
+	}
+	smtpPoolDials.WithLabelValues("success").Inc()
+	p.mu.Lock()
+	p.open++
+	p.updateGauges()
+	p.mu.Unlock()
+	return &smtpConn{SendCloser: sc}, nil
+}
+
+// put mengembalikan koneksi setelah pengiriman berhasil.
+func (p *smtpPool) put(conn *smtpConn) {
+	conn.sent++
+	conn.lastUsed = p.now()
+	p.mu.Lock()
+	if !p.closed && conn.sent < p.config.MaxMessagesPerConn {
+		p.idle = append(p.idle, conn)
+		p.updateGauges()
+		p.mu.Unlock()
+		return
+	}
+	reason := smtpCloseMaxMessages
+	if p.closed {
+		reason = smtpCloseShutdown
+	}
+	p.open--
+	p.updateGauges()
+	p.mu.Unlock()
+	p.close(conn, reason)
+}
+
+// discard menutup koneksi yang sedang dipakai tanpa mengembalikannya ke pool.
+func (p *smtpPool) discard(conn *smtpConn, reason string) {
+	p.mu.Lock()
+	p.open--
+	p.updateGauges()
+	p.mu.Unlock()
+	p.close(conn, reason)
+}
+
+// closeIdle menutup koneksi idle yang melewati IdleTimeout.
+func (p *smtpPool) closeIdle() {
+	p.mu.Lock()
+	now := p.now()
+	var expired []*smtpConn
+	fresh := p.idle[:0]
+	for _, conn := range p.idle {
+		if now.Sub(conn.lastUsed) >= p.config.IdleTimeout {
+			expired = append(expired, conn)
+		} else {
+			fresh = append(fresh, conn)
+		}
+	}
+	p.idle = fresh
+	p.open -= len(expired)
+	p.updateGauges()
+	p.mu.Unlock()
+	for _, conn := range expired {
+		p.close(conn, smtpCloseIdleTimeout)
+	}
+}
+
+// Close menutup semua koneksi idle. Koneksi yang sedang dipakai ditutup
+// saat dikembalikan.
+func (p *smtpPool) Close() {
+	p.mu.Lock()
+	p.closed = true
+	idle := p.idle
+	p.idle = nil
+	p.open -= len(idle)
+	p.updateGauges()
+	p.mu.Unlock()
+	for _, conn := range idle {
+		p.close(conn, smtpCloseShutdown)
+	}
+}
+
+func (p *smtpPool) close(conn *smtpConn, reason string) {
+	smtpPoolClosed.WithLabelValues(reason).Inc()
+	// QUIT pada koneksi yang sudah putus wajar gagal; cukup dicatat.
+	if err := conn.Close(); err != nil {
+		log.Debug().Err(err).Str("reason", reason).Msg("Failed to close pooled SMTP connection")
+	}
+}
+
+// updateGauges dipanggil dengan p.mu terkunci.
+func (p *smtpPool) updateGauges() {
+	smtpPoolConnections.WithLabelValues("idle").Set(float64(len(p.idle)))
+	smtpPoolConnections.WithLabelValues("in_use").Set(float64(p.open - len(p.idle)))
+}
@@ -0,0 +1,210 @@
+package service
+
+import (
+	"context"
+	"io"
+	"net/textproto"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/gomail.v2"
+)
+
+func sendN(t *testing.T, sender *SMTPSender, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		require.NoError(t, sender.SendEmail(context.Background(), testMessage))
+	}
+}
+
+func TestSMTPPool_ReusesConnection(t *testing.T) {
+	standIn := startSMTPStandIn(t, "250 OK")
+	sender := NewSMTPSender(standIn.host, standIn.port, "", "")
+
+	sendN(t, sender, 3)
+
+	assert.Equal(t, int32(1), standIn.conns.Load(), "handshake hanya sekali")
+	assert.Len(t, standIn.data, 3)
+}
+
+func TestSMTPPool_MaxMessagesPerConn(t *testing.T) {
+	standIn := startSMTPStandIn(t, "250 OK")
+	sender := NewSMTPSender(standIn.host, standIn.port, "", "").WithPool(SMTPPoolConfig{MaxMessagesPerConn: 2})
+
+	sendN(t, sender, 3)
+
+	assert.Equal(t, int32(2), standIn.conns.Load())
+}
+
+func TestSMTPPool_IdleConnectionIsReplaced(t *testing.T) {
+	standIn := startSMTPStandIn(t, "250 OK")
+	sender := NewSMTPSender(standIn.host, standIn.port, "", "").WithPool(SMTPPoolConfig{IdleTimeout: 30 * time.Second})
+	now := time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC)
+	sender.pool.now = func() time.Time { return now }
+
+	sendN(t, sender, 1)
+	now = now.Add(31 * time.Second)
+	sendN(t, sender, 1)
+
+	assert.Equal(t, int32(2), standIn.conns.Load())
+}
+
+func TestSMTPPool_ReconnectsWhenServerDroppedConnection(t *testing.T) {
+	standIn := startSMTPStandIn(t, "250 OK")
+	standIn.closeAfterData.Store(true)
+	sender := NewSMTPSender(standIn.host, standIn.port, "", "")
+
+	sendN(t, sender, 2)
+
+	assert.Equal(t, int32(2), standIn.conns.Load())
+	assert.Len(t, standIn.data, 2)
+}
+
+func TestSMTPPool_FailedSendClosesConnection(t *testing.T) {
+	standIn := startSMTPStandIn(t, "550 5.1.1 mailbox does not exist")
+	sender := NewSMTPSender(standIn.host, standIn.port, "", "")
+
+	assert.Error(t, sender.SendEmail(context.Background(), testMessage))
+	assert.Error(t, sender.SendEmail(context.Background(), testMessage))
+
+	assert.Equal(t, int32(2), standIn.conns.Load(), "transaksi yang gagal tidak dipakai ulang")
+}
+
+// droppingConn berhasil mengirim satu email, lalu koneksinya putus pada
+// pengiriman berikutnya, sebelum atau sesudah isi pesan ditulis.
+type droppingConn struct {
+	sends        int
+	dropAfterMsg bool
+	dropErr      error
+	delivered    *atomic.Int32
+}
+
+func (c *droppingConn) Send(from string, to []string, msg io.WriterTo) error {
+	c.sends++
+	if c.sends > 1 {
+		if c.dropAfterMsg {
+			_, _ = msg.WriteTo(io.Discard)
+		}
+		return c.dropErr
+	}
+	_, err := msg.WriteTo(io.Discard)
+	c.delivered.Add(1)
+	return err
+}
+
+func (c *droppingConn) Close() error { return nil }
+
+func TestSMTPPool_RetriesDroppedConnectionOnlyBeforeMessageIsSent(t *testing.T) {
+	closing := &textproto.Error{Code: 421, Msg: "4.4.2 idle timeout, closing connection"}
+	rejected := &textproto.Error{Code: 451, Msg: "4.3.0 try again later"}
+	for _, tc := range []struct {
+		name         string
+		dropAfterMsg bool
+		dropErr      error
+		wantErr      bool
+		wantDials    int32
+	}{
+		{name: "putus sebelum DATA diulang di koneksi baru", dropErr: io.ErrUnexpectedEOF, wantDials: 2},
+		{name: "balasan 421 sebelum DATA diulang di koneksi baru", dropErr: closing, wantDials: 2},
+		{name: "balasan lain sebelum DATA tidak diulang", dropErr: rejected, wantErr: true, wantDials: 1},
+		{name: "putus setelah isi pesan dikirim tidak diulang", dropAfterMsg: true, dropErr: io.ErrUnexpectedEOF, wantErr: true, wantDials: 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var dials, delivered atomic.Int32
+			pool := newSMTPPool(func() (gomail.SendCloser, error) {
+				dials.Add(1)
+				return &droppingConn{dropAfterMsg: tc.dropAfterMsg, dropErr: tc.dropErr, delivered: &delivered}, nil
+			}, SMTPPoolConfig{})
+			send := func() error {
+				return pool.send(context.Background(), "a@example.com", []string{"b@example.com"}, gomail.NewMessage())
+			}
+
+			require.NoError(t, send())
+			err := send()
+
+			if tc.wantErr {
+				assert.ErrorIs(t, err, tc.dropErr)
+				assert.Equal(t, int32(1), delivered.Load(), "email tidak boleh dikirim ulang")
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, int32(2), delivered.Load())
+			}
+			assert.Equal(t, tc.wantDials, dials.Load())
+		})
+	}
+}
+
+// blockingConn menahan Send sampai release ditutup.
+type blockingConn struct {
+	release <-chan struct{}
+	closed  atomic.Bool
+}
+
+func (c *blockingConn) Send(from string, to []string, msg io.WriterTo) error {
+	<-c.release
+	return nil
+}
+
+func (c *blockingConn) Close() error {
+	c.closed.Store(true)
+	return nil
+}
+
+func TestSMTPPool_LimitsConcurrentConnections(t *testing.T) {
+	release := make(chan struct{})
+	var dials atomic.Int32
+	pool := newSMTPPool(func() (gomail.SendCloser, error) {
+		dials.Add(1)
+		return &blockingConn{release: release}, nil
+	}, SMTPPoolConfig{MaxConns: 1})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, pool.send(context.Background(), "a@example.com", []string{"b@example.com"}, gomail.NewMessage()))
+		}()
+	}
+	require.Eventually(t, func() bool { return dials.Load() == 1 }, time.Second, time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), dials.Load(), "pengirim kedua menunggu lalu memakai koneksi yang sama")
+}
+
+func TestSMTPPool_WaitRespectsContext(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	pool := newSMTPPool(func() (gomail.SendCloser, error) {
+		return &blockingConn{release: release}, nil
+	}, SMTPPoolConfig{MaxConns: 1})
+	go func() {
+		_ = pool.send(context.Background(), "a@example.com", []string{"b@example.com"}, gomail.NewMessage())
+	}()
+	require.Eventually(t, func() bool { return len(pool.slots) == 1 }, time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := pool.send(ctx, "a@example.com", []string{"b@example.com"}, gomail.NewMessage())
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestSMTPPool_CloseClosesIdleConnections(t *testing.T) {
+	release := make(chan struct{})
+	close(release)
+	conn := &blockingConn{release: release}
+	pool := newSMTPPool(func() (gomail.SendCloser, error) { return conn, nil }, SMTPPoolConfig{})
+
+	require.NoError(t, pool.send(context.Background(), "a@example.com", []string{"b@example.com"}, gomail.NewMessage()))
+	assert.False(t, conn.closed.Load())
+
+	pool.Close()
+	assert.True(t, conn.closed.Load())
+	assert.Empty(t, pool.idle)
+	assert.Zero(t, pool.open)
+}
@@ -0,0 +1,211 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const NotificationStatusKeyPrefix = "notification_status:"
+
+var ErrStatusNotFound = errors.New("notification status not found")
+
+// DeliveryState adalah tahap siklus hidup sebuah notifikasi.
+type DeliveryState string
+
+const (
+	StateQueued       DeliveryState = "queued"
+	StateProcessing   DeliveryState = "processing"
+	StateSent         DeliveryState = "sent"
+	StateRetrying     DeliveryState = "retrying"
+	StateFailed       DeliveryState = "failed"
+	StateDeadLettered DeliveryState = "dead_lettered"
+	// StateSkipped dipakai per channel, mis. WebSocket saat user sedang offline.
+	StateSkipped DeliveryState = "skipped"
+	// StateCancelled berarti notifikasi ditarik lewat DELETE /notifications/:id
+	// sebelum worker mulai mengirimnya.
+	StateCancelled DeliveryState = "cancelled"
+	// StateExpired berarti batas expires_at lewat sebelum notifikasi terkirim.
+	StateExpired DeliveryState = "expired"
+	// StateSuppressed berarti alamat penerima ada di suppression list sehingga
+	// email tidak dikirim.
+	StateSuppressed DeliveryState = "suppressed"
+)
+
+const (
+	ChannelEmail     = "email"
+	ChannelWebSocket = "websocket"
+)
+
+type ChannelStatus struct {
+	State     DeliveryState `json:"state"`
+	Error     string        `json:"error,omitempty"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
+
+type NotificationStatus struct {
+	ID        string                   `json:"id"`
+	State     DeliveryState            `json:"state"`
+	Channels  map[string]ChannelStatus `json:"channels"`
+	CreatedAt time.Time                `json:"created_at"`
+	UpdatedAt time.Time                `json:"updated_at"`
+}
+
+type StatusStore interface {
+	// Record memperbarui status keseluruhan notifikasi.
+	Record(ctx context.Context, id string, state DeliveryState) error
+	// RecordChannel memperbarui status satu channel; errMsg boleh kosong.
+	RecordChannel(ctx context.Context, id, channel string, state DeliveryState, errMsg string) error
+	Get(ctx context.Context, id string) (*NotificationStatus, error)
+	// Claim dipanggil worker sebelum mengirim: mengubah status menjadi
+	// processing kecuali notifikasi sudah dibatalkan (mengembalikan false).
+	Claim(ctx context.Context, id string) (bool, error)
+	// Cancel membatalkan notifikasi yang masih queued atau retrying dan
+	// mengembalikan status terkini beserta apakah pembatalan berhasil.
+	Cancel(ctx context.Context, id string) (DeliveryState, bool, error)
+	// Delete menghapus status notifikasi yang tidak pernah diterima, mis.
+	// karena ditolak backpressure.
+	Delete(ctx context.Context, id string) error
+}
+
+// StatusService menyimpan status per notifikasi sebagai hash Redis
+// notification_status:<id> yang kedaluwarsa setelah ttl.
+type StatusService struct {
+	redisClient *redis.Client
+	ttl         time.Duration
+	now         func() time.Time
+}
+
+var _ StatusStore = (*StatusService)(nil)
+
+func NewStatusService(redisClient *redis.Client, ttl time.Duration) *StatusService {
+	return &StatusService{redisClient: redisClient, ttl: ttl, now: time.Now}
+}
+
+func (s *StatusService) Record(ctx context.Context, id string, state DeliveryState) error {
+	return s.write(ctx, id, "state", string(state))
+}
+
+func (s *StatusService) RecordChannel(ctx context.Context, id, channel string, state DeliveryState, errMsg string) error {
+	now := s.timestamp()
+	return s.write(ctx, id,
+		channel+":state", string(state),
+		channel+":error", errMsg,
+		channel+":updated_at", now,
+	)
+}
+
+func (s *StatusService) timestamp() string {
+	return s.now().UTC().Format(time.RFC3339Nano)
+}
+
+// write menulis pasangan field/value ke hash status dan memperbarui TTL.
+func (s *StatusService) write(ctx context.Context, id string, fieldValues ...string) error {
+	if id == "" {
+		return nil // job lama tanpa ID tidak dilacak
+	}
+	key := NotificationStatusKeyPrefix + id
+	now := s.timestamp()
+	values := make([]interface{}, 0, len(fieldValues)+2)
+	for _, v := range fieldValues {
+		values = append(values, v)
+	}
+	values = append(values, "updated_at", now)
+
+	_, err := s.redisClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSetNX(ctx, key, "created_at", now)
+		pipe.HSet(ctx, key, values...)
+		pipe.Expire(ctx, key, s.ttl)
+		return nil
+	})
+	return err
+}
+
+func (s *StatusService) Get(ctx context.Context, id string) (*NotificationStatus, error) {
+	fields, err := s.redisClient.HGetAll(ctx, NotificationStatusKeyPrefix+id).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return nil, ErrStatusNotFound
+	}
+
+	status := &NotificationStatus{
+		ID:       id,
+		State:    DeliveryState(fields["state"]),
+		Channels: map[string]ChannelStatus{},
+	}
+	status.CreatedAt, _ = time.Parse(time.RFC3339Nano, fields["created_at"])
+	status.UpdatedAt, _ = time.Parse(time.RFC3339Nano, fields["updated_at"])
+	for field, value := range fields {
+		channel, ok := strings.CutSuffix(field, ":state")
+		if !ok {
+			continue
+		}
+		updatedAt, _ := time.Parse(time.RFC3339Nano, fields[channel+":updated_at"])
+		status.Channels[channel] = ChannelStatus{
+			State:     DeliveryState(value),
+			Error:     fields[channel+":error"],
+			UpdatedAt: updatedAt,
+		}
+	}
+	return status, nil
+}
+
+// claimScript dan cancelScript memutuskan perlombaan antara worker dan
+// pembatalan secara atomik pada hash status: siapa yang lebih dulu mengubah
+// state dari queued/retrying, dialah yang menang.
+var claimScript = redis.NewScript(`
+if redis.call('HGET', KEYS[1], 'state') == 'cancelled' then
+	return 0
+end
+redis.call('HSETNX', KEYS[1], 'created_at', ARGV[1])
+redis.call('HSET', KEYS[1], 'state', 'processing', 'updated_at', ARGV[1])
+redis.call('EXPIRE', KEYS[1], ARGV[2])
+return 1
+`)
+
+var cancelScript = redis.NewScript(`
+local state = redis.call('HGET', KEYS[1], 'state')
+if not state then
+	return false
+end
+if state == 'queued' or state == 'retrying' then
+	redis.call('HSET', KEYS[1], 'state', 'cancelled', 'updated_at', ARGV[1])
+	return 'cancelled'
+end
+return state
+`)
+
+func (s *StatusService) Claim(ctx context.Context, id string) (bool, error) {
+	if id == "" {
+		return true, nil
+	}
+	claimed, err := claimScript.Run(ctx, s.redisClient, []string{NotificationStatusKeyPrefix + id},
+		s.timestamp(), int64(s.ttl.Seconds())).Int()
+	if err != nil {
+		return false, err
+	}
+	return claimed == 1, nil
+}
+
+func (s *StatusService) Cancel(ctx context.Context, id string) (DeliveryState, bool, error) {
+	state, err := cancelScript.Run(ctx, s.redisClient, []string{NotificationStatusKeyPrefix + id}, s.timestamp()).Text()
+	if errors.Is(err, redis.Nil) {
+		return "", false, ErrStatusNotFound
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return DeliveryState(state), DeliveryState(state) == StateCancelled, nil
+}
+
+func (s *StatusService) Delete(ctx context.Context, id string) error {
+	if id == "" {
+		return nil
+	}
+	return s.redisClient.Del(ctx, NotificationStatusKeyPrefix+id).Err()
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redismock/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatusRecordChannel_WritesFieldsAndRefreshesTTL(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	store := NewStatusService(db, 24*time.Hour)
+	now := time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+	ts := now.Format(time.RFC3339Nano)
+	key := NotificationStatusKeyPrefix + "n-1"
+
+	mock.ExpectHSetNX(key, "created_at", ts).SetVal(true)
+	mock.ExpectHSet(key, "email:state", "retrying", "email:error", "dial tcp: timeout", "email:updated_at", ts, "updated_at", ts).SetVal(4)
+	mock.ExpectExpire(key, 24*time.Hour).SetVal(true)
+
+	err := store.RecordChannel(context.Background(), "n-1", ChannelEmail, StateRetrying, "dial tcp: timeout")
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStatusRecord_IgnoresJobsWithoutID(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	store := NewStatusService(db, time.Hour)
+
+	require.NoError(t, store.Record(context.Background(), "", StateSent))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStatusGet(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	store := NewStatusService(db, time.Hour)
+	ts := "2025-01-06T09:00:00Z"
+
+	mock.ExpectHGetAll(NotificationStatusKeyPrefix + "n-1").SetVal(map[string]string{
+		"state":                "sent",
+		"created_at":           ts,
+		"updated_at":           ts,
+		"email:state":          "sent",
+		"email:error":          "",
+		"email:updated_at":     ts,
+		"websocket:state":      "skipped",
+		"websocket:updated_at": ts,
+	})
+
+	status, err := store.Get(context.Background(), "n-1")
+	require.NoError(t, err)
+	assert.Equal(t, StateSent, status.State)
+	assert.Equal(t, StateSent, status.Channels[ChannelEmail].State)
+	assert.Equal(t, StateSkipped, status.Channels[ChannelWebSocket].State)
+	assert.Equal(t, time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC), status.UpdatedAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStatusGet_NotFound(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	store := NewStatusService(db, time.Hour)
+
+	mock.ExpectHGetAll(NotificationStatusKeyPrefix + "missing").SetVal(map[string]string{})
+
+	_, err := store.Get(context.Background(), "missing")
+	assert.ErrorIs(t, err, ErrStatusNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStatusClaim(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	store := NewStatusService(db, time.Hour)
+	now := time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+	ts := now.Format(time.RFC3339Nano)
+
+	mock.ExpectEvalSha(claimScript.Hash(), []string{NotificationStatusKeyPrefix + "n-1"}, ts, int64(3600)).SetVal(int64(1))
+	mock.ExpectEvalSha(claimScript.Hash(), []string{NotificationStatusKeyPrefix + "n-2"}, ts, int64(3600)).SetVal(int64(0))
+
+	claimed, err := store.Claim(context.Background(), "n-1")
+	require.NoError(t, err)
+	assert.True(t, claimed)
+	claimed, err = store.Claim(context.Background(), "n-2")
+	require.NoError(t, err)
+	assert.False(t, claimed, "notifikasi yang dibatalkan tidak boleh diklaim")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStatusCancel(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	store := NewStatusService(db, time.Hour)
+	now := time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+	ts := now.Format(time.RFC3339Nano)
+
+	mock.ExpectEvalSha(cancelScript.Hash(), []string{NotificationStatusKeyPrefix + "n-1"}, ts).SetVal("cancelled")
+	mock.ExpectEvalSha(cancelScript.Hash(), []string{NotificationStatusKeyPrefix + "n-2"}, ts).SetVal("sent")
+	mock.ExpectEvalSha(cancelScript.Hash(), []string{NotificationStatusKeyPrefix + "missing"}, ts).RedisNil()
+
+	state, cancelled, err := store.Cancel(context.Background(), "n-1")
+	require.NoError(t, err)
+	assert.True(t, cancelled)
+	assert.Equal(t, StateCancelled, state)
+
+	state, cancelled, err = store.Cancel(context.Background(), "n-2")
+	require.NoError(t, err)
+	assert.False(t, cancelled, "pengiriman sudah menang")
+	assert.Equal(t, StateSent, state)
+
+	_, _, err = store.Cancel(context.Background(), "missing")
+	assert.ErrorIs(t, err, ErrStatusNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStatusDelete(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	store := NewStatusService(db, time.Hour)
+	mock.ExpectDel(NotificationStatusKeyPrefix + "n-1").SetVal(1)
+
+	require.NoError(t, store.Delete(context.Background(), "n-1"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,264 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	NotificationStreamKey   = "notification_stream"
+	NotificationStreamGroup = "notification_workers"
+
+	streamPayloadField = "payload"
+)
+
+// StreamQueueService adalah implementasi Queue berbasis Redis Streams.
+// Semua replika bergabung dalam satu consumer group sehingga beban terbagi,
+// dan entri pending milik consumer yang mati diambil alih via XAUTOCLAIM.
+type StreamQueueService struct {
+	redisClient *redis.Client
+	consumer    string
+	// claimMinIdle adalah lama sebuah entri pending tidak di-Ack sebelum
+	// boleh diambil alih consumer lain (setara visibility timeout).
+	claimMinIdle  time.Duration
+	claimInterval time.Duration
+
+	mu         sync.Mutex
+	claimStart string
+	nextClaim  time.Time
+	now        func() time.Time
+}
+
+var _ Queue = (*StreamQueueService)(nil)
+
+func NewStreamQueueService(redisClient *redis.Client, consumer string, claimMinIdle time.Duration) *StreamQueueService {
+	return &StreamQueueService{
+		redisClient:   redisClient,
+		consumer:      consumer,
+		claimMinIdle:  claimMinIdle,
+		claimInterval: 30 * time.Second,
+		claimStart:    "0-0",
+		now:           time.Now,
+	}
+}
+
+// EnsureGroup membuat stream dan consumer group jika belum ada.
+func (s *StreamQueueService) EnsureGroup(ctx context.Context) error {
+	err := s.redisClient.XGroupCreateMkStream(ctx, NotificationStreamKey, NotificationStreamGroup, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("gagal membuat consumer group %s: %w", NotificationStreamGroup, err)
+	}
+	return nil
+}
+
+func (s *StreamQueueService) Enqueue(ctx context.Context, job NotificationJob) error {
+	payload, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return s.redisClient.XAdd(ctx, &redis.XAddArgs{
+		Stream: NotificationStreamKey,
+		Values: map[string]interface{}{streamPayloadField: string(payload)},
+	}).Err()
+}
+
+func (s *StreamQueueService) EnqueueBatch(ctx context.Context, jobs []NotificationJob) error {
+	return enqueueBatch(ctx, s.redisClient, s, jobs)
+}
+
+func (s *StreamQueueService) enqueueTo(ctx context.Context, pipe redis.Pipeliner, job NotificationJob) error {
+	payload, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	pipe.XAdd(ctx, &redis.XAddArgs{
+		Stream: NotificationStreamKey,
+		Values: map[string]interface{}{streamPayloadField: string(payload)},
+	})
+	return nil
+}
+
+// Dequeue mendahulukan entri pending milik consumer mati yang sudah melewati
+// claimMinIdle, lalu membaca entri baru dari consumer group.
+func (s *StreamQueueService) Dequeue(ctx context.Context) (*NotificationJob, error) {
+	if job, err := s.claimStale(ctx); err != nil || job != nil {
+		return job, err
+	}
+
+	streams, err := s.redisClient.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    NotificationStreamGroup,
+		Consumer: s.consumer,
+		Streams:  []string{NotificationStreamKey, ">"},
+		Count:    1,
+		Block:    5 * time.Second,
+	}).Result()
+	if err != nil {
+		return nil, err // redis.Nil jika timeout
+	}
+	if len(streams) == 0 || len(streams[0].Messages) == 0 {
+		return nil, redis.Nil
+	}
+	return s.decodeMessage(ctx, streams[0].Messages[0])
+}
+
+func (s *StreamQueueService) claimStale(ctx context.Context) (*NotificationJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.now().Before(s.nextClaim) {
+		return nil, nil
+	}
+
+	messages, next, err := s.redisClient.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+		Stream:   NotificationStreamKey,
+		Group:    NotificationStreamGroup,
+		Consumer: s.consumer,
+		MinIdle:  s.claimMinIdle,
+		Start:    s.claimStart,
+		Count:    1,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	s.claimStart = next
+	if len(messages) == 0 {
+		// Tidak ada lagi entri basi; periksa lagi setelah claimInterval.
+		s.claimStart = "0-0"
+		s.nextClaim = s.now().Add(s.claimInterval)
+		return nil, nil
+	}
+
+	log.Warn().Str("message_id", messages[0].ID).Str("consumer", s.consumer).Msg("Reclaimed stale pending stream entry")
+	return s.decodeMessage(ctx, messages[0])
+}
+
+func (s *StreamQueueService) decodeMessage(ctx context.Context, msg redis.XMessage) (*NotificationJob, error) {
+	payload, ok := msg.Values[streamPayloadField].(string)
+	if !ok {
+		raw, _ := json.Marshal(msg.Values)
+		return nil, s.quarantine(ctx, msg.ID, string(raw), fmt.Errorf("entri stream %s tidak memiliki field %q", msg.ID, streamPayloadField))
+	}
+	job, err := decodeJob(payload)
+	if errors.Is(err, ErrUnsupportedJobVersion) {
+		// Entri dari worker yang lebih baru dibiarkan di PEL tanpa ack agar
+		// diklaim ulang oleh consumer lain lewat XAUTOCLAIM.
+		return nil, err
+	}
+	if err != nil {
+		return nil, s.quarantine(ctx, msg.ID, payload, err)
+	}
+	job.receipt = msg.ID
+	return job, nil
+}
+
+// quarantine memindahkan entri yang tidak akan pernah bisa diproses ke
+// karantina lalu membuangnya dari PEL.
+func (s *StreamQueueService) quarantine(ctx context.Context, id, payload string, decodeErr error) error {
+	err := quarantine(ctx, s.redisClient, s.now(), QuarantineSourceStream, payload, decodeErr)
+	if !errors.Is(err, ErrPoisonMessage) {
+		return err
+	}
+	if ackErr := s.ack(ctx, id); ackErr != nil {
+		log.Warn().Err(ackErr).Str("message_id", id).Msg("Failed to ack quarantined stream entry")
+	}
+	return err
+}
+
+func (s *StreamQueueService) Ack(ctx context.Context, job *NotificationJob) error {
+	if job.receipt == "" {
+		return nil
+	}
+	return s.ack(ctx, job.receipt)
+}
+
+func (s *StreamQueueService) ack(ctx context.Context, id string) error {
+	_, err := s.redisClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.XAck(ctx, NotificationStreamKey, NotificationStreamGroup, id)
+		pipe.XDel(ctx, NotificationStreamKey, id)
+		return nil
+	})
+	return err
+}
+
+// Nack menambahkan ulang job sebagai entri baru lalu meng-Ack entri lama,
+// sehingga job segera tersedia bagi consumer lain.
+func (s *StreamQueueService) Nack(ctx context.Context, job *NotificationJob) error {
+	if err := s.Enqueue(ctx, *job); err != nil {
+		return err
+	}
+	return s.Ack(ctx, job)
+}
+
+func (s *StreamQueueService) EnqueueToDLQ(ctx context.Context, job NotificationJob, failure DeliveryFailure) error {
+	return enqueueToDLQ(ctx, s.redisClient, job, failure)
+}
+
+// Depth mengembalikan panjang stream. Entri dihapus saat di-Ack, jadi nilai
+// ini mencakup entri yang menunggu maupun yang sedang diproses.
+func (s *StreamQueueService) Depth(ctx context.Context) (int64, error) {
+	return s.redisClient.XLen(ctx, NotificationStreamKey).Result()
+}
+
+// StreamStats merangkum kondisi consumer group untuk observabilitas.
+type StreamStats struct {
+	// Lag adalah jumlah entri yang belum dikirim ke consumer mana pun.
+	Lag int64
+	// Pending adalah jumlah entri in-flight per consumer.
+	Pending map[string]int64
+}
+
+func (s *StreamQueueService) Stats(ctx context.Context) (*StreamStats, error) {
+	groups, err := s.redisClient.XInfoGroups(ctx, NotificationStreamKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	stats := &StreamStats{Pending: map[string]int64{}}
+	for _, g := range groups {
+		if g.Name == NotificationStreamGroup {
+			stats.Lag = g.Lag
+		}
+	}
+
+	pending, err := s.redisClient.XPending(ctx, NotificationStreamKey, NotificationStreamGroup).Result()
+	if err != nil {
+		return nil, err
+	}
+	for consumer, count := range pending.Consumers {
+		stats.Pending[consumer] = count
+	}
+	return stats, nil
+}
+
+// RunMonitor memperbarui metrik lag dan pending secara periodik.
+func (s *StreamQueueService) RunMonitor(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			stats, err := s.Stats(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Error().Err(err).Msg("Failed to read stream consumer group stats")
+				}
+				continue
+			}
+			streamLag.Set(float64(stats.Lag))
+			streamPending.Reset()
+			for consumer, count := range stats.Pending {
+				streamPending.WithLabelValues(consumer).Set(float64(count))
+			}
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redismock/v9"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestStreamQueue(t *testing.T) (*StreamQueueService, redismock.ClientMock) {
+	t.Helper()
+	db, mock := redismock.NewClientMock()
+	return NewStreamQueueService(db, "worker-1", time.Minute), mock
+}
+
+func autoClaimArgs(start string) *redis.XAutoClaimArgs {
+	return &redis.XAutoClaimArgs{
+		Stream:   NotificationStreamKey,
+		Group:    NotificationStreamGroup,
+		Consumer: "worker-1",
+		MinIdle:  time.Minute,
+		Start:    start,
+		Count:    1,
+	}
+}
+
+func TestStreamEnqueue(t *testing.T) {
+	queueService, mock := newTestStreamQueue(t)
+	job := NotificationJob{RecipientUserID: "user-1", To: "a@example.com"}
+	payload, err := json.Marshal(job)
+	require.NoError(t, err)
+
+	mock.ExpectXAdd(&redis.XAddArgs{
+		Stream: NotificationStreamKey,
+		Values: map[string]interface{}{streamPayloadField: string(payload)},
+	}).SetVal("1-0")
+
+	require.NoError(t, queueService.Enqueue(context.Background(), job))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStreamDequeue_ReadsNewEntries(t *testing.T) {
+	queueService, mock := newTestStreamQueue(t)
+	payload, err := json.Marshal(NotificationJob{RecipientUserID: "user-1", To: "a@example.com"})
+	require.NoError(t, err)
+
+	mock.ExpectXAutoClaim(autoClaimArgs("0-0")).SetVal(nil, "0-0")
+	mock.ExpectXReadGroup(&redis.XReadGroupArgs{
+		Group:    NotificationStreamGroup,
+		Consumer: "worker-1",
+		Streams:  []string{NotificationStreamKey, ">"},
+		Count:    1,
+		Block:    5 * time.Second,
+	}).SetVal([]redis.XStream{{
+		Stream:   NotificationStreamKey,
+		Messages: []redis.XMessage{{ID: "5-0", Values: map[string]interface{}{streamPayloadField: string(payload)}}},
+	}})
+
+	job, err := queueService.Dequeue(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", job.RecipientUserID)
+	assert.Equal(t, "5-0", job.receipt, "Receipt harus berisi message ID untuk XACK")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStreamDequeue_ReclaimsStaleEntries(t *testing.T) {
+	queueService, mock := newTestStreamQueue(t)
+	payload, err := json.Marshal(NotificationJob{RecipientUserID: "user-2"})
+	require.NoError(t, err)
+
+	mock.ExpectXAutoClaim(autoClaimArgs("0-0")).SetVal([]redis.XMessage{
+		{ID: "3-0", Values: map[string]interface{}{streamPayloadField: string(payload)}},
+	}, "4-0")
+
+	job, err := queueService.Dequeue(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "user-2", job.RecipientUserID)
+	assert.Equal(t, "3-0", job.receipt)
+	assert.Equal(t, "4-0", queueService.claimStart, "Cursor XAUTOCLAIM harus dilanjutkan")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStreamDequeue_QuarantinesEntryWithoutPayload(t *testing.T) {
+	queueService, mock := newTestStreamQueue(t)
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	queueService.now = func() time.Time { return now }
+
+	mock.ExpectXAutoClaim(autoClaimArgs("0-0")).SetVal([]redis.XMessage{
+		{ID: "3-0", Values: map[string]interface{}{"body": "hello"}},
+	}, "0-0")
+	expectQuarantineErr(t, mock, now, QuarantineSourceStream, `{"body":"hello"}`,
+		errors.New(`entri stream 3-0 tidak memiliki field "payload"`))
+	mock.ExpectXAck(NotificationStreamKey, NotificationStreamGroup, "3-0").SetVal(1)
+	mock.ExpectXDel(NotificationStreamKey, "3-0").SetVal(1)
+
+	_, err := queueService.Dequeue(context.Background())
+	assert.ErrorIs(t, err, ErrPoisonMessage)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStreamDequeue_LeavesNewerVersionEntryPending(t *testing.T) {
+	queueService, mock := newTestStreamQueue(t)
+
+	mock.ExpectXAutoClaim(autoClaimArgs("0-0")).SetVal([]redis.XMessage{
+		{ID: "3-0", Values: map[string]interface{}{streamPayloadField: `{"version":99}`}},
+	}, "0-0")
+
+	_, err := queueService.Dequeue(context.Background())
+	assert.ErrorIs(t, err, ErrUnsupportedJobVersion)
+	assert.NoError(t, mock.ExpectationsWereMet(), "entri versi baru tidak boleh di-ack atau dikarantina")
+}
+
+func TestStreamAck(t *testing.T) {
+	queueService, mock := newTestStreamQueue(t)
+
+	mock.ExpectXAck(NotificationStreamKey, NotificationStreamGroup, "7-0").SetVal(1)
+	mock.ExpectXDel(NotificationStreamKey, "7-0").SetVal(1)
+
+	require.NoError(t, queueService.Ack(context.Background(), &NotificationJob{receipt: "7-0"}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStreamEnsureGroup_IgnoresBusyGroup(t *testing.T) {
+	queueService, mock := newTestStreamQueue(t)
+
+	mock.ExpectXGroupCreateMkStream(NotificationStreamKey, NotificationStreamGroup, "0").
+		SetErr(errors.New("BUSYGROUP Consumer Group name already exists"))
+
+	assert.NoError(t, queueService.EnsureGroup(context.Background()))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
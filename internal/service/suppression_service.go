@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+)
+
+// Detail suppression disimpan di hash per alamat; sorted set menyimpan
+// urutan waktu untuk paginasi.
+const (
+	NotificationSuppressionKey      = "notification_suppression"
+	NotificationSuppressionIndexKey = "notification_suppression_index"
+)
+
+var ErrSuppressionNotFound = errors.New("suppression not found")
+
+// Asal suppression, dipakai sebagai label metrik.
+const (
+	SuppressionSourceHardBounce = "hard_bounce"
+	SuppressionSourceManual     = "manual"
+)
+
+// Suppression adalah alamat email yang tidak lagi dikirimi email.
+type Suppression struct {
+	Email        string    `json:"email"`
+	Source       string    `json:"source"`
+	Reason       string    `json:"reason,omitempty"`
+	SuppressedAt time.Time `json:"suppressed_at"`
+}
+
+// SuppressionList menyimpan alamat yang pernah hard bounce (atau ditambahkan
+// manual) agar worker tidak terus mengirim ke alamat yang tidak ada.
+// Alamat dibandingkan tanpa membedakan huruf besar/kecil.
+type SuppressionList interface {
+	Suppress(ctx context.Context, email, source, reason string) error
+	IsSuppressed(ctx context.Context, email string) (bool, error)
+	List(ctx context.Context, offset, limit int) ([]Suppression, int, error)
+	Get(ctx context.Context, email string) (*Suppression, error)
+	Remove(ctx context.Context, email string) error
+}
+
+type SuppressionService struct {
+	redisClient *redis.Client
+	now         func() time.Time
+}
+
+var _ SuppressionList = (*SuppressionService)(nil)
+
+func NewSuppressionService(redisClient *redis.Client) *SuppressionService {
+	return &SuppressionService{redisClient: redisClient, now: time.Now}
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func (s *SuppressionService) Suppress(ctx context.Context, email, source, reason string) error {
+	email = normalizeEmail(email)
+	at := s.now().UTC()
+	entry, err := json.Marshal(Suppression{Email: email, Source: source, Reason: reason, SuppressedAt: at})
+	if err != nil {
+		return err
+	}
+	var added *redis.IntCmd
+	_, err = s.redisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		added = pipe.HSet(ctx, NotificationSuppressionKey, email, entry)
+		pipe.ZAdd(ctx, NotificationSuppressionIndexKey, redis.Z{Score: float64(at.Unix()), Member: email})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if added.Val() > 0 {
+		suppressedRecipients.WithLabelValues(source).Inc()
+		log.Warn().Str("email", email).Str("source", source).Str("reason", reason).Msg("Recipient added to suppression list")
+	}
+	return nil
+}
+
+func (s *SuppressionService) IsSuppressed(ctx context.Context, email string) (bool, error) {
+	return s.redisClient.HExists(ctx, NotificationSuppressionKey, normalizeEmail(email)).Result()
+}
+
+// List mengembalikan suppression terbaru lebih dulu.
+func (s *SuppressionService) List(ctx context.Context, offset, limit int) ([]Suppression, int, error) {
+	total, err := s.redisClient.ZCard(ctx, NotificationSuppressionIndexKey).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+	emails, err := s.redisClient.ZRevRange(ctx, NotificationSuppressionIndexKey, int64(offset), int64(offset+limit-1)).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+	entries := make([]Suppression, 0, len(emails))
+	if len(emails) == 0 {
+		return entries, int(total), nil
+	}
+	raws, err := s.redisClient.HMGet(ctx, NotificationSuppressionKey, emails...).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+	for i, raw := range raws {
+		payload, ok := raw.(string)
+		if !ok {
+			// Index tanpa detail (mis. dihapus di antara ZREVRANGE dan HMGET).
+			continue
+		}
+		entries = append(entries, decodeSuppression(emails[i], payload))
+	}
+	return entries, int(total), nil
+}
+
+func (s *SuppressionService) Get(ctx context.Context, email string) (*Suppression, error) {
+	email = normalizeEmail(email)
+	payload, err := s.redisClient.HGet(ctx, NotificationSuppressionKey, email).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrSuppressionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	entry := decodeSuppression(email, payload)
+	return &entry, nil
+}
+
+func (s *SuppressionService) Remove(ctx context.Context, email string) error {
+	email = normalizeEmail(email)
+	var removed *redis.IntCmd
+	_, err := s.redisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		removed = pipe.HDel(ctx, NotificationSuppressionKey, email)
+		pipe.ZRem(ctx, NotificationSuppressionIndexKey, email)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if removed.Val() == 0 {
+		return ErrSuppressionNotFound
+	}
+	return nil
+}
+
+func decodeSuppression(email, payload string) Suppression {
+	var entry Suppression
+	if err := json.Unmarshal([]byte(payload), &entry); err != nil {
+		// Tetap tampilkan alamatnya supaya masih bisa dihapus.
+		entry = Suppression{Reason: err.Error()}
+	}
+	entry.Email = email
+	return entry
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redismock/v9"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSuppress_StoresNormalizedAddress(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	suppression := NewSuppressionService(db)
+	now := time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC)
+	suppression.now = func() time.Time { return now }
+	entry, err := json.Marshal(Suppression{Email: "budi@example.com", Source: SuppressionSourceHardBounce, Reason: "550 5.1.1", SuppressedAt: now})
+	require.NoError(t, err)
+
+	mock.ExpectTxPipeline()
+	mock.ExpectHSet(NotificationSuppressionKey, "budi@example.com", entry).SetVal(1)
+	mock.ExpectZAdd(NotificationSuppressionIndexKey, redis.Z{Score: float64(now.Unix()), Member: "budi@example.com"}).SetVal(1)
+	mock.ExpectTxPipelineExec()
+
+	require.NoError(t, suppression.Suppress(context.Background(), " Budi@Example.com", SuppressionSourceHardBounce, "550 5.1.1"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestIsSuppressed_IgnoresCase(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	suppression := NewSuppressionService(db)
+	mock.ExpectHExists(NotificationSuppressionKey, "budi@example.com").SetVal(true)
+
+	suppressed, err := suppression.IsSuppressed(context.Background(), "BUDI@example.com")
+	require.NoError(t, err)
+	assert.True(t, suppressed)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSuppressionList_NewestFirst(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	suppression := NewSuppressionService(db)
+	mock.ExpectZCard(NotificationSuppressionIndexKey).SetVal(3)
+	mock.ExpectZRevRange(NotificationSuppressionIndexKey, 0, 1).SetVal([]string{"b@example.com", "a@example.com"})
+	mock.ExpectHMGet(NotificationSuppressionKey, "b@example.com", "a@example.com").
+		SetVal([]interface{}{`{"email":"b@example.com","source":"manual"}`, nil})
+
+	entries, total, err := suppression.List(context.Background(), 0, 2)
+	require.NoError(t, err)
+	assert.Equal(t, 3, total)
+	assert.Equal(t, []Suppression{{Email: "b@example.com", Source: SuppressionSourceManual}}, entries)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSuppressionRemove_NotFound(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	suppression := NewSuppressionService(db)
+	mock.ExpectTxPipeline()
+	mock.ExpectHDel(NotificationSuppressionKey, "budi@example.com").SetVal(0)
+	mock.ExpectZRem(NotificationSuppressionIndexKey, "budi@example.com").SetVal(0)
+	mock.ExpectTxPipelineExec()
+
+	assert.ErrorIs(t, suppression.Remove(context.Background(), "budi@example.com"), ErrSuppressionNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,35 @@
+package worker
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Metrik per worker di dalam pool. Label worker berisi indeks goroutine
+// (0..concurrency-1) sehingga kardinalitasnya terbatas.
+var (
+	workerJobs = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "notification_worker_jobs_total",
+		Help: "Jumlah job yang diproses per worker, berdasarkan hasil akhir.",
+	}, []string{"worker", "result"})
+	workerJobDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "notification_worker_job_duration_seconds",
+		Help:    "Lama satu percobaan pemrosesan job per worker; setiap retry diukur sebagai percobaan terpisah.",
+		Buckets: prometheus.ExponentialBuckets(0.05, 2, 12),
+	}, []string{"worker"})
+	workerBusy = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "notification_worker_busy",
+		Help: "1 jika worker sedang memproses job, 0 jika menunggu antrian.",
+	}, []string{"worker"})
+	// jobsExpired menghitung job yang dibuang karena expires_at; stage
+	// "dequeue" berarti sudah kedaluwarsa saat diambil, "retry" berarti retry
+	// berikutnya akan melewati batas waktu.
+	jobsExpired = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "notification_jobs_expired_total",
+		Help: "Jumlah job yang dibuang karena melewati expires_at.",
+	}, []string{"stage"})
+	workersActive = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "notification_workers_active",
+		Help: "Jumlah goroutine worker yang sedang berjalan.",
+	})
+)
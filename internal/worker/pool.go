@@ -0,0 +1,330 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Lumina-Enterprise-Solutions/prism-notification-service/internal/service"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog"
+)
+
+// Sender mengirim email berbasis template. Diimplementasikan oleh
+// *service.EmailService.
+type Sender interface {
+	Send(to, subject, templateName string, data interface{}) error
+}
+
+// Notifier mengirim notifikasi real-time ke user yang sedang online.
+// Diimplementasikan oleh *websocket.Hub.
+type Notifier interface {
+	SendToUser(userID string, message interface{}) bool
+}
+
+// Pool menjalankan sejumlah goroutine worker yang mengambil job dari Queue
+// yang sama. Saat ctx dibatalkan, worker berhenti mengambil job baru dan
+// menyelesaikan job yang sedang berjalan (graceful drain).
+type Pool struct {
+	queue       service.Queue
+	status      service.StatusStore     // opsional
+	suppression service.SuppressionList // opsional
+	sender      Sender
+	notifier    Notifier
+	concurrency int
+	retry       RetryPolicies
+	host        string
+	logger      zerolog.Logger
+
+	errorDelay time.Duration
+	jitter     func() float64
+	now        func() time.Time
+}
+
+func NewPool(queue service.Queue, status service.StatusStore, sender Sender, notifier Notifier, concurrency int, retry RetryPolicies, host string, logger zerolog.Logger) *Pool {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &Pool{
+		queue:       queue,
+		status:      status,
+		sender:      sender,
+		notifier:    notifier,
+		concurrency: concurrency,
+		retry:       retry,
+		host:        host,
+		logger:      logger,
+		errorDelay:  5 * time.Second,
+		jitter:      randomJitter,
+		now:         time.Now,
+	}
+}
+
+// WithSuppression mengaktifkan suppression list: email ke alamat yang ada di
+// daftar tidak dikirim, dan alamat yang hard bounce ditambahkan ke daftar.
+func (p *Pool) WithSuppression(list service.SuppressionList) *Pool {
+	p.suppression = list
+	return p
+}
+
+// Run menjalankan worker dan baru kembali setelah semua worker selesai
+// memproses job terakhirnya.
+func (p *Pool) Run(ctx context.Context) {
+	p.logger.Info().Int("concurrency", p.concurrency).Msg("Worker pool antrian notifikasi dimulai...")
+	var wg sync.WaitGroup
+	for i := 0; i < p.concurrency; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			p.runWorker(ctx, strconv.Itoa(id))
+		}(i)
+	}
+	wg.Wait()
+	p.logger.Info().Msg("Worker pool antrian notifikasi berhenti.")
+}
+
+func (p *Pool) runWorker(ctx context.Context, id string) {
+	workersActive.Inc()
+	defer workersActive.Dec()
+	logger := p.logger.With().Str("worker", id).Logger()
+
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+		job, err := p.queue.Dequeue(ctx)
+		if errors.Is(err, service.ErrPoisonMessage) {
+			// Payload sudah dipindah ke karantina; langsung ambil job berikutnya.
+			logger.Warn().Err(err).Msg("Payload antrian tidak valid dipindahkan ke karantina")
+			workerJobs.WithLabelValues(id, "quarantined").Inc()
+			continue
+		}
+		if errors.Is(err, service.ErrUnsupportedJobVersion) {
+			// Job ditulis worker yang lebih baru dan sudah dibiarkan di antrian;
+			// beri jeda agar worker baru sempat mengambilnya.
+			logger.Warn().Err(err).Msg("Job dengan versi skema yang lebih baru dilewati untuk worker lain")
+			select {
+			case <-ctx.Done():
+			case <-time.After(p.errorDelay):
+			}
+			continue
+		}
+		if err != nil {
+			if !errors.Is(err, redis.Nil) && !errors.Is(err, context.Canceled) {
+				logger.Error().Err(err).Msg("Gagal mengambil job dari antrian, mencoba lagi...")
+				select {
+				case <-ctx.Done():
+				case <-time.After(p.errorDelay):
+				}
+			}
+			continue
+		}
+
+		workerBusy.WithLabelValues(id).Set(1)
+		start := time.Now()
+		result := p.process(job, logger)
+		workerJobDuration.WithLabelValues(id).Observe(time.Since(start).Seconds())
+		workerJobs.WithLabelValues(id, result).Inc()
+		workerBusy.WithLabelValues(id).Set(0)
+	}
+}
+
+// process melakukan satu percobaan kirim dan mengembalikan hasilnya untuk
+// label metrik: "sent", "retry_scheduled", "dead_lettered", "cancelled",
+// "expired", "suppressed" atau "error".
+// Percobaan yang gagal tidak ditunggu di worker; job di-enqueue ulang dengan
+// send_at sesuai backoff sehingga worker langsung bebas. Kegagalan permanen
+// tidak di-retry dan langsung masuk DLQ.
+func (p *Pool) process(job *service.NotificationJob, logger zerolog.Logger) string {
+	logger.Info().Str("notification_id", job.ID).Str("recipient_id", job.RecipientUserID).Str("subject", job.Subject).Int("attempt", job.Attempt+1).Msg("Memproses job notifikasi")
+	if !p.claim(job.ID, logger) {
+		logger.Info().Str("notification_id", job.ID).Msg("Notifikasi sudah dibatalkan, job dilewati")
+		p.ack(job, logger)
+		return "cancelled"
+	}
+	if job.Expired(p.now()) {
+		logger.Warn().Str("notification_id", job.ID).Time("expires_at", *job.ExpiresAt).Msg("Notifikasi kedaluwarsa sebelum dikirim, job dibuang")
+		jobsExpired.WithLabelValues("dequeue").Inc()
+		p.record(job.ID, "", service.StateExpired, nil, logger)
+		p.ack(job, logger)
+		return "expired"
+	}
+
+	// Notifikasi WebSocket hanya dikirim pada percobaan pertama agar retry
+	// email tidak memunculkan notifikasi ganda di UI.
+	if job.Attempt == 0 {
+		if p.notifier.SendToUser(job.RecipientUserID, map[string]string{"type": "new_notification", "subject": job.Subject, "notification_id": job.ID}) {
+			logger.Info().Str("user_id", job.RecipientUserID).Msg("Notifikasi terkirim via WebSocket")
+			p.record(job.ID, service.ChannelWebSocket, service.StateSent, nil, logger)
+		} else {
+			p.record(job.ID, service.ChannelWebSocket, service.StateSkipped, nil, logger)
+		}
+	}
+
+	if p.suppressed(job.To, logger) {
+		logger.Info().Str("notification_id", job.ID).Str("to", job.To).Msg("Alamat penerima ada di suppression list, email tidak dikirim")
+		p.record(job.ID, service.ChannelEmail, service.StateSuppressed, nil, logger)
+		p.record(job.ID, "", service.StateSuppressed, nil, logger)
+		p.ack(job, logger)
+		return "suppressed"
+	}
+
+	sendErr := p.sender.Send(job.To, job.Subject, job.TemplateName, job.TemplateData)
+	if sendErr == nil {
+		p.record(job.ID, service.ChannelEmail, service.StateSent, nil, logger)
+		p.record(job.ID, "", service.StateSent, nil, logger)
+		p.ack(job, logger)
+		return "sent"
+	}
+
+	now := p.now().UTC()
+	retry := *job
+	retry.Attempt++
+	retry.AttemptHistory = append(append([]service.DeliveryAttempt(nil), job.AttemptHistory...),
+		service.DeliveryAttempt{Attempt: retry.Attempt, At: now, Error: sendErr.Error()})
+
+	if service.IsHardBounce(sendErr) {
+		p.suppress(job.To, sendErr, logger)
+	}
+	permanent := service.IsPermanent(sendErr)
+	policy := p.retry.For(job.TemplateName)
+	if !permanent && retry.Attempt < policy.MaxAttempts {
+		delay := policy.Backoff(retry.Attempt, p.jitter())
+		sendAt := now.Add(delay)
+		if retry.Expired(sendAt) {
+			// Retry tidak boleh melewati batas waktu kirim.
+			logger.Warn().Err(sendErr).Int("attempt", retry.Attempt).Time("expires_at", *job.ExpiresAt).Msg("Gagal mengirim email dan retry akan melewati batas waktu, job dibuang")
+			jobsExpired.WithLabelValues("retry").Inc()
+			p.record(job.ID, service.ChannelEmail, service.StateFailed, sendErr, logger)
+			p.record(job.ID, "", service.StateExpired, nil, logger)
+			p.ack(job, logger)
+			return "expired"
+		}
+		retry.SendAt = &sendAt
+		logger.Warn().Err(sendErr).Int("attempt", retry.Attempt).Dur("retry_in", delay).Msg("Gagal mengirim email, dijadwalkan ulang")
+		held, err := p.hold(job, retry)
+		if err == nil && !held {
+			err = p.queue.Enqueue(context.Background(), retry)
+		}
+		if err != nil {
+			// Kembalikan job asli agar tidak hilang; percobaan ini akan diulang.
+			logger.Error().Err(err).Msg("Gagal menjadwalkan ulang job")
+			if err := p.queue.Nack(context.Background(), job); err != nil {
+				logger.Error().Err(err).Msg("Gagal mengembalikan job ke antrian")
+			}
+			return "error"
+		}
+		p.record(job.ID, service.ChannelEmail, service.StateRetrying, sendErr, logger)
+		p.record(job.ID, "", service.StateRetrying, nil, logger)
+		if !held {
+			p.ack(job, logger)
+		}
+		return "retry_scheduled"
+	}
+
+	if permanent {
+		logger.Error().Err(sendErr).Int("attempts", retry.Attempt).Msg("Job gagal permanen, dipindahkan ke DLQ tanpa retry")
+	} else {
+		logger.Error().Err(sendErr).Int("attempts", retry.Attempt).Msg("Job gagal setelah semua percobaan, dipindahkan ke DLQ")
+	}
+	p.record(job.ID, service.ChannelEmail, service.StateFailed, sendErr, logger)
+	p.record(job.ID, "", service.StateFailed, nil, logger)
+	failure := service.DeliveryFailure{
+		Reason:         sendErr.Error(),
+		ErrorClass:     service.ErrorClass(sendErr),
+		Permanent:      permanent,
+		Attempts:       retry.Attempt,
+		AttemptHistory: retry.AttemptHistory,
+		WorkerHost:     p.host,
+	}
+	dead := *job
+	dead.SendAt = nil
+	if err := p.queue.EnqueueToDLQ(context.Background(), dead, failure); err != nil {
+		// Jangan Ack: biarkan job dipulihkan oleh reaper.
+		logger.Error().Err(err).Msg("Gagal memindahkan job ke DLQ")
+		return "error"
+	}
+	p.record(job.ID, "", service.StateDeadLettered, nil, logger)
+	p.ack(job, logger)
+	return "dead_lettered"
+}
+
+// hold menahan job di posisinya selama backoff jika backend mendukungnya
+// (backend partitioned), agar job lain dengan ordering key yang sama tidak
+// mendahului retry.
+func (p *Pool) hold(job *service.NotificationJob, retry service.NotificationJob) (bool, error) {
+	holder, ok := p.queue.(service.RetryHolder)
+	if !ok {
+		return false, nil
+	}
+	return holder.HoldForRetry(context.Background(), job, retry)
+}
+
+func (p *Pool) ack(job *service.NotificationJob, logger zerolog.Logger) {
+	if err := p.queue.Ack(context.Background(), job); err != nil {
+		logger.Error().Err(err).Msg("Gagal melakukan ack job")
+	}
+}
+
+// claim menandai notifikasi sedang diproses. Mengembalikan false jika
+// notifikasi sudah dibatalkan. Jika status store tidak bisa dihubungi job
+// tetap dikirim, sama seperti kegagalan record.
+func (p *Pool) claim(id string, logger zerolog.Logger) bool {
+	if p.status == nil {
+		return true
+	}
+	claimed, err := p.status.Claim(context.Background(), id)
+	if err != nil {
+		logger.Warn().Err(err).Str("notification_id", id).Msg("Gagal mengklaim status notifikasi")
+		return true
+	}
+	return claimed
+}
+
+// suppressed melaporkan apakah alamat ada di suppression list. Jika daftar
+// tidak bisa dibaca, email tetap dikirim.
+func (p *Pool) suppressed(to string, logger zerolog.Logger) bool {
+	if p.suppression == nil {
+		return false
+	}
+	suppressed, err := p.suppression.IsSuppressed(context.Background(), to)
+	if err != nil {
+		logger.Warn().Err(err).Str("to", to).Msg("Gagal memeriksa suppression list")
+		return false
+	}
+	return suppressed
+}
+
+// suppress memasukkan alamat yang hard bounce ke suppression list.
+func (p *Pool) suppress(to string, cause error, logger zerolog.Logger) {
+	if p.suppression == nil {
+		return
+	}
+	if err := p.suppression.Suppress(context.Background(), to, service.SuppressionSourceHardBounce, cause.Error()); err != nil {
+		logger.Warn().Err(err).Str("to", to).Msg("Gagal menambahkan alamat ke suppression list")
+	}
+}
+
+// record mencatat status keseluruhan (channel kosong) atau status satu
+// channel. Kegagalan hanya dicatat di log agar tidak menghambat pengiriman.
+func (p *Pool) record(id, channel string, state service.DeliveryState, cause error, logger zerolog.Logger) {
+	if p.status == nil {
+		return
+	}
+	ctx := context.Background()
+	var err error
+	if channel == "" {
+		err = p.status.Record(ctx, id, state)
+	} else {
+		errMsg := ""
+		if cause != nil {
+			errMsg = cause.Error()
+		}
+		err = p.status.RecordChannel(ctx, id, channel, state, errMsg)
+	}
+	if err != nil {
+		logger.Warn().Err(err).Str("notification_id", id).Str("state", string(state)).Msg("Gagal mencatat status notifikasi")
+	}
+}
@@ -0,0 +1,503 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Lumina-Enterprise-Solutions/prism-notification-service/internal/service"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// chanQueue adalah Queue sederhana berbasis channel untuk pengujian pool.
+type chanQueue struct {
+	jobs chan *service.NotificationJob
+
+	mu     sync.Mutex
+	acked  []string
+	nacked []string
+	dlq    []service.DeliveryFailure
+}
+
+func newChanQueue(jobs ...service.NotificationJob) *chanQueue {
+	q := &chanQueue{jobs: make(chan *service.NotificationJob, len(jobs))}
+	for i := range jobs {
+		q.jobs <- &jobs[i]
+	}
+	return q
+}
+
+func (q *chanQueue) Enqueue(ctx context.Context, job service.NotificationJob) error {
+	q.jobs <- &job
+	return nil
+}
+
+func (q *chanQueue) Dequeue(ctx context.Context) (*service.NotificationJob, error) {
+	select {
+	case job := <-q.jobs:
+		return job, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(10 * time.Millisecond):
+		return nil, redis.Nil
+	}
+}
+
+func (q *chanQueue) Ack(ctx context.Context, job *service.NotificationJob) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.acked = append(q.acked, job.ID)
+	return nil
+}
+
+func (q *chanQueue) Nack(ctx context.Context, job *service.NotificationJob) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.nacked = append(q.nacked, job.ID)
+	return nil
+}
+
+func (q *chanQueue) EnqueueToDLQ(ctx context.Context, job service.NotificationJob, failure service.DeliveryFailure) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.dlq = append(q.dlq, failure)
+	return nil
+}
+
+func (q *chanQueue) snapshot() (acked, nacked []string, dlq []service.DeliveryFailure) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return append([]string(nil), q.acked...), append([]string(nil), q.nacked...), append([]service.DeliveryFailure(nil), q.dlq...)
+}
+
+type funcSender func(to, subject, templateName string, data interface{}) error
+
+func (f funcSender) Send(to, subject, templateName string, data interface{}) error {
+	return f(to, subject, templateName, data)
+}
+
+type offlineNotifier struct{}
+
+func (offlineNotifier) SendToUser(userID string, message interface{}) bool { return false }
+
+func jobs(n int) []service.NotificationJob {
+	out := make([]service.NotificationJob, n)
+	for i := range out {
+		out[i] = service.NotificationJob{ID: string(rune('a' + i)), To: "user@example.com", TemplateName: "welcome.html"}
+	}
+	return out
+}
+
+// poisonQueue mengembalikan ErrPoisonMessage sekali sebelum job normal.
+type poisonQueue struct {
+	*chanQueue
+	poisoned atomic.Bool
+}
+
+func (q *poisonQueue) Dequeue(ctx context.Context) (*service.NotificationJob, error) {
+	if q.poisoned.CompareAndSwap(false, true) {
+		return nil, service.ErrPoisonMessage
+	}
+	return q.chanQueue.Dequeue(ctx)
+}
+
+// newerVersionQueue mengembalikan ErrUnsupportedJobVersion sekali sebelum
+// job normal.
+type newerVersionQueue struct {
+	*chanQueue
+	returned atomic.Bool
+}
+
+func (q *newerVersionQueue) Dequeue(ctx context.Context) (*service.NotificationJob, error) {
+	if q.returned.CompareAndSwap(false, true) {
+		return nil, service.ErrUnsupportedJobVersion
+	}
+	return q.chanQueue.Dequeue(ctx)
+}
+
+func TestPool_NewerVersionJobBacksOff(t *testing.T) {
+	queue := &newerVersionQueue{chanQueue: newChanQueue(jobs(1)...)}
+	sender := funcSender(func(to, subject, templateName string, data interface{}) error { return nil })
+
+	pool := NewPool(queue, nil, sender, offlineNotifier{}, 1, RetryPolicies{}, "test-host", zerolog.Nop())
+	pool.errorDelay = 50 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	start := time.Now()
+	go func() {
+		pool.Run(ctx)
+		close(done)
+	}()
+
+	require.Eventually(t, func() bool {
+		acked, _, _ := queue.snapshot()
+		return len(acked) == 1
+	}, 2*time.Second, 5*time.Millisecond)
+	assert.GreaterOrEqual(t, time.Since(start), pool.errorDelay, "worker lama harus memberi jeda sebelum mengambil job lagi")
+	cancel()
+	<-done
+}
+
+func TestPool_PoisonMessageDoesNotDelayWorker(t *testing.T) {
+	queue := &poisonQueue{chanQueue: newChanQueue(jobs(1)...)}
+	sender := funcSender(func(to, subject, templateName string, data interface{}) error { return nil })
+
+	pool := NewPool(queue, nil, sender, offlineNotifier{}, 1, RetryPolicies{}, "test-host", zerolog.Nop())
+	pool.errorDelay = time.Hour
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		pool.Run(ctx)
+		close(done)
+	}()
+
+	require.Eventually(t, func() bool {
+		acked, _, _ := queue.snapshot()
+		return len(acked) == 1
+	}, 2*time.Second, 5*time.Millisecond, "job berikutnya harus diproses tanpa menunggu errorDelay")
+	cancel()
+	<-done
+}
+
+func TestPool_ProcessesJobsConcurrently(t *testing.T) {
+	queue := newChanQueue(jobs(3)...)
+	// Setiap Send menunggu sampai ketiga job sedang diproses bersamaan.
+	var inFlight sync.WaitGroup
+	inFlight.Add(3)
+	sender := funcSender(func(to, subject, templateName string, data interface{}) error {
+		inFlight.Done()
+		inFlight.Wait()
+		return nil
+	})
+
+	pool := NewPool(queue, nil, sender, offlineNotifier{}, 3, RetryPolicies{}, "test-host", zerolog.Nop())
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		pool.Run(ctx)
+		close(done)
+	}()
+
+	require.Eventually(t, func() bool {
+		acked, _, _ := queue.snapshot()
+		return len(acked) == 3
+	}, 2*time.Second, 5*time.Millisecond, "3 job harus selesai paralel oleh 3 worker")
+	cancel()
+	<-done
+}
+
+func TestPool_DrainsInFlightJobOnShutdown(t *testing.T) {
+	queue := newChanQueue(jobs(1)...)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	sender := funcSender(func(to, subject, templateName string, data interface{}) error {
+		close(started)
+		<-release
+		return nil
+	})
+
+	pool := NewPool(queue, nil, sender, offlineNotifier{}, 2, RetryPolicies{}, "test-host", zerolog.Nop())
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		pool.Run(ctx)
+		close(done)
+	}()
+
+	<-started
+	cancel()
+	select {
+	case <-done:
+		t.Fatal("Run tidak boleh kembali sebelum job in-flight selesai")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	<-done
+	acked, _, _ := queue.snapshot()
+	assert.Equal(t, []string{"a"}, acked)
+}
+
+func TestPool_ExhaustedRetriesGoToDLQ(t *testing.T) {
+	queue := newChanQueue(jobs(1)...)
+	var calls atomic.Int32
+	sender := funcSender(func(to, subject, templateName string, data interface{}) error {
+		calls.Add(1)
+		return errors.New("smtp down")
+	})
+
+	// chanQueue mengabaikan send_at sehingga retry langsung diproses ulang.
+	pool := NewPool(queue, nil, sender, offlineNotifier{}, 1, RetryPolicies{}, "test-host", zerolog.Nop())
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		pool.Run(ctx)
+		close(done)
+	}()
+
+	require.Eventually(t, func() bool {
+		_, _, dlq := queue.snapshot()
+		return len(dlq) == 1
+	}, 2*time.Second, 5*time.Millisecond)
+	cancel()
+	<-done
+
+	acked, _, dlq := queue.snapshot()
+	assert.Len(t, acked, 3, "setiap percobaan meng-Ack job yang diambilnya")
+	assert.Equal(t, int32(3), calls.Load())
+	assert.Equal(t, 3, dlq[0].Attempts)
+	require.Len(t, dlq[0].AttemptHistory, 3)
+	assert.Equal(t, 3, dlq[0].AttemptHistory[2].Attempt)
+	assert.Equal(t, "test-host", dlq[0].WorkerHost)
+}
+
+func TestPool_FailedSendIsRescheduledWithBackoff(t *testing.T) {
+	queue := newChanQueue()
+	queue.jobs = make(chan *service.NotificationJob, 1)
+	sender := funcSender(func(to, subject, templateName string, data interface{}) error {
+		return errors.New("smtp down")
+	})
+	policies := RetryPolicies{
+		Default:     RetryPolicy{MaxAttempts: 5, BaseDelay: 10 * time.Second, MaxDelay: time.Minute},
+		PerTemplate: map[string]RetryPolicy{"otp.html": {BaseDelay: time.Second}},
+	}
+	pool := NewPool(queue, nil, sender, offlineNotifier{}, 1, policies, "test-host", zerolog.Nop())
+	now := time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC)
+	pool.now = func() time.Time { return now }
+	pool.jitter = func() float64 { return 0 }
+
+	job := &service.NotificationJob{ID: "n-1", TemplateName: "otp.html", Attempt: 1,
+		AttemptHistory: []service.DeliveryAttempt{{Attempt: 1, Error: "timeout"}}}
+	result := pool.process(job, zerolog.Nop())
+
+	assert.Equal(t, "retry_scheduled", result)
+	acked, _, dlq := queue.snapshot()
+	assert.Equal(t, []string{"n-1"}, acked)
+	assert.Empty(t, dlq)
+
+	retry := <-queue.jobs
+	assert.Equal(t, 2, retry.Attempt)
+	require.Len(t, retry.AttemptHistory, 2)
+	assert.Equal(t, "smtp down", retry.AttemptHistory[1].Error)
+	// Percobaan gagal ke-2 dengan base 1s: 2s, equal jitter 0 -> 1s.
+	require.NotNil(t, retry.SendAt)
+	assert.Equal(t, now.Add(time.Second), *retry.SendAt)
+	assert.Len(t, job.AttemptHistory, 1, "job asli tidak boleh ikut berubah")
+}
+
+// cancelledStatus menolak Claim untuk ID yang sudah dibatalkan.
+type cancelledStatus struct {
+	service.StatusStore
+	cancelled map[string]bool
+}
+
+func (s *cancelledStatus) Claim(ctx context.Context, id string) (bool, error) {
+	return !s.cancelled[id], nil
+}
+
+func (s *cancelledStatus) Record(ctx context.Context, id string, state service.DeliveryState) error {
+	return nil
+}
+
+func (s *cancelledStatus) RecordChannel(ctx context.Context, id, channel string, state service.DeliveryState, errMsg string) error {
+	return nil
+}
+
+func TestPool_SkipsCancelledJob(t *testing.T) {
+	queue := newChanQueue()
+	var sent atomic.Int32
+	sender := funcSender(func(to, subject, templateName string, data interface{}) error {
+		sent.Add(1)
+		return nil
+	})
+	status := &cancelledStatus{cancelled: map[string]bool{"n-1": true}}
+	pool := NewPool(queue, status, sender, offlineNotifier{}, 1, RetryPolicies{}, "test-host", zerolog.Nop())
+
+	assert.Equal(t, "cancelled", pool.process(&service.NotificationJob{ID: "n-1"}, zerolog.Nop()))
+	assert.Equal(t, "sent", pool.process(&service.NotificationJob{ID: "n-2"}, zerolog.Nop()))
+
+	assert.Equal(t, int32(1), sent.Load())
+	acked, _, _ := queue.snapshot()
+	assert.Equal(t, []string{"n-1", "n-2"}, acked, "job yang dibatalkan tetap di-ack agar keluar dari antrian")
+}
+
+func TestPool_DropsExpiredJob(t *testing.T) {
+	queue := newChanQueue()
+	var sent atomic.Int32
+	sender := funcSender(func(to, subject, templateName string, data interface{}) error {
+		sent.Add(1)
+		return nil
+	})
+	pool := NewPool(queue, nil, sender, offlineNotifier{}, 1, RetryPolicies{}, "test-host", zerolog.Nop())
+	now := time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC)
+	pool.now = func() time.Time { return now }
+
+	expiresAt := now.Add(-time.Second)
+	result := pool.process(&service.NotificationJob{ID: "n-1", ExpiresAt: &expiresAt}, zerolog.Nop())
+
+	assert.Equal(t, "expired", result)
+	assert.Zero(t, sent.Load())
+	acked, _, dlq := queue.snapshot()
+	assert.Equal(t, []string{"n-1"}, acked)
+	assert.Empty(t, dlq)
+}
+
+func TestPool_RetryDoesNotExtendPastDeadline(t *testing.T) {
+	queue := newChanQueue()
+	queue.jobs = make(chan *service.NotificationJob, 1)
+	sender := funcSender(func(to, subject, templateName string, data interface{}) error {
+		return errors.New("smtp down")
+	})
+	policies := RetryPolicies{Default: RetryPolicy{MaxAttempts: 5, BaseDelay: 10 * time.Second, MaxDelay: time.Minute}}
+	pool := NewPool(queue, nil, sender, offlineNotifier{}, 1, policies, "test-host", zerolog.Nop())
+	now := time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC)
+	pool.now = func() time.Time { return now }
+	pool.jitter = func() float64 { return 1 }
+
+	// Retry pertama dijadwalkan 10s lagi, setelah batas 5s.
+	expiresAt := now.Add(5 * time.Second)
+	result := pool.process(&service.NotificationJob{ID: "n-1", ExpiresAt: &expiresAt}, zerolog.Nop())
+
+	assert.Equal(t, "expired", result)
+	assert.Empty(t, queue.jobs, "retry tidak boleh di-enqueue")
+	acked, _, dlq := queue.snapshot()
+	assert.Equal(t, []string{"n-1"}, acked)
+	assert.Empty(t, dlq)
+}
+
+// memorySuppression adalah SuppressionList di memori.
+type memorySuppression struct {
+	service.SuppressionList
+	emails map[string]string
+}
+
+func (s *memorySuppression) Suppress(ctx context.Context, email, source, reason string) error {
+	s.emails[email] = source
+	return nil
+}
+
+func (s *memorySuppression) IsSuppressed(ctx context.Context, email string) (bool, error) {
+	_, ok := s.emails[email]
+	return ok, nil
+}
+
+func TestPool_PermanentFailureSkipsRetries(t *testing.T) {
+	queue := newChanQueue()
+	queue.jobs = make(chan *service.NotificationJob, 1)
+	sender := funcSender(func(to, subject, templateName string, data interface{}) error {
+		return &service.DeliveryError{Err: errors.New("554 5.7.1 message rejected"), Permanent: true}
+	})
+	suppression := &memorySuppression{emails: map[string]string{}}
+	pool := NewPool(queue, nil, sender, offlineNotifier{}, 1, RetryPolicies{}, "test-host", zerolog.Nop()).WithSuppression(suppression)
+
+	result := pool.process(&service.NotificationJob{ID: "n-1", To: "budi@example.com"}, zerolog.Nop())
+
+	assert.Equal(t, "dead_lettered", result)
+	assert.Empty(t, queue.jobs, "kegagalan permanen tidak di-retry")
+	_, _, dlq := queue.snapshot()
+	require.Len(t, dlq, 1)
+	assert.True(t, dlq[0].Permanent)
+	assert.Equal(t, 1, dlq[0].Attempts)
+	assert.Empty(t, suppression.emails, "penolakan pesan bukan hard bounce")
+}
+
+func TestPool_HardBounceIsSuppressed(t *testing.T) {
+	queue := newChanQueue()
+	var sent atomic.Int32
+	sender := funcSender(func(to, subject, templateName string, data interface{}) error {
+		sent.Add(1)
+		return &service.DeliveryError{Err: errors.New("550 5.1.1 no such user"), Permanent: true, HardBounce: true}
+	})
+	suppression := &memorySuppression{emails: map[string]string{}}
+	pool := NewPool(queue, nil, sender, offlineNotifier{}, 1, RetryPolicies{}, "test-host", zerolog.Nop()).WithSuppression(suppression)
+
+	assert.Equal(t, "dead_lettered", pool.process(&service.NotificationJob{ID: "n-1", To: "ghost@example.com"}, zerolog.Nop()))
+	assert.Equal(t, service.SuppressionSourceHardBounce, suppression.emails["ghost@example.com"])
+
+	// Job berikutnya ke alamat yang sama tidak dikirim lagi.
+	assert.Equal(t, "suppressed", pool.process(&service.NotificationJob{ID: "n-2", To: "ghost@example.com"}, zerolog.Nop()))
+	assert.Equal(t, int32(1), sent.Load())
+	acked, _, dlq := queue.snapshot()
+	assert.Equal(t, []string{"n-1", "n-2"}, acked)
+	assert.Len(t, dlq, 1)
+}
+
+// partitionQueue meniru satu partisi backend partitioned: hanya kepala
+// antrian yang bisa diambil, dan HoldForRetry menahannya sampai SendAt.
+type partitionQueue struct {
+	*chanQueue
+	now       func() time.Time
+	pending   []service.NotificationJob
+	inFlight  bool
+	heldUntil time.Time
+}
+
+func (q *partitionQueue) Dequeue(ctx context.Context) (*service.NotificationJob, error) {
+	if q.inFlight || len(q.pending) == 0 || q.now().Before(q.heldUntil) {
+		return nil, redis.Nil
+	}
+	q.inFlight = true
+	job := q.pending[0]
+	return &job, nil
+}
+
+func (q *partitionQueue) Ack(ctx context.Context, job *service.NotificationJob) error {
+	q.pending = q.pending[1:]
+	q.inFlight = false
+	return q.chanQueue.Ack(ctx, job)
+}
+
+func (q *partitionQueue) HoldForRetry(ctx context.Context, job *service.NotificationJob, retry service.NotificationJob) (bool, error) {
+	q.pending[0] = retry
+	q.inFlight = false
+	q.heldUntil = *retry.SendAt
+	return true, nil
+}
+
+func TestPool_RetryHoldsOrderingKey(t *testing.T) {
+	now := time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC)
+	queue := &partitionQueue{chanQueue: newChanQueue(), now: func() time.Time { return now }, pending: []service.NotificationJob{
+		{ID: "confirmed", OrderingKey: "order-1"},
+		{ID: "shipped", OrderingKey: "order-1"},
+	}}
+	var sent []string
+	sender := funcSender(func(to, subject, templateName string, data interface{}) error {
+		if len(sent) == 0 {
+			sent = append(sent, "failed")
+			return errors.New("smtp down")
+		}
+		sent = append(sent, subject)
+		return nil
+	})
+	pool := NewPool(queue, nil, sender, offlineNotifier{}, 1, RetryPolicies{Default: RetryPolicy{MaxAttempts: 3, BaseDelay: 10 * time.Second}}, "test-host", zerolog.Nop())
+	pool.now = func() time.Time { return now }
+	pool.jitter = func() float64 { return 0 }
+	next := func() *service.NotificationJob {
+		job, err := queue.Dequeue(context.Background())
+		if err != nil {
+			return nil
+		}
+		job.Subject = job.ID
+		return job
+	}
+
+	assert.Equal(t, "retry_scheduled", pool.process(next(), zerolog.Nop()))
+	assert.Nil(t, next(), "job shipped harus menunggu selama confirmed menunggu retry")
+	acked, _, _ := queue.snapshot()
+	assert.Empty(t, acked, "job yang ditahan tidak boleh di-Ack")
+
+	now = now.Add(10 * time.Second)
+	retry := next()
+	require.NotNil(t, retry)
+	assert.Equal(t, "confirmed", retry.ID)
+	assert.Equal(t, 1, retry.Attempt)
+	assert.Equal(t, "sent", pool.process(retry, zerolog.Nop()))
+	assert.Equal(t, "sent", pool.process(next(), zerolog.Nop()))
+	assert.Equal(t, []string{"failed", "confirmed", "shipped"}, sent)
+}
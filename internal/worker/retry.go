@@ -0,0 +1,64 @@
+package worker
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// RetryPolicy mengatur berapa kali pengiriman dicoba dan jeda antar
+// percobaan. Field bernilai nol mengikuti policy default.
+type RetryPolicy struct {
+	// MaxAttempts adalah jumlah total percobaan, termasuk yang pertama.
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// DefaultRetryPolicy setara dengan perilaku lama: 3 percobaan berjarak ~20 detik.
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 3, BaseDelay: 20 * time.Second, MaxDelay: 10 * time.Minute}
+
+// RetryPolicies memilih RetryPolicy per template dengan fallback ke Default.
+type RetryPolicies struct {
+	Default     RetryPolicy
+	PerTemplate map[string]RetryPolicy
+}
+
+func (r RetryPolicies) For(templateName string) RetryPolicy {
+	policy := r.Default.withDefaults(DefaultRetryPolicy)
+	if override, ok := r.PerTemplate[templateName]; ok {
+		policy = override.withDefaults(policy)
+	}
+	return policy
+}
+
+func (p RetryPolicy) withDefaults(d RetryPolicy) RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = d.MaxAttempts
+	}
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = d.BaseDelay
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = d.MaxDelay
+	}
+	return p
+}
+
+// Backoff menghitung jeda sebelum percobaan berikutnya setelah attempt
+// percobaan gagal (attempt >= 1): BaseDelay * 2^(attempt-1), dibatasi
+// MaxDelay, dengan "equal jitter" agar retry dari banyak job tidak
+// serentak. jitter adalah bilangan acak di [0, 1).
+func (p RetryPolicy) Backoff(attempt int, jitter float64) time.Duration {
+	delay := p.MaxDelay
+	if shift := attempt - 1; shift < 32 {
+		if d := p.BaseDelay << shift; d > 0 && d < p.MaxDelay {
+			delay = d
+		}
+	}
+	half := delay / 2
+	return half + time.Duration(jitter*float64(delay-half))
+}
+
+func randomJitter() float64 {
+	return rand.Float64()
+}
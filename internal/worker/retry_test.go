@@ -0,0 +1,32 @@
+package worker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryPolicies_ForMergesTemplateOverride(t *testing.T) {
+	policies := RetryPolicies{
+		Default:     RetryPolicy{MaxAttempts: 4, BaseDelay: 30 * time.Second},
+		PerTemplate: map[string]RetryPolicy{"password_reset.html": {MaxAttempts: 8}},
+	}
+
+	assert.Equal(t, RetryPolicy{MaxAttempts: 4, BaseDelay: 30 * time.Second, MaxDelay: DefaultRetryPolicy.MaxDelay}, policies.For("welcome.html"))
+	assert.Equal(t, RetryPolicy{MaxAttempts: 8, BaseDelay: 30 * time.Second, MaxDelay: DefaultRetryPolicy.MaxDelay}, policies.For("password_reset.html"))
+	assert.Equal(t, DefaultRetryPolicy, RetryPolicies{}.For("welcome.html"))
+}
+
+func TestRetryPolicy_BackoffGrowsExponentiallyUpToCap(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 10, BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+
+	// Tanpa jitter (0) hasilnya setengah jeda; dengan jitter mendekati 1 hampir penuh.
+	assert.Equal(t, 500*time.Millisecond, policy.Backoff(1, 0))
+	assert.Equal(t, time.Second, policy.Backoff(2, 0))
+	assert.Equal(t, 2*time.Second, policy.Backoff(3, 0))
+	assert.Equal(t, 6*time.Second, policy.Backoff(4, 0.5))
+	assert.Equal(t, 5*time.Second, policy.Backoff(5, 0), "dibatasi MaxDelay")
+	assert.Equal(t, 5*time.Second, policy.Backoff(100, 0), "tidak overflow untuk attempt besar")
+	assert.Less(t, policy.Backoff(5, 0.999), 10*time.Second)
+}
@@ -19,6 +19,7 @@ import (
 	"github.com/Lumina-Enterprise-Solutions/prism-notification-service/internal/handler"
 	"github.com/Lumina-Enterprise-Solutions/prism-notification-service/internal/service"
 	"github.com/Lumina-Enterprise-Solutions/prism-notification-service/internal/websocket"
+	"github.com/Lumina-Enterprise-Solutions/prism-notification-service/internal/worker"
 	"github.com/gin-gonic/gin"
 	"github.com/redis/go-redis/v9"
 	"github.com/rs/zerolog" // Impor zerolog untuk menggunakan tipenya
@@ -33,13 +34,21 @@ func setupDependencies(cfg *notifconfig.Config, logger zerolog.Logger) error {
 		return fmt.Errorf("gagal membuat klien Vault: %w", err)
 	}
 	secretPath := "secret/data/prism"
-	requiredSecrets := []string{
-		"mailtrap_host", "mailtrap_port", "mailtrap_user", "mailtrap_pass",
+	var requiredSecrets []string
+	for _, provider := range cfg.EmailProviders() {
+		switch provider {
+		case service.EmailProviderSES:
+			requiredSecrets = append(requiredSecrets, "ses_access_key_id", "ses_secret_access_key")
+		case service.EmailProviderSendGrid:
+			requiredSecrets = append(requiredSecrets, "sendgrid_api_key")
+		default:
+			requiredSecrets = append(requiredSecrets, "mailtrap_host", "mailtrap_port", "mailtrap_user", "mailtrap_pass")
+		}
 	}
 	if err := vaultClient.LoadSecretsToEnv(secretPath, requiredSecrets...); err != nil {
-		return fmt.Errorf("gagal memuat kredensial Mailtrap dari Vault: %w", err)
+		return fmt.Errorf("gagal memuat kredensial provider email %s dari Vault: %w", cfg.EmailProvider, err)
 	}
-	logger.Info().Msg("Kredensial Mailtrap berhasil dimuat dari Vault.")
+	logger.Info().Str("email_provider", cfg.EmailProvider).Msg("Kredensial provider email berhasil dimuat dari Vault.")
 	return nil
 }
 
@@ -52,6 +61,7 @@ func main() {
 	enhanced_logger.LogStartup(cfg.ServiceName, cfg.Port, map[string]interface{}{
 		"jaeger_endpoint": cfg.JaegerEndpoint,
 		"redis_addr":      cfg.RedisAddr,
+		"queue_backend":   cfg.QueueBackend,
 	})
 
 	tp, err := telemetry.InitTracerProvider(cfg.ServiceName, cfg.JaegerEndpoint)
@@ -64,8 +74,14 @@ func main() {
 		}
 	}()
 
+	// Backend memory ditujukan untuk pengembangan lokal tanpa Redis maupun
+	// Vault; tanpa kredensial provider email hanya disimulasikan.
+	memoryBackend := cfg.QueueBackend == "memory"
 	if err := setupDependencies(cfg, serviceLogger); err != nil {
-		serviceLogger.Fatal().Err(err).Msg("Gagal menginisialisasi dependensi")
+		if !memoryBackend {
+			serviceLogger.Fatal().Err(err).Msg("Gagal menginisialisasi dependensi")
+		}
+		serviceLogger.Warn().Err(err).Msg("Dependensi tidak tersedia, melanjutkan dengan backend memory")
 	}
 
 	// === Setup Komponen Inti ===
@@ -79,13 +95,106 @@ func main() {
 	hub := websocket.NewHub()
 	go hub.Run()
 
-	emailService := service.NewEmailService()
-	queueService := service.NewQueueService(redisClient) // FIX: Pass Redis client yang sudah ada
-	notificationHandler := handler.NewNotificationHandler(queueService, hub)
-
-	// === Jalankan Worker Background ===
 	workerCtx, workerCancel := context.WithCancel(context.Background())
-	go runWorker(workerCtx, queueService, emailService, hub, serviceLogger)
+	sender, err := emailSender(workerCtx, cfg, serviceLogger)
+	if err != nil {
+		serviceLogger.Fatal().Err(err).Msg("Gagal menginisialisasi provider email")
+	}
+	emailService := service.NewEmailService(sender, cfg.EmailFrom)
+
+	var queueService service.Queue
+	var memoryQueue *service.MemoryQueue
+	switch {
+	case memoryBackend:
+		memoryQueue = service.NewMemoryQueue()
+		queueService = memoryQueue
+	case cfg.QueueBackend == "stream":
+		streamQueue := service.NewStreamQueueService(redisClient, consumerName(), cfg.QueueVisibilityTimeout)
+		if err := streamQueue.EnsureGroup(context.Background()); err != nil {
+			serviceLogger.Fatal().Err(err).Msg("Gagal menyiapkan Redis Stream")
+		}
+		go streamQueue.RunMonitor(workerCtx, cfg.QueueReaperInterval)
+		queueService = streamQueue
+	case cfg.QueueBackend == "fair":
+		fairQueue := service.NewFairQueueService(redisClient, cfg.TenantWeights, cfg.TenantMaxInflight, cfg.QueueVisibilityTimeout)
+		go fairQueue.RunReaper(workerCtx, cfg.QueueReaperInterval)
+		queueService = fairQueue
+	case cfg.QueueBackend == "partitioned":
+		queueService = service.NewPartitionedQueueService(redisClient, cfg.QueuePartitions, cfg.QueueVisibilityTimeout)
+	case cfg.QueueReliable:
+		reliableQueue := service.NewReliableQueueService(redisClient, consumerName(), cfg.QueueVisibilityTimeout).
+			WithLanePolicy(lanePolicy(cfg))
+		go reliableQueue.RunReaper(workerCtx, cfg.QueueReaperInterval)
+		queueService = reliableQueue
+	default:
+		queueService = service.NewQueueService(redisClient).WithLanePolicy(lanePolicy(cfg)) // FIX: Pass Redis client yang sudah ada
+	}
+	switch cfg.QueueBackend {
+	case "stream", "fair", "partitioned":
+		serviceLogger.Warn().Str("queue_backend", cfg.QueueBackend).Msg("Lane prioritas hanya didukung backend list; field priority diabaikan")
+	case "memory":
+	default:
+		go service.RunLaneMonitor(workerCtx, redisClient, cfg.QueueReaperInterval)
+	}
+
+	limits := service.BackpressureLimits{
+		QueueHighWater:  int64(cfg.QueueHighWaterMark),
+		TenantHighWater: int64(cfg.TenantHighWaterMark),
+		BulkShedPercent: cfg.BulkShedPercent,
+		RetryAfter:      cfg.BackpressureRetryAfter,
+	}
+	// Status, idempotency, jadwal berulang, karantina dan suppression list
+	// membutuhkan Redis sehingga tidak aktif pada backend memory.
+	var (
+		statusStore        service.StatusStore
+		idempotencyStore   service.IdempotencyStore
+		deadLetters        service.DeadLetterQueue
+		suppressionList    service.SuppressionList
+		scheduleHandler    *handler.ScheduleHandler
+		quarantineHandler  *handler.QuarantineHandler
+		suppressionHandler *handler.SuppressionHandler
+	)
+	if memoryBackend {
+		// MemoryQueue menahan job send_at sendiri dan menyimpan DLQ di memori.
+		limits.TenantHighWater = 0
+		deadLetters = memoryQueue
+		serviceLogger.Warn().Msg("Backend memory aktif: antrian dan DLQ hilang saat restart; status, idempotency, jadwal berulang, karantina dan suppression list dinonaktifkan")
+	} else {
+		// Job dengan send_at di masa depan diparkir di sorted set lalu dipromosikan oleh scheduler.
+		scheduledQueue := service.NewScheduledQueue(redisClient, queueService)
+		go scheduledQueue.Run(workerCtx, cfg.SchedulerInterval)
+		queueService = scheduledQueue
+		statusStore = service.NewStatusService(redisClient, cfg.StatusTTL)
+		idempotencyStore = service.NewIdempotencyService(redisClient, cfg.IdempotencyWindow)
+		suppressionList = service.NewSuppressionService(redisClient)
+	}
+	// Backpressure menolak job baru saat antrian jenuh; retry dari worker tetap diterima.
+	queueService = service.NewBackpressureQueue(redisClient, queueService, limits)
+	if !memoryBackend {
+		// Replay DLQ melewati backpressure agar slot tenant dihitung ulang tanpa admission.
+		deadLetters = service.NewDLQService(redisClient, queueService)
+	}
+	notificationHandler := handler.NewNotificationHandler(queueService, idempotencyStore, statusStore, hub)
+	dlqHandler := handler.NewDLQHandler(deadLetters)
+
+	if !memoryBackend {
+		// Jadwal berulang (cron); hanya replika pemegang lock yang membuat job.
+		scheduleService := service.NewScheduleService(redisClient)
+		recurringScheduler := service.NewRecurringScheduler(scheduleService, queueService, statusStore, consumerName())
+		go recurringScheduler.Run(workerCtx, cfg.SchedulerInterval)
+		scheduleHandler = handler.NewScheduleHandler(scheduleService)
+		quarantineHandler = handler.NewQuarantineHandler(service.NewQuarantineService(redisClient))
+		suppressionHandler = handler.NewSuppressionHandler(suppressionList)
+	}
+
+	// === Jalankan Worker Pool Background ===
+	workerPool := worker.NewPool(queueService, statusStore, emailService, hub, cfg.WorkerConcurrency, retryPolicies(cfg), consumerName(), serviceLogger).
+		WithSuppression(suppressionList)
+	workerDone := make(chan struct{})
+	go func() {
+		workerPool.Run(workerCtx)
+		close(workerDone)
+	}()
 
 	// === Setup Server HTTP ===
 	portStr := strconv.Itoa(cfg.Port)
@@ -96,13 +205,56 @@ func main() {
 
 	// FIX: Gunakan redisClient yang sama untuk JWT middleware
 	jwtAuthMiddleware := auth.JWTMiddleware(redisClient)
+	if memoryBackend {
+		// Tanpa Redis daftar token yang dicabut tidak bisa diperiksa; token
+		// hanya diverifikasi tanda tangan dan masa berlakunya.
+		jwtAuthMiddleware = handler.LocalJWTMiddleware()
+		serviceLogger.Warn().Msg("Backend memory aktif: JWT pada /ws dan /admin hanya diverifikasi tanda tangan dan masa berlakunya, pencabutan token tidak diperiksa")
+	}
 
 	// --- Rute API ---
 	notificationRoutes := router.Group("/notifications")
 	{
 		notificationRoutes.GET("/health", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"status": "healthy"}) })
 		notificationRoutes.POST("/send", notificationHandler.SendNotification)
+		notificationRoutes.POST("/send/batch", notificationHandler.SendBatch)
 		notificationRoutes.GET("/ws", jwtAuthMiddleware, notificationHandler.HandleWebSocket)
+		notificationRoutes.GET("/:id", notificationHandler.GetNotificationStatus)
+		notificationRoutes.DELETE("/:id", notificationHandler.CancelNotification)
+
+		if scheduleHandler != nil {
+			// Schedule berisi alamat penerima dan data template, jadi hanya
+			// caller terautentikasi yang boleh membaca atau mengubahnya.
+			scheduleRoutes := notificationRoutes.Group("/schedules", jwtAuthMiddleware)
+			scheduleRoutes.POST("", scheduleHandler.CreateSchedule)
+			scheduleRoutes.GET("", scheduleHandler.ListSchedules)
+			scheduleRoutes.GET("/:id", scheduleHandler.GetSchedule)
+			scheduleRoutes.PUT("/:id", scheduleHandler.UpdateSchedule)
+			scheduleRoutes.DELETE("/:id", scheduleHandler.DeleteSchedule)
+		}
+
+		dlqRoutes := notificationRoutes.Group("/admin/dlq", jwtAuthMiddleware, auth.AdminOnly())
+		dlqRoutes.GET("", dlqHandler.ListEntries)
+		dlqRoutes.DELETE("", dlqHandler.PurgeEntries)
+		dlqRoutes.POST("/replay", dlqHandler.ReplayEntries)
+		dlqRoutes.GET("/:id", dlqHandler.GetEntry)
+		dlqRoutes.DELETE("/:id", dlqHandler.DeleteEntry)
+		dlqRoutes.POST("/:id/replay", dlqHandler.ReplayEntry)
+
+		if quarantineHandler != nil {
+			quarantineRoutes := notificationRoutes.Group("/admin/quarantine", jwtAuthMiddleware, auth.AdminOnly())
+			quarantineRoutes.GET("", quarantineHandler.ListEntries)
+			quarantineRoutes.DELETE("", quarantineHandler.PurgeEntries)
+			quarantineRoutes.GET("/:id", quarantineHandler.GetEntry)
+			quarantineRoutes.DELETE("/:id", quarantineHandler.DeleteEntry)
+		}
+		if suppressionHandler != nil {
+			suppressionRoutes := notificationRoutes.Group("/admin/suppressions", jwtAuthMiddleware, auth.AdminOnly())
+			suppressionRoutes.GET("", suppressionHandler.ListSuppressions)
+			suppressionRoutes.POST("", suppressionHandler.AddSuppression)
+			suppressionRoutes.GET("/:email", suppressionHandler.GetSuppression)
+			suppressionRoutes.DELETE("/:email", suppressionHandler.RemoveSuppression)
+		}
 	}
 
 	srv := &http.Server{
@@ -124,7 +276,14 @@ func main() {
 
 	serviceLogger.Info().Msg("Sinyal shutdown diterima, memulai graceful shutdown...")
 
+	// Berhenti mengambil job baru dan tunggu job yang sedang diproses selesai.
 	workerCancel()
+	select {
+	case <-workerDone:
+		serviceLogger.Info().Msg("Semua worker selesai memproses job.")
+	case <-time.After(cfg.WorkerDrainTimeout):
+		serviceLogger.Warn().Dur("timeout", cfg.WorkerDrainTimeout).Msg("Batas waktu drain worker terlampaui, melanjutkan shutdown")
+	}
 	hub.Stop()
 
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -137,49 +296,92 @@ func main() {
 	enhanced_logger.LogShutdown(cfg.ServiceName)
 }
 
-// FIX: Ubah tipe EmailSender ke tipe konkret *service.EmailService dan Logger ke zerolog.Logger
-func runWorker(ctx context.Context, qs service.Queue, es *service.EmailService, hub *websocket.Hub, logger zerolog.Logger) {
-	logger.Info().Msg("Worker antrian notifikasi dimulai...")
-	const maxRetries = 3
-	const retryDelay = 20 * time.Second
-
-	for {
-		select {
-		case <-ctx.Done():
-			logger.Info().Msg("Worker antrian notifikasi berhenti.")
-			return
-		default:
-			job, err := qs.Dequeue(ctx)
+// lanePolicy menerjemahkan konfigurasi lane prioritas untuk backend list.
+func lanePolicy(cfg *notifconfig.Config) service.LanePolicy {
+	policy := service.LanePolicy{Weighted: cfg.QueuePriorityMode == "weighted"}
+	if len(cfg.QueueLaneWeights) > 0 {
+		policy.Weights = map[service.Priority]int{}
+		for lane, weight := range cfg.QueueLaneWeights {
+			p, err := service.ParsePriority(lane)
 			if err != nil {
-				if !errors.Is(err, redis.Nil) && !errors.Is(err, context.Canceled) {
-					logger.Error().Err(err).Msg("Gagal mengambil job dari antrian, mencoba lagi...")
-					time.Sleep(5 * time.Second)
-				}
 				continue
 			}
+			policy.Weights[p] = weight
+		}
+	}
+	return policy
+}
 
-			logger.Info().Str("recipient_id", job.RecipientUserID).Str("subject", job.Subject).Msg("Memproses job notifikasi")
-
-			if hub.SendToUser(job.RecipientUserID, map[string]string{"type": "new_notification", "subject": job.Subject}) {
-				logger.Info().Str("user_id", job.RecipientUserID).Msg("Notifikasi terkirim via WebSocket")
-			}
-
-			var sendErr error
-			for i := 0; i < maxRetries; i++ {
-				sendErr = es.Send(job.To, job.Subject, job.TemplateName, job.TemplateData)
-				if sendErr == nil {
-					break
-				}
-				logger.Warn().Err(sendErr).Int("attempt", i+1).Msg("Gagal mengirim email, mencoba lagi...")
-				if i < maxRetries-1 {
-					time.Sleep(retryDelay)
-				}
-			}
+// emailSender membuat provider email sesuai EMAIL_PROVIDER. Lebih dari satu
+// provider dibungkus FailoverSender; provider tanpa kredensial dilewati agar
+// rantai tidak berakhir di mode simulasi.
+func emailSender(ctx context.Context, cfg *notifconfig.Config, logger zerolog.Logger) (service.EmailSender, error) {
+	providers := cfg.EmailProviders()
+	var senders []service.EmailSender
+	for _, provider := range providers {
+		sender, err := service.NewEmailSenderFromEnv(service.EmailProviderConfig{
+			Provider:         provider,
+			SESRegion:        cfg.SESRegion,
+			SESEndpoint:      cfg.SESEndpoint,
+			SendGridEndpoint: cfg.SendGridEndpoint,
+			SMTPPool: service.SMTPPoolConfig{
+				MaxConns:           cfg.SMTPPoolSize,
+				IdleTimeout:        cfg.SMTPPoolIdleTimeout,
+				MaxMessagesPerConn: cfg.SMTPPoolMaxMessagesPerConn,
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if smtpSender, ok := sender.(*service.SMTPSender); ok {
+			go smtpSender.RunReaper(ctx, cfg.SMTPPoolIdleTimeout)
+		}
+		if _, simulated := sender.(service.SimulatedSender); simulated && len(providers) > 1 {
+			logger.Warn().Str("email_provider", provider).Msg("Kredensial provider email tidak tersedia, provider dikeluarkan dari rantai failover")
+			continue
+		}
+		senders = append(senders, sender)
+	}
+	switch len(senders) {
+	case 0:
+		return service.SimulatedSender{}, nil
+	case 1:
+		return senders[0], nil
+	}
+	failover := service.NewFailoverSender(senders, service.BreakerConfig{
+		FailureThreshold: cfg.EmailBreakerFailureThreshold,
+		Cooldown:         cfg.EmailBreakerCooldown,
+	})
+	go failover.Run(ctx, cfg.EmailProbeInterval)
+	logger.Info().Str("email_provider", failover.Name()).Msg("Rantai failover provider email aktif")
+	return failover, nil
+}
 
-			if sendErr != nil {
-				logger.Error().Err(sendErr).Msg("Job gagal setelah semua percobaan, dipindahkan ke DLQ")
-				_ = qs.EnqueueToDLQ(context.Background(), *job)
-			}
+// retryPolicies menerjemahkan konfigurasi retry menjadi policy worker.
+func retryPolicies(cfg *notifconfig.Config) worker.RetryPolicies {
+	policies := worker.RetryPolicies{
+		Default: worker.RetryPolicy{
+			MaxAttempts: cfg.RetryMaxAttempts,
+			BaseDelay:   cfg.RetryBaseDelay,
+			MaxDelay:    cfg.RetryMaxDelay,
+		},
+		PerTemplate: map[string]worker.RetryPolicy{},
+	}
+	for template, p := range cfg.TemplateRetryPolicies {
+		policies.PerTemplate[template] = worker.RetryPolicy{
+			MaxAttempts: p.MaxAttempts,
+			BaseDelay:   time.Duration(p.BaseDelaySeconds) * time.Second,
+			MaxDelay:    time.Duration(p.MaxDelaySeconds) * time.Second,
 		}
 	}
+	return policies
+}
+
+// consumerName menghasilkan nama unik untuk processing list worker ini.
+func consumerName() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
 }